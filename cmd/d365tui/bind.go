@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// runBind binds a local file to a web resource without starting the TUI
+func runBind(args []string) error {
+	fs := flag.NewFlagSet("bind", flag.ContinueOnError)
+	envName := fs.String("env", "", "environment name (defaults to the current environment)")
+	resourceName := fs.String("resource", "", "web resource unique name")
+	filePath := fs.String("file", "", "local file to bind")
+	autoPublish := fs.Bool("auto", false, "enable auto-publish for the binding")
+	create := fs.Bool("create", false, "create the web resource if it does not exist")
+	displayName := fs.String("display-name", "", "display name for a created web resource (defaults to the file name)")
+	var concat []string
+	fs.Func("concat", "another local file appended to --file when publishing; repeat in order", func(path string) error {
+		absPath, err := config.ValidateBindingPath(path)
+		if err != nil {
+			return err
+		}
+		concat = append(concat, absPath)
+		return nil
+	})
+	build := fs.String("build", "", "command run in the file's folder before every publish, e.g. to bundle it")
+	var watch []string
+	fs.Func("watch", "a file whose changes auto-publish the binding instead of --file; repeat for more", func(path string) error {
+		absPath, err := config.ValidateBindingPath(path)
+		if err != nil {
+			return err
+		}
+		watch = append(watch, absPath)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *resourceName == "" {
+		return errors.New("--resource is required")
+	}
+	if *build != "" && len(watch) == 0 && *autoPublish {
+		// Watching the output would rebuild after every build
+		return errors.New("--build with --auto needs --watch for the build's inputs")
+	}
+	absPath, err := config.ValidateBindingPath(*filePath)
+	if err != nil {
+		return err
+	}
+
+	cfg, env, client, err := connect(*envName)
+	if err != nil {
+		return err
+	}
+
+	res, err := resolveWebResource(client, env, *resourceName, absPath, *create, *displayName)
+	if err != nil {
+		return err
+	}
+
+	binding := config.Binding{
+		Environment:      env.Name,
+		LocalPath:        absPath,
+		WebResourceName:  res.Name,
+		WebResourceID:    res.ID,
+		ServerVersion:    res.Version,
+		LastKnownVersion: "1.0.0",
+		AutoPublish:      *autoPublish,
+		DisplayName:      res.DisplayName,
+		BuildCommand:     *build,
+		WatchPaths:       watch,
+	}
+	if len(concat) > 0 {
+		binding.LocalPaths = append([]string{absPath}, concat...)
+	}
+	if err := cfg.AddBinding(binding); err != nil {
+		return fmt.Errorf("save binding: %w", err)
+	}
+
+	fmt.Printf("Bound %s to %s (%s)\n", res.Name, absPath, env.Name)
+	for _, path := range concat {
+		fmt.Printf("  + %s\n", path)
+	}
+	for _, path := range watch {
+		fmt.Printf("  watching %s\n", path)
+	}
+	return nil
+}
+
+// resolveWebResource finds a web resource by name, optionally creating it from the local file
+func resolveWebResource(client *d365.Client, env *config.Environment, name, localPath string, create bool, displayName string) (*d365.WebResource, error) {
+	res, err := client.GetWebResourceByName(name)
+	if err == nil {
+		return res, nil
+	}
+	if !errors.Is(err, d365.ErrNotFound) || !create {
+		return nil, err
+	}
+
+	resourceType, err := d365.GetWebResourceTypeFromExtension(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if resourceType.IsText() {
+		content, _ = env.ApplyTokens(content)
+	}
+
+	if displayName == "" {
+		displayName = filepath.Base(name)
+	}
+	id, err := client.CreateWebResource(name, displayName, base64.StdEncoding.EncodeToString(content), resourceType)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.PublishWebResource(id); err != nil {
+		return nil, err
+	}
+
+	return &d365.WebResource{ID: id, Name: name, DisplayName: displayName}, nil
+}
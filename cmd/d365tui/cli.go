@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/auth"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/httpclient"
+)
+
+// connect loads the config and returns an authenticated client for the named environment
+func connect(envName string) (*config.Config, *config.Environment, *d365.Client, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("load config: %w", err)
+	}
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+	if err := httpclient.Configure(cfg); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if envName == "" {
+		envName = cfg.CurrentEnvironment
+	}
+	env := cfg.GetEnvironment(envName)
+	if env == nil {
+		return nil, nil, nil, fmt.Errorf("environment not found: %q", envName)
+	}
+
+	token, err := auth.LoadToken(env.Name)
+	if err != nil || token.IsExpired() {
+		if token, err = signIn(*env, token); err != nil {
+			return nil, nil, nil, err
+		}
+		if err := auth.SaveToken(env.Name, token); err != nil {
+			return nil, nil, nil, fmt.Errorf("save token: %w", err)
+		}
+	}
+
+	if env.TokenOutputDir != "" {
+		if err := auth.ExportAccessToken(env.TokenOutputDir, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Token export failed: %v\n", err)
+		}
+	}
+
+	client := d365.NewClient(env.URL, token.AccessToken, d365.ClientOptions{
+		ConnectTimeout: cfg.ConnectTimeout(),
+		RequestTimeout: cfg.RequestTimeout(),
+		PublishTimeout: cfg.PublishTimeout(),
+	})
+	client.SetMaxRetries(cfg.RetryLimit())
+	return cfg, env, client, nil
+}
+
+// signIn returns a new token for an environment whose stored token is missing
+// or expired. It refreshes silently first and only asks the user to sign in
+// when that fails.
+func signIn(env config.Environment, expired *auth.Token) (*auth.Token, error) {
+	refreshToken := ""
+	if expired != nil {
+		refreshToken = expired.RefreshToken
+	}
+	if token, err := auth.RefreshToken(env, refreshToken); err == nil {
+		return token, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Signing in to %s...\n", env.Name)
+	if auth.UseDeviceCode(env) {
+		return auth.AcquireTokenDeviceCode(env, func(code *auth.DeviceCodeResponse) {
+			fmt.Fprintf(os.Stderr, "To sign in, open %s and enter the code %s\n", code.VerificationURI, code.UserCode)
+		})
+	}
+	return auth.AcquireToken(env)
+}
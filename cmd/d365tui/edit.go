@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/publisher"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/watcher"
+)
+
+// runEdit binds a single file if needed, publishes it and keeps publishing on
+// every save until interrupted
+func runEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	envName := fs.String("env", "", "environment name (defaults to the current environment)")
+	resourceName := fs.String("resource", "", "web resource unique name")
+	filePath := fs.String("file", "", "local file to publish")
+	create := fs.Bool("create", false, "create the web resource if it does not exist")
+	displayName := fs.String("display-name", "", "display name for a created web resource (defaults to the file name)")
+	jsonOutput := fs.Bool("json", false, "print one JSON object per publish instead of text")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *resourceName == "" {
+		return errors.New("--resource is required")
+	}
+	absPath, err := config.ValidateBindingPath(*filePath)
+	if err != nil {
+		return err
+	}
+
+	// Keep stdout to the JSON results
+	var info io.Writer = os.Stdout
+	if *jsonOutput {
+		info = os.Stderr
+	}
+
+	cfg, env, client, err := connect(*envName)
+	if err != nil {
+		return err
+	}
+
+	res, err := resolveWebResource(client, env, *resourceName, absPath, *create, *displayName)
+	if err != nil {
+		return err
+	}
+
+	binding := cfg.GetBinding(env.Name, res.ID)
+	if binding == nil || binding.LocalPath != absPath {
+		newBinding := config.Binding{
+			Environment:      env.Name,
+			LocalPath:        absPath,
+			WebResourceName:  res.Name,
+			WebResourceID:    res.ID,
+			ServerVersion:    res.Version,
+			LastKnownVersion: "1.0.0",
+			AutoPublish:      true,
+			DisplayName:      res.DisplayName,
+		}
+		if err := cfg.AddBinding(newBinding); err != nil {
+			return fmt.Errorf("save binding: %w", err)
+		}
+		fmt.Fprintf(info, "Bound %s to %s (%s)\n", res.Name, absPath, env.Name)
+	}
+
+	publish := func() {
+		binding := cfg.GetBinding(env.Name, res.ID)
+		if binding == nil {
+			fmt.Fprintf(os.Stderr, "Binding for %s was removed\n", res.Name)
+			return
+		}
+		result, err := publisher.Publish(client, cfg, *binding)
+		if *jsonOutput {
+			printJSON(newPublishReport(*binding, result, err))
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Publish failed: %v\n", err)
+			return
+		}
+		if result.Unchanged {
+			fmt.Printf("No changes in %s\n", filepath.Base(absPath))
+			return
+		}
+		fmt.Printf("Published %s (%s)\n", filepath.Base(absPath), result.Version)
+		if result.Warning != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", result.Warning)
+		}
+	}
+
+	publish()
+
+	w, err := watcher.New(func(path string) {
+		publish()
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	w.SetWriteOnly(cfg.WatchWriteOnly())
+
+	if err := w.AddFile(absPath); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(info, "Watching %s, press Ctrl-C to stop\n", absPath)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/solution"
+)
+
+// runImport extracts the web resources of a solution export zip and binds them
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	envName := fs.String("env", "", "environment name (defaults to the current environment)")
+	zipPath := fs.String("zip", "", "solution export zip")
+	dir := fs.String("dir", ".", "directory to extract web resources into")
+	list := fs.Bool("list", false, "only list the web resources in the zip")
+	yes := fs.Bool("yes", false, "bind every web resource without asking")
+	autoPublish := fs.Bool("auto", false, "enable auto-publish for the bindings")
+	overwrite := fs.Bool("overwrite", false, "overwrite local files that already exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *zipPath == "" {
+		return errors.New("--zip is required")
+	}
+
+	archive, err := solution.Open(*zipPath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	resources, err := archive.WebResources()
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		fmt.Println("The solution contains no web resources")
+		return nil
+	}
+
+	if *list {
+		for _, wr := range resources {
+			fmt.Printf("%-4s %s\n", wr.Type, wr.Name)
+		}
+		return nil
+	}
+
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+
+	cfg, env, client, err := connect(*envName)
+	if err != nil {
+		return err
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	bound := 0
+	for _, wr := range resources {
+		target, err := solution.LocalPath(root, wr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipped %s: %v\n", wr.Name, err)
+			continue
+		}
+
+		if !*yes {
+			answer := prompt(stdin, fmt.Sprintf("Bind %s -> %s? [y/N/a/q] ", wr.Name, target))
+			switch answer {
+			case "a":
+				*yes = true
+			case "q":
+				fmt.Printf("Bound %d of %d web resources\n", bound, len(resources))
+				return nil
+			case "y":
+			default:
+				continue
+			}
+		}
+
+		if err := importWebResource(cfg, env, client, archive, wr, target, *autoPublish, *overwrite); err != nil {
+			fmt.Fprintf(os.Stderr, "Skipped %s: %v\n", wr.Name, err)
+			continue
+		}
+		bound++
+	}
+
+	fmt.Printf("Bound %d of %d web resources\n", bound, len(resources))
+	return nil
+}
+
+// importWebResource extracts one web resource and binds it to the matching resource in the environment
+func importWebResource(cfg *config.Config, env *config.Environment, client *d365.Client, archive *solution.Archive, wr solution.WebResource, target string, autoPublish, overwrite bool) error {
+	// The solution must already be imported; match by ID, then by name
+	res, err := client.GetWebResource(wr.ID)
+	if errors.Is(err, d365.ErrNotFound) {
+		res, err = client.GetWebResourceByName(wr.Name)
+	}
+	if errors.Is(err, d365.ErrNotFound) {
+		return fmt.Errorf("not found in %s; import the solution first", env.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(target); err == nil && !overwrite {
+		fmt.Printf("Keeping existing %s\n", target)
+	} else if err := archive.Extract(wr, target); err != nil {
+		return err
+	}
+
+	binding := config.Binding{
+		Environment:      env.Name,
+		LocalPath:        target,
+		WebResourceName:  res.Name,
+		WebResourceID:    res.ID,
+		ServerVersion:    res.Version,
+		LastKnownVersion: "1.0.0",
+		AutoPublish:      autoPublish,
+	}
+	if err := cfg.AddBinding(binding); err != nil {
+		return fmt.Errorf("save binding: %w", err)
+	}
+
+	fmt.Printf("Bound %s to %s\n", res.Name, target)
+	return nil
+}
+
+// prompt asks a question on stdout and returns the lower-cased first word of the answer
+func prompt(r *bufio.Reader, question string) string {
+	fmt.Print(question)
+	line, _ := r.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(line))
+}
@@ -1,15 +1,37 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/tui"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	if len(os.Args) > 1 && strings.HasPrefix(os.Args[1], "-") && os.Args[1] != "-h" && os.Args[1] != "--help" {
+		fs := flag.NewFlagSet("d365tui", flag.ContinueOnError)
+		readOnly := fs.Bool("read-only", false, "browse without writing config, tokens or web resources")
+		if err := fs.Parse(os.Args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			os.Exit(2)
+		}
+		config.SetReadOnly(*readOnly)
+	} else if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	p := tea.NewProgram(tui.NewModel(), tea.WithAltScreen())
 
 	if _, err := p.Run(); err != nil {
@@ -17,3 +39,44 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runCommand dispatches a non-interactive subcommand
+func runCommand(name string, args []string) error {
+	switch name {
+	case "bind":
+		return runBind(args)
+	case "edit":
+		return runEdit(args)
+	case "import":
+		return runImport(args)
+	case "publish":
+		return runPublish(args)
+	case "pull":
+		return runPull(args)
+	case "validate":
+		return runValidate(args)
+	case "verify":
+		return runVerify(args)
+	case "help", "-h", "--help":
+		printUsage()
+		return nil
+	default:
+		printUsage()
+		return fmt.Errorf("unknown command: %s", name)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, `Usage:
+  d365tui                 Start the interactive TUI
+  d365tui --read-only     Browse without writing config, tokens or web resources
+  d365tui bind [flags]    Bind a local file to a web resource
+  d365tui edit [flags]    Bind, publish and watch a single file until Ctrl-C
+  d365tui import [flags]  Extract and bind the web resources of a solution zip
+  d365tui publish [flags] Publish bound files once; --json prints one result per line
+  d365tui pull [flags]    Download and bind every web resource of an environment
+  d365tui validate        Check every environment URL and binding in the config
+  d365tui verify [flags]  Compare bound files with the deployed web resources
+
+Run 'd365tui <command> -h' for command flags.`)
+}
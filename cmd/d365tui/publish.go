@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/publisher"
+)
+
+// publishReport is printed as one JSON line per publish with --json
+type publishReport struct {
+	Resource    string `json:"resource,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	File        string `json:"file,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Status      string `json:"status"` // published, unchanged or failed
+	Warning     string `json:"warning,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// newPublishReport describes the outcome of publishing a binding
+func newPublishReport(binding config.Binding, result *publisher.Result, err error) publishReport {
+	report := publishReport{
+		Resource:    binding.WebResourceName,
+		Environment: binding.Environment,
+		File:        binding.LocalPath,
+	}
+	switch {
+	case err != nil:
+		report.Status = "failed"
+		report.Error = err.Error()
+	case result.Unchanged:
+		report.Status = "unchanged"
+		report.Version = result.Version
+	default:
+		report.Status = "published"
+		report.Version = result.Version
+		report.Warning = result.Warning
+	}
+	return report
+}
+
+// printJSON writes v to stdout as a single line
+func printJSON(v any) {
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
+// runPublish publishes bound files once and exits, for scripts and CI. The
+// exit code is non-zero when any publish fails.
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	var opts publishOptions
+	fs.StringVar(&opts.env, "env", "", "environment name (defaults to the current environment)")
+	fs.StringVar(&opts.resource, "resource", "", "publish the binding of this web resource")
+	fs.StringVar(&opts.file, "file", "", "publish the binding of this local file")
+	fs.BoolVar(&opts.all, "all", false, "publish every binding of the environment")
+	fs.BoolVar(&opts.force, "force", false, "publish even if unchanged or changed on the server")
+	fs.BoolVar(&opts.yes, "yes", false, "confirm publishing to an environment that requires confirmation")
+	fs.BoolVar(&opts.json, "json", false, "print one JSON object per publish instead of text")
+	fs.BoolVar(&opts.quiet, "quiet", false, "only print failures and warnings")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	err := publishBindings(opts)
+	if err != nil && opts.json && !errors.Is(err, errPublishFailed) {
+		printJSON(publishReport{Environment: opts.env, Status: "failed", Error: err.Error()})
+	}
+	return err
+}
+
+// publishOptions holds the flags of the publish command
+type publishOptions struct {
+	env, resource, file string
+	all, force, yes     bool
+	json, quiet         bool
+}
+
+// selectBindings returns the bindings of an environment for a web resource
+// name or an absolute local path, or all of them
+func selectBindings(cfg *config.Config, envName, resourceName, absPath string, all bool) ([]config.Binding, error) {
+	var bindings []config.Binding
+	for _, b := range cfg.GetBindingsForEnvironment(envName) {
+		if all || (resourceName != "" && b.WebResourceName == resourceName) || (absPath != "" && b.LocalPath == absPath) {
+			bindings = append(bindings, b)
+		}
+	}
+	if len(bindings) == 0 {
+		return nil, fmt.Errorf("no matching bindings in %s", envName)
+	}
+	return bindings, nil
+}
+
+// errPublishFailed is returned by publishBindings when a publish failed that
+// was already reported
+var errPublishFailed = errors.New("publish failed")
+
+// publishBindings selects the bindings to publish and publishes them one by one
+func publishBindings(opts publishOptions) error {
+	if !opts.all && opts.resource == "" && opts.file == "" {
+		return errors.New("one of --resource, --file or --all is required")
+	}
+	var absPath string
+	if opts.file != "" {
+		var err error
+		if absPath, err = config.ValidateBindingPath(opts.file); err != nil {
+			return err
+		}
+	}
+
+	cfg, env, client, err := connect(opts.env)
+	if err != nil {
+		return err
+	}
+	if env.RequireConfirm && !opts.yes {
+		return fmt.Errorf("%s requires confirmation; pass --yes to publish to it", env.Name)
+	}
+
+	bindings, err := selectBindings(cfg, env.Name, opts.resource, absPath, opts.all)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, binding := range bindings {
+		var result *publisher.Result
+		if opts.force {
+			result, err = publisher.Overwrite(client, cfg, binding)
+		} else {
+			result, err = publisher.Publish(client, cfg, binding)
+		}
+		if err != nil {
+			failed++
+		}
+
+		if opts.json {
+			printJSON(newPublishReport(binding, result, err))
+			continue
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Failed %s: %v\n", binding.WebResourceName, err)
+		case result.Unchanged:
+			if !opts.quiet {
+				fmt.Printf("No changes in %s\n", binding.WebResourceName)
+			}
+		default:
+			if !opts.quiet {
+				fmt.Printf("Published %s (%s)\n", binding.WebResourceName, result.Version)
+			}
+			if result.Warning != "" {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", result.Warning)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d web resources", errPublishFailed, failed, len(bindings))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/solution"
+)
+
+// runPull downloads the web resources of an environment into a folder
+// mirroring their names and binds them, to start a local working copy
+func runPull(args []string) error {
+	fs := flag.NewFlagSet("pull", flag.ContinueOnError)
+	envName := fs.String("env", "", "environment name (defaults to the current environment)")
+	dir := fs.String("dir", ".", "directory to download web resources into")
+	prefix := fs.String("prefix", "", "only pull web resources whose name starts with this, e.g. new_")
+	binary := fs.Bool("binary", false, "also pull images and other binary web resources")
+	managed := fs.Bool("managed", false, "also pull managed web resources")
+	autoPublish := fs.Bool("auto", false, "enable auto-publish for the bindings")
+	overwrite := fs.Bool("overwrite", false, "overwrite local files that already exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+
+	cfg, env, client, err := connect(*envName)
+	if err != nil {
+		return err
+	}
+
+	resources, err := client.ListAllWebResources(*managed)
+	if err != nil {
+		return err
+	}
+
+	var selected []d365.WebResource
+	skippedBinary := 0
+	for _, res := range resources {
+		if !strings.HasPrefix(res.Name, *prefix) {
+			continue
+		}
+		if !res.Type.IsText() && !*binary {
+			skippedBinary++
+			continue
+		}
+		selected = append(selected, res)
+	}
+	if len(selected) == 0 {
+		fmt.Printf("No web resources to pull from %s\n", env.Name)
+		return nil
+	}
+
+	bound := 0
+	for i, res := range selected {
+		fmt.Printf("[%d/%d] ", i+1, len(selected))
+		if existing := cfg.GetBinding(env.Name, res.ID); existing != nil {
+			fmt.Printf("Skipped %s: already bound to %s\n", res.Name, existing.LocalPath)
+			continue
+		}
+		target, err := solution.NamePath(root, res.Name)
+		if err == nil {
+			err = pullWebResource(cfg, env, client, res, target, *autoPublish, *overwrite)
+		}
+		if err != nil {
+			fmt.Printf("Skipped %s: %v\n", res.Name, err)
+			continue
+		}
+		bound++
+	}
+
+	fmt.Printf("Bound %d of %d web resources", bound, len(selected))
+	if skippedBinary > 0 {
+		fmt.Printf("; left out %d binary ones (use --binary to include them)", skippedBinary)
+	}
+	fmt.Println()
+	return nil
+}
+
+// pullWebResource downloads one web resource to target and binds it
+func pullWebResource(cfg *config.Config, env *config.Environment, client *d365.Client, res d365.WebResource, target string, autoPublish, overwrite bool) error {
+	if _, err := os.Stat(target); err == nil && !overwrite {
+		fmt.Printf("Keeping existing %s; ", target)
+	} else {
+		encoded, err := client.GetWebResourceContent(res.ID)
+		if err != nil {
+			return err
+		}
+		content, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("decode content: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return err
+		}
+	}
+
+	binding := config.Binding{
+		Environment:      env.Name,
+		LocalPath:        target,
+		WebResourceName:  res.Name,
+		WebResourceID:    res.ID,
+		ServerVersion:    res.Version,
+		LastKnownVersion: "1.0.0",
+		AutoPublish:      autoPublish,
+		DisplayName:      res.DisplayName,
+	}
+	if err := cfg.AddBinding(binding); err != nil {
+		return fmt.Errorf("save binding: %w", err)
+	}
+
+	fmt.Printf("Bound %s to %s\n", res.Name, target)
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+)
+
+// runValidate checks every environment and binding and prints a report
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	summary, err := config.Check()
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range summary.Problems {
+		fmt.Println(problem)
+	}
+	fmt.Printf("Checked %d environments and %d bindings: ", summary.Environments, summary.Bindings)
+	if len(summary.Problems) == 0 {
+		fmt.Println("no problems found")
+		return nil
+	}
+	fmt.Printf("%d problems found\n", len(summary.Problems))
+	return fmt.Errorf("config has %d problems", len(summary.Problems))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/publisher"
+)
+
+// verifyReport is printed as one JSON line per binding with --json
+type verifyReport struct {
+	Resource    string `json:"resource"`
+	Environment string `json:"environment"`
+	File        string `json:"file"`
+	Status      string `json:"status"` // in-sync, local-ahead, conflict, missing or error
+	ChangedBy   string `json:"changedBy,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// syncStatusNames are the JSON names of the sync statuses
+var syncStatusNames = map[publisher.SyncStatus]string{
+	publisher.SyncInSync:     "in-sync",
+	publisher.SyncLocalAhead: "local-ahead",
+	publisher.SyncConflict:   "conflict",
+	publisher.SyncMissing:    "missing",
+	publisher.SyncError:      "error",
+}
+
+// runVerify compares bound files with the content deployed in an environment
+// and prints a summary. The exit code is non-zero unless every binding is in
+// sync.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	envName := fs.String("env", "", "environment name (defaults to the current environment)")
+	resourceName := fs.String("resource", "", "only verify the binding of this web resource")
+	filePath := fs.String("file", "", "only verify the binding of this local file")
+	jsonOutput := fs.Bool("json", false, "print one JSON object per binding instead of text")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var absPath string
+	if *filePath != "" {
+		var err error
+		if absPath, err = config.ValidateBindingPath(*filePath); err != nil {
+			return err
+		}
+	}
+
+	cfg, env, client, err := connect(*envName)
+	if err != nil {
+		return err
+	}
+	bindings, err := selectBindings(cfg, env.Name, *resourceName, absPath, *resourceName == "" && absPath == "")
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[publisher.SyncStatus]int)
+	for _, binding := range bindings {
+		result := publisher.CheckSync(client, env, binding)
+		counts[result.Status]++
+
+		if *jsonOutput {
+			report := verifyReport{
+				Resource:    binding.WebResourceName,
+				Environment: binding.Environment,
+				File:        binding.LocalPath,
+				Status:      syncStatusNames[result.Status],
+			}
+			if result.Audit != nil {
+				report.ChangedBy = result.Audit.ModifiedBy.FullName
+			}
+			if result.Err != nil {
+				report.Error = result.Err.Error()
+			}
+			printJSON(report)
+			continue
+		}
+		line := fmt.Sprintf("[%s] %s", result.Status, binding.WebResourceName)
+		if result.Audit != nil && result.Audit.ModifiedBy.FullName != "" {
+			line += fmt.Sprintf(" (changed by %s)", result.Audit.ModifiedBy.FullName)
+		}
+		if result.Err != nil {
+			line += ": " + result.Err.Error()
+		}
+		fmt.Println(line)
+	}
+
+	var summary []string
+	for _, status := range []publisher.SyncStatus{publisher.SyncInSync, publisher.SyncLocalAhead, publisher.SyncConflict, publisher.SyncMissing, publisher.SyncError} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], strings.ToLower(status.String())))
+		}
+	}
+	if !*jsonOutput {
+		fmt.Printf("Checked %d bindings in %s: %s\n", len(bindings), env.Name, strings.Join(summary, ", "))
+	}
+	if counts[publisher.SyncInSync] < len(bindings) {
+		return errors.New("not every binding is in sync")
+	}
+	return nil
+}
@@ -2,7 +2,14 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/public"
 )
@@ -10,14 +17,70 @@ import (
 const (
 	ClientID      = "51f81489-12ee-4a9e-aaae-a2591f45987d"
 	RedirectURL   = "http://localhost:8400"
-	AuthorityBase = "https://login.microsoftonline.com/common"
+	LoginHost     = "https://login.microsoftonline.com"
+	DefaultTenant = "common"
 )
 
+// httpClient sends every sign-in request
+var httpClient = &http.Client{}
+
+// SetHTTPClient replaces the client used for sign-in requests, e.g. to go
+// through a proxy or trust a custom CA
+func SetHTTPClient(client *http.Client) {
+	httpClient = client
+}
+
+// Registration is the Azure AD app registration, tenant and cloud to sign in
+// with. Empty fields fall back to ClientID, DefaultTenant and LoginHost.
+type Registration struct {
+	ClientID  string
+	TenantID  string
+	LoginHost string
+}
+
+// RegistrationFor returns the app registration configured for an environment
+func RegistrationFor(env config.Environment) Registration {
+	return Registration{
+		ClientID:  strings.TrimSpace(env.ClientID),
+		TenantID:  strings.TrimSpace(env.TenantID),
+		LoginHost: env.LoginHost(),
+	}
+}
+
+func (r Registration) clientID() string {
+	if r.ClientID != "" {
+		return r.ClientID
+	}
+	return ClientID
+}
+
+// newPublicClient creates the MSAL client of an app registration, sharing
+// the persistent cache of every client of that registration
+func newPublicClient(reg Registration) (public.Client, error) {
+	return public.New(reg.clientID(),
+		public.WithAuthority(reg.authority()),
+		public.WithHTTPClient(httpClient),
+		public.WithCache(msalCache{clientID: reg.clientID()}),
+	)
+}
+
+// authority returns the login URL of the tenant
+func (r Registration) authority() string {
+	host := r.LoginHost
+	if host == "" {
+		host = LoginHost
+	}
+	if r.TenantID != "" {
+		return host + "/" + r.TenantID
+	}
+	return host + "/" + DefaultTenant
+}
+
 // AcquireTokenInteractive authenticates the user via browser and returns a token
-func AcquireTokenInteractive(orgURL string) (*Token, error) {
+func AcquireTokenInteractive(orgURL string, reg Registration) (*Token, error) {
 	scope := orgURL + "/.default"
 
-	app, err := public.New(ClientID, public.WithAuthority(AuthorityBase))
+	app, err := newPublicClient(reg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create public client: %w", err)
 	}
@@ -52,12 +115,34 @@ func AcquireTokenInteractive(orgURL string) (*Token, error) {
 	return convertAuthResult(result), nil
 }
 
-// RefreshAccessToken refreshes an expired token using MSAL
-func RefreshAccessToken(refreshToken, orgURL string) (*Token, error) {
+// CanOpenBrowser reports whether a browser sign-in can work in this session:
+// not over SSH on macOS and Windows, and only with a graphical session (which
+// may be forwarded over SSH) elsewhere
+func CanOpenBrowser() bool {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return os.Getenv("SSH_CONNECTION") == "" && os.Getenv("SSH_TTY") == ""
+	default:
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+}
+
+// UseDeviceCode reports whether a user should sign in to an environment with
+// a device code: when it is configured to, or when no browser can be opened
+func UseDeviceCode(env config.Environment) bool {
+	if env.UsesClientCredentials() {
+		return false
+	}
+	return env.AuthMode == config.AuthModeDeviceCode || !CanOpenBrowser()
+}
+
+// RefreshAccessToken refreshes an expired token silently with the account
+// MSAL cached during an earlier browser sign-in of the app registration
+func RefreshAccessToken(refreshToken, orgURL string, reg Registration) (*Token, error) {
 	scope := orgURL + "/.default"
 
 	// Create public client application
-	app, err := public.New(ClientID, public.WithAuthority(AuthorityBase))
+	app, err := newPublicClient(reg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create public client: %w", err)
 	}
@@ -81,11 +166,69 @@ func RefreshAccessToken(refreshToken, orgURL string) (*Token, error) {
 	return convertAuthResult(result), nil
 }
 
+// SignOut removes the stored token of an environment and the account it
+// signed in with from the persistent MSAL cache, so the next sign-in can use
+// a different account. The account is kept while another environment of the
+// same app registration still signs in with it.
+func SignOut(env config.Environment, environments []config.Environment) error {
+	token, _ := LoadToken(env.Name)
+	if err := DeleteToken(env.Name); err != nil {
+		return err
+	}
+	if env.UsesClientCredentials() || token == nil || token.AccountID == "" {
+		return nil
+	}
+
+	reg := RegistrationFor(env)
+	for _, other := range environments {
+		if other.Name == env.Name || RegistrationFor(other).clientID() != reg.clientID() {
+			continue
+		}
+		if t, err := LoadToken(other.Name); err == nil && t.AccountID == token.AccountID {
+			return nil
+		}
+	}
+
+	app, err := newPublicClient(reg)
+	if err != nil {
+		return fmt.Errorf("failed to create public client: %w", err)
+	}
+	accounts, err := app.Accounts(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get accounts: %w", err)
+	}
+	for _, account := range accounts {
+		if account.HomeAccountID != token.AccountID {
+			continue
+		}
+		if err := app.RemoveAccount(context.Background(), account); err != nil {
+			return fmt.Errorf("failed to remove cached account: %w", err)
+		}
+	}
+	return nil
+}
+
+// IsCancelled reports whether an authentication error was caused by the user
+// cancelling or declining the sign-in rather than a genuine failure
+func IsCancelled(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "access_denied") ||
+		strings.Contains(msg, "cancel") ||
+		strings.Contains(msg, "aadsts65004") // user declined consent
+}
+
 // convertAuthResult converts MSAL AuthResult to our Token type
 func convertAuthResult(result public.AuthResult) *Token {
 	return &Token{
 		AccessToken:  result.AccessToken,
 		RefreshToken: "",
 		ExpiresAt:    result.ExpiresOn,
+		AccountID:    result.Account.HomeAccountID,
 	}
 }
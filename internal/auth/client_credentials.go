@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/confidential"
+)
+
+// ClientSecretEnv is the environment variable holding the service principal's
+// client secret. It is never stored in config.json.
+const ClientSecretEnv = "D365TUI_CLIENT_SECRET"
+
+// AcquireTokenClientCredentials authenticates as a service principal. The
+// returned token has no refresh token; once it expires, acquire a new one.
+func AcquireTokenClientCredentials(orgURL, clientID, clientSecret, tenantID string) (*Token, error) {
+	return acquireClientCredentials(orgURL, clientSecret, Registration{ClientID: clientID, TenantID: tenantID})
+}
+
+// acquireClientCredentials authenticates as a service principal in any cloud
+func acquireClientCredentials(orgURL, clientSecret string, reg Registration) (*Token, error) {
+	scope := orgURL + "/.default"
+
+	cred, err := confidential.NewCredFromSecret(clientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client secret: %w", err)
+	}
+
+	app, err := confidential.New(reg.authority(), reg.ClientID, cred, confidential.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create confidential client: %w", err)
+	}
+
+	result, err := app.AcquireTokenByCredential(context.Background(), []string{scope})
+	if err != nil {
+		return nil, fmt.Errorf("client credentials authentication failed: %w", err)
+	}
+
+	return &Token{
+		AccessToken: result.AccessToken,
+		ExpiresAt:   result.ExpiresOn,
+	}, nil
+}
+
+// AcquireToken signs in to an environment the way it is configured to:
+// interactively in the browser, or as a service principal
+func AcquireToken(env config.Environment) (*Token, error) {
+	if !env.UsesClientCredentials() {
+		return AcquireTokenInteractive(env.URL, RegistrationFor(env))
+	}
+	secret := strings.TrimSpace(os.Getenv(ClientSecretEnv))
+	if secret == "" {
+		return nil, fmt.Errorf("%s uses client credentials; set %s to the client secret", env.Name, ClientSecretEnv)
+	}
+	return acquireClientCredentials(env.URL, secret, RegistrationFor(env))
+}
+
+// RefreshToken renews an expired token for an environment without user
+// interaction, whichever flow signed in. A stored refresh token, as the device
+// code flow keeps, is redeemed directly; otherwise the account a browser
+// sign-in left in the persistent MSAL cache is used. Service principals simply
+// acquire a new token.
+func RefreshToken(env config.Environment, refreshToken string) (*Token, error) {
+	if env.UsesClientCredentials() {
+		return AcquireToken(env)
+	}
+	reg := RegistrationFor(env)
+	if refreshToken == "" {
+		return RefreshAccessToken(refreshToken, env.URL, reg)
+	}
+	token, err := RedeemRefreshToken(refreshToken, env.URL, reg)
+	if err == nil {
+		return token, nil
+	}
+	if token, msalErr := RefreshAccessToken(refreshToken, env.URL, reg); msalErr == nil {
+		return token, nil
+	}
+	return nil, err
+}
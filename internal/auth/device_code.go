@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
 )
 
 // DeviceCodeResponse represents the device code response from Azure AD
@@ -31,15 +34,15 @@ type TokenResponse struct {
 }
 
 // RequestDeviceCode initiates the device code flow
-func RequestDeviceCode(orgURL string) (*DeviceCodeResponse, error) {
+func RequestDeviceCode(orgURL string, reg Registration) (*DeviceCodeResponse, error) {
 	scope := orgURL + "/.default"
 
 	data := url.Values{}
-	data.Set("client_id", ClientID)
+	data.Set("client_id", reg.clientID())
 	data.Set("scope", scope)
 
-	resp, err := http.Post(
-		AuthorityBase+"/oauth2/v2.0/devicecode",
+	resp, err := httpClient.Post(
+		reg.authority()+"/oauth2/v2.0/devicecode",
 		"application/x-www-form-urlencoded",
 		strings.NewReader(data.Encode()),
 	)
@@ -65,51 +68,91 @@ func RequestDeviceCode(orgURL string) (*DeviceCodeResponse, error) {
 	return &dcResp, nil
 }
 
-// PollForToken polls for token after user authenticates
-func PollForToken(deviceCode string, orgURL string, interval int) (*Token, error) {
+// AcquireTokenDeviceCode signs in to an environment with a device code,
+// calling prompt with the code to show before it waits for the sign-in
+func AcquireTokenDeviceCode(env config.Environment, prompt func(*DeviceCodeResponse)) (*Token, error) {
+	reg := RegistrationFor(env)
+	code, err := RequestDeviceCode(env.URL, reg)
+	if err != nil {
+		return nil, err
+	}
+	prompt(code)
+	return PollForToken(context.Background(), code.DeviceCode, env.URL, code.Interval, reg)
+}
+
+// PollForToken polls for token after user authenticates, until ctx is
+// cancelled when the sign-in is abandoned
+func PollForToken(ctx context.Context, deviceCode string, orgURL string, interval int, reg Registration) (*Token, error) {
 	scope := orgURL + "/.default"
 
 	data := url.Values{}
-	data.Set("client_id", ClientID)
+	data.Set("client_id", reg.clientID())
 	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
 	data.Set("device_code", deviceCode)
 	data.Set("scope", scope)
 
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
-	defer ticker.Stop()
+	pollInterval := time.Duration(interval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
 
 	timeout := time.After(5 * time.Minute)
 
 	for {
 		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		case <-timeout:
 			return nil, errors.New("authentication timed out")
-		case <-ticker.C:
-			resp, err := http.Post(
-				AuthorityBase+"/oauth2/v2.0/token",
-				"application/x-www-form-urlencoded",
-				strings.NewReader(data.Encode()),
-			)
+		case <-timer.C:
+			// Schedule the next poll up front; slow_down may lengthen it below
+			next := pollInterval
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, reg.authority()+"/oauth2/v2.0/token", strings.NewReader(data.Encode()))
 			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			resp, err := httpClient.Do(req)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				timer.Reset(next)
 				continue
 			}
 
 			body, err := io.ReadAll(resp.Body)
 			resp.Body.Close()
 			if err != nil {
+				timer.Reset(next)
 				continue
 			}
 
 			var tokenResp TokenResponse
 			if err := json.Unmarshal(body, &tokenResp); err != nil {
+				timer.Reset(next)
 				continue
 			}
 
-			if tokenResp.Error == "authorization_pending" {
+			switch tokenResp.Error {
+			case "":
+			case "authorization_pending":
+				timer.Reset(next)
 				continue
-			}
-
-			if tokenResp.Error != "" {
+			case "slow_down":
+				// Azure AD asks clients to back off by at least 5 seconds
+				pollInterval += 5 * time.Second
+				timer.Reset(pollInterval)
+				continue
+			case "expired_token":
+				return nil, errors.New("device code expired, please start sign-in again")
+			case "access_denied", "authorization_declined":
+				return nil, errors.New("sign-in was declined")
+			default:
 				return nil, fmt.Errorf("token error: %s - %s", tokenResp.Error, tokenResp.ErrorDesc)
 			}
 
@@ -120,22 +163,26 @@ func PollForToken(deviceCode string, orgURL string, interval int) (*Token, error
 					ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
 				}, nil
 			}
+			timer.Reset(next)
 		}
 	}
 }
 
-// RefreshAccessTokenLegacy refreshes an expired token using legacy devide code flow
-func RefreshAccessTokenLegacy(refreshToken, orgURL string) (*Token, error) {
+// RedeemRefreshToken exchanges a stored refresh token for a new token. It
+// works for any token that came with a refresh token, such as one from the
+// device code flow. The new token keeps the old refresh token if the server
+// doesn't rotate it.
+func RedeemRefreshToken(refreshToken, orgURL string, reg Registration) (*Token, error) {
 	scope := orgURL + "/.default"
 
 	data := url.Values{}
-	data.Set("client_id", ClientID)
+	data.Set("client_id", reg.clientID())
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", refreshToken)
 	data.Set("scope", scope)
 
-	resp, err := http.Post(
-		AuthorityBase+"/oauth2/v2.0/token",
+	resp, err := httpClient.Post(
+		reg.authority()+"/oauth2/v2.0/token",
 		"application/x-www-form-urlencoded",
 		strings.NewReader(data.Encode()),
 	)
@@ -157,6 +204,12 @@ func RefreshAccessTokenLegacy(refreshToken, orgURL string) (*Token, error) {
 	if tokenResp.Error != "" {
 		return nil, fmt.Errorf("refresh error: %s - %s", tokenResp.Error, tokenResp.ErrorDesc)
 	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("refresh failed: %s", resp.Status)
+	}
+	if tokenResp.RefreshToken == "" {
+		tokenResp.RefreshToken = refreshToken
+	}
 
 	return &Token{
 		AccessToken:  tokenResp.AccessToken,
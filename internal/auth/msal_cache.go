@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/cache"
+)
+
+// msalCache keeps the MSAL token cache of an app registration between runs,
+// stored like environment tokens, so silent refreshes and sign-out find the
+// accounts of earlier browser sign-ins
+type msalCache struct {
+	clientID string
+}
+
+// name is the keychain entry of the cache
+func (c msalCache) name() string {
+	return "msal:" + c.clientID
+}
+
+// path is the file the cache is written to without a keychain
+func (c msalCache) path() string {
+	return filepath.Join(config.GetConfigDir(), "msal-"+c.clientID+".json")
+}
+
+// Replace loads the stored cache into MSAL. A missing or unreadable cache
+// leaves MSAL's empty, which only costs a browser sign-in.
+func (c msalCache) Replace(ctx context.Context, u cache.Unmarshaler, _ cache.ReplaceHints) error {
+	data, err := loadSecret(c.name(), c.path())
+	if err != nil {
+		return nil
+	}
+	u.Unmarshal(data)
+	return nil
+}
+
+// Export stores MSAL's cache after it changed
+func (c msalCache) Export(ctx context.Context, m cache.Marshaler, _ cache.ExportHints) error {
+	if config.ReadOnly() {
+		return nil
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	return storeSecret(c.name(), c.path(), data)
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
 )
 
 type exportedToken struct {
@@ -23,7 +25,11 @@ type tokenClaims struct {
 }
 
 // ExportAccessToken writes a token.json file compatible with the Azure CLI JSON shape.
+// Nothing is written in read-only mode.
 func ExportAccessToken(rootDir string, token *Token) error {
+	if config.ReadOnly() {
+		return nil
+	}
 	rootDir = strings.TrimSpace(rootDir)
 	if rootDir == "" {
 		return errors.New("token export directory is empty")
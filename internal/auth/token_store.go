@@ -1,14 +1,18 @@
 package auth
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/keychain"
 )
 
 // Token represents an OAuth token
@@ -16,6 +20,7 @@ type Token struct {
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
+	AccountID    string    `json:"account_id,omitempty"` // MSAL home account ID of a browser sign-in
 }
 
 // IsExpired checks if the token is expired or about to expire
@@ -26,15 +31,25 @@ func (t *Token) IsExpired() bool {
 
 // tokenFilePath returns the path for the environment-specific token file
 func tokenFilePath(envName string) string {
-	safeName := strings.ReplaceAll(envName, "/", "_")
-	safeName = strings.ReplaceAll(safeName, "\\", "_")
-	return filepath.Join(config.GetConfigDir(), fmt.Sprintf("token-%s.json", safeName))
+	return config.TokenPath(envName)
 }
 
-// LoadToken loads a token for a specific environment
+// tokenKeyPath returns the path of the key that encrypts token files. It sits
+// in the config directory next to them, so the encryption only keeps a token
+// file that is copied or shared on its own from being read; anyone who can
+// read the config directory can decrypt it.
+func tokenKeyPath() string {
+	return filepath.Join(config.GetConfigDir(), "token.key")
+}
+
+// encryptedHeader starts every encrypted token file; files without it were
+// written in plaintext by older versions
+var encryptedHeader = []byte("d365tui-token-v1\n")
+
+// LoadToken loads a token for a specific environment from the OS keychain,
+// or from its token file when the keychain has none
 func LoadToken(envName string) (*Token, error) {
-	path := tokenFilePath(envName)
-	data, err := os.ReadFile(path)
+	data, err := loadSecret(envName, tokenFilePath(envName))
 	if err != nil {
 		return nil, err
 	}
@@ -47,25 +62,128 @@ func LoadToken(envName string) (*Token, error) {
 	return &token, nil
 }
 
-// SaveToken saves a token for a specific environment
+// SaveToken saves a token for a specific environment in the OS keychain. When
+// no keychain is available, or the token is too large for it, the token is
+// written to an encrypted file instead. In read-only mode the token only
+// lives in memory.
 func SaveToken(envName string, token *Token) error {
-	if err := os.MkdirAll(config.GetConfigDir(), 0700); err != nil {
-		return err
+	if config.ReadOnly() {
+		return nil
 	}
 
-	data, err := json.MarshalIndent(token, "", "  ")
+	data, err := json.Marshal(token)
 	if err != nil {
 		return err
 	}
+	return storeSecret(envName, tokenFilePath(envName), data)
+}
+
+// loadSecret reads what storeSecret stored under a keychain name or in its
+// file
+func loadSecret(name, path string) ([]byte, error) {
+	if secret, err := keychain.Get(name); err == nil {
+		return []byte(secret), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, encryptedHeader) {
+		return decryptToken(data[len(encryptedHeader):])
+	}
+	return data, nil
+}
+
+// storeSecret stores data in the OS keychain under name, or in an encrypted
+// file at path when the keychain is unavailable or too small for it
+func storeSecret(name, path string, data []byte) error {
+	if err := keychain.Set(name, string(data)); err == nil {
+		// Don't leave an older copy of the token on disk
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	// An entry left in the keychain would shadow the file
+	keychain.Delete(name)
 
-	return os.WriteFile(tokenFilePath(envName), data, 0600)
+	if err := os.MkdirAll(config.GetConfigDir(), 0700); err != nil {
+		return err
+	}
+	encrypted, err := encryptToken(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(encryptedHeader, encrypted...), 0600)
 }
 
-// DeleteToken removes a token file for an environment
+// DeleteToken removes the stored token of an environment
 func DeleteToken(envName string) error {
+	if config.ReadOnly() {
+		return nil
+	}
+	// Without a keychain there is nothing to delete there
+	keychain.Delete(envName)
 	path := tokenFilePath(envName)
 	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
 }
+
+// encryptToken seals a token with AES-GCM under the token file key, creating
+// the key on first use
+func encryptToken(data []byte) ([]byte, error) {
+	key, err := os.ReadFile(tokenKeyPath())
+	if os.IsNotExist(err) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(tokenKeyPath(), key, 0600); err != nil {
+			return nil, fmt.Errorf("write token key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("read token key: %w", err)
+	}
+
+	gcm, err := tokenCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decryptToken opens a token sealed by encryptToken
+func decryptToken(data []byte) ([]byte, error) {
+	key, err := os.ReadFile(tokenKeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("read token key: %w", err)
+	}
+	gcm, err := tokenCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("token file is truncated")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt token file: %w", err)
+	}
+	return plain, nil
+}
+
+// tokenCipher returns the AES-GCM cipher for a token file key
+func tokenCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
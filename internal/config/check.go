@@ -0,0 +1,171 @@
+package config
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Problem is one finding of Check
+type Problem struct {
+	File    string // config.json or the project binding file
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.File + ": " + p.Message
+}
+
+// CheckSummary counts what Check looked at
+type CheckSummary struct {
+	Environments int
+	Bindings     int
+	Problems     []Problem
+}
+
+// Check reads the global config and any project binding file without
+// modifying them and reports every problem it finds, instead of stopping at
+// the first like Load does. Environment URLs are checked with
+// ValidateEnvironmentURL and binding paths with ValidateBindingPath.
+func Check() (*CheckSummary, error) {
+	summary := &CheckSummary{}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err == nil {
+		cfg, err = decode(data)
+		if err != nil {
+			summary.Problems = append(summary.Problems, Problem{File: configPath, Message: err.Error()})
+			return summary, nil
+		}
+	}
+
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	problems = append(problems, cfg.warnings...)
+	switch cfg.WatchEvents {
+	case "", WatchEventsAll, WatchEventsWrite:
+	default:
+		report("watchEvents must be %q or %q, got %q", WatchEventsAll, WatchEventsWrite, cfg.WatchEvents)
+	}
+	if cfg.BackupRetention < 0 {
+		report("backupRetention must not be negative, got %d", cfg.BackupRetention)
+	}
+	if err := cfg.validateTimeouts(); err != nil {
+		report("%v", err)
+	}
+	if cfg.CABundle != "" {
+		if pem, err := os.ReadFile(cfg.CABundle); err != nil {
+			report("caBundle: %v", err)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			report("caBundle %s contains no PEM certificates", cfg.CABundle)
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.Environments))
+	for i, env := range cfg.Environments {
+		summary.Environments++
+		if strings.TrimSpace(env.Name) == "" {
+			report("environments[%d] has an empty name", i)
+		} else if names[env.Name] {
+			report("environment %q is defined more than once", env.Name)
+		}
+		names[env.Name] = true
+		if err := ValidateEnvironmentURL(env.URL); err != nil {
+			report("environment %q: %v", env.Name, err)
+		}
+		if err := env.validateAuth(); err != nil {
+			report("environment %q: %v", env.Name, err)
+		}
+		if err := env.validateCloud(); err != nil {
+			report("environment %q: %v", env.Name, err)
+		}
+		if err := env.validateWatchMode(); err != nil {
+			report("environment %q: %v", env.Name, err)
+		}
+		if err := validateIgnore(env.Ignore); err != nil {
+			report("environment %q: %v", env.Name, err)
+		}
+	}
+
+	if cfg.CurrentEnvironment != "" && !names[cfg.CurrentEnvironment] {
+		report("currentEnvironment refers to unknown environment %q", cfg.CurrentEnvironment)
+	}
+	for i, name := range cfg.PromotionChain {
+		if !names[name] {
+			report("promotionChain[%d] refers to unknown environment %q", i, name)
+		}
+	}
+
+	summary.Bindings += len(cfg.Bindings)
+	problems = append(problems, checkBindings(cfg.Bindings, names)...)
+	for i, d := range cfg.DirectoryBindings {
+		if !names[d.Environment] {
+			report("directoryBindings[%d] refers to unknown environment %q", i, d.Environment)
+		}
+		if err := d.validate(); err != nil {
+			report("directoryBindings[%d] %v", i, err)
+		} else if info, err := os.Stat(d.Root()); err != nil || !info.IsDir() {
+			report("directoryBindings[%d] localDir %s is not a folder", i, d.LocalDir)
+		}
+	}
+	for _, msg := range problems {
+		summary.Problems = append(summary.Problems, Problem{File: configPath, Message: msg})
+	}
+
+	if path := FindProjectFile("."); path != "" {
+		project := &Config{}
+		if err := project.loadProject(path); err != nil {
+			summary.Problems = append(summary.Problems, Problem{File: path, Message: err.Error()})
+		} else {
+			summary.Bindings += len(project.Bindings)
+			for _, msg := range checkBindings(project.Bindings, names) {
+				summary.Problems = append(summary.Problems, Problem{File: path, Message: msg})
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// checkBindings reports bindings with an unknown environment, a missing
+// resource ID, a duplicate resource or a local file that can't be read
+func checkBindings(bindings []Binding, envNames map[string]bool) []string {
+	var problems []string
+	seen := make(map[string]bool, len(bindings))
+	for i, b := range bindings {
+		label := fmt.Sprintf("bindings[%d] (%s)", i, b.WebResourceName)
+		if !envNames[b.Environment] {
+			problems = append(problems, fmt.Sprintf("%s refers to unknown environment %q", label, b.Environment))
+		}
+		if b.WebResourceID == "" {
+			problems = append(problems, fmt.Sprintf("%s has an empty webResourceId", label))
+		}
+		key := b.Environment + "/" + b.WebResourceID
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("%s binds the same resource in %s more than once", label, b.Environment))
+		}
+		seen[key] = true
+		for _, source := range b.Sources() {
+			if _, err := ValidateBindingPath(source); err != nil && b.BuildCommand == "" {
+				problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+			}
+		}
+		if b.BuildCommand != "" && len(b.WatchPaths) == 0 && b.AutoPublish {
+			problems = append(problems, fmt.Sprintf("%s has a buildCommand but no watchPaths, so it is not auto-published", label))
+		}
+		for _, path := range b.WatchPaths {
+			if _, err := ValidateBindingPath(path); err != nil {
+				problems = append(problems, fmt.Sprintf("%s watchPaths: %v", label, err))
+			}
+		}
+	}
+	return problems
+}
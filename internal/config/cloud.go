@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Azure clouds an environment can live in. An environment without a cloud
+// uses the one its URL belongs to.
+const (
+	CloudCommercial = "commercial"
+	CloudGCC        = "gcc"
+	CloudGCCHigh    = "gcchigh"
+	CloudDoD        = "dod"
+	CloudChina      = "china"
+)
+
+// cloud holds the sign-in host and organization URL pattern of a cloud
+type cloud struct {
+	loginHost string
+	url       *regexp.Regexp
+}
+
+var clouds = map[string]cloud{
+	CloudCommercial: {"https://login.microsoftonline.com", regexp.MustCompile(`^https://[a-zA-Z0-9-]+\.crm[0-9]*\.dynamics\.com$`)},
+	CloudGCC:        {"https://login.microsoftonline.com", regexp.MustCompile(`^https://[a-zA-Z0-9-]+\.crm9\.dynamics\.com$`)},
+	CloudGCCHigh:    {"https://login.microsoftonline.us", regexp.MustCompile(`^https://[a-zA-Z0-9-]+\.(crm[0-9]*\.dynamics\.us|crm\.microsoftdynamics\.us)$`)},
+	CloudDoD:        {"https://login.microsoftonline.us", regexp.MustCompile(`^https://[a-zA-Z0-9-]+\.crm\.appsplatform\.us$`)},
+	CloudChina:      {"https://login.chinacloudapi.cn", regexp.MustCompile(`^https://[a-zA-Z0-9-]+\.crm[0-9]*\.dynamics\.cn$`)},
+}
+
+// cloudOrder is the order URLs are matched in; GCC shares the commercial
+// sign-in, so it is never inferred
+var cloudOrder = []string{CloudCommercial, CloudGCCHigh, CloudDoD, CloudChina}
+
+// CloudForURL returns the cloud an organization URL belongs to, or "" if it
+// matches none
+func CloudForURL(url string) string {
+	for _, name := range cloudOrder {
+		if clouds[name].url.MatchString(url) {
+			return name
+		}
+	}
+	return ""
+}
+
+// LoginHost returns the Azure AD sign-in host for the environment's cloud
+func (e Environment) LoginHost() string {
+	name := e.Cloud
+	if name == "" {
+		name = CloudForURL(e.URL)
+	}
+	if c, ok := clouds[name]; ok {
+		return c.loginHost
+	}
+	return clouds[CloudCommercial].loginHost
+}
+
+// validateCloud checks that a configured cloud is known and that the URL
+// belongs to it
+func (e Environment) validateCloud() error {
+	if e.Cloud == "" {
+		return nil
+	}
+	c, ok := clouds[e.Cloud]
+	if !ok {
+		return fmt.Errorf("cloud must be one of %s, got %q", strings.Join([]string{CloudCommercial, CloudGCC, CloudGCCHigh, CloudDoD, CloudChina}, ", "), e.Cloud)
+	}
+	if !c.url.MatchString(e.URL) {
+		return fmt.Errorf("url %s is not a %s URL", e.URL, e.Cloud)
+	}
+	return nil
+}
@@ -1,48 +1,368 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
+	"time"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/keychain"
 )
 
 // Environment represents a Dynamics 365 environment
 type Environment struct {
-	Name           string `json:"name"`
-	URL            string `json:"url"`
-	TokenOutputDir string `json:"tokenOutputDir,omitempty"`
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	TokenOutputDir  string            `json:"tokenOutputDir,omitempty"`
+	PublisherPrefix string            `json:"publisherPrefix,omitempty"`
+	Tokens          map[string]string `json:"tokens,omitempty"`
+	PrePublish      []string          `json:"prePublish,omitempty"`
+	LastPublished   string            `json:"lastPublished,omitempty"`   // web resource ID of the most recent publish
+	Protected       bool              `json:"protected,omitempty"`       // snapshot server content before every publish
+	RequireConfirm  bool              `json:"requireConfirm,omitempty"`  // ask before manual publishes; auto-publish must be armed each session
+	AuthMode        string            `json:"authMode,omitempty"`        // empty for interactive sign-in, or AuthModeClientCredentials
+	TenantID        string            `json:"tenantId,omitempty"`        // empty signs in through the common endpoint
+	ClientID        string            `json:"clientId,omitempty"`        // app registration to sign in with; empty uses the default client
+	Cloud           string            `json:"cloud,omitempty"`           // empty uses the cloud of the URL
+	ExpandedFolders []string          `json:"expandedFolders,omitempty"` // resource list folders left open
+	WatchMode       string            `json:"watchMode,omitempty"`       // empty detects network file systems, or WatchModeEvents or WatchModePoll
+	Ignore          []string          `json:"ignore,omitempty"`          // gitignore-style patterns skipped when scanning folders, on top of DefaultIgnore
+	DefaultSolution string            `json:"defaultSolution,omitempty"` // unique name of the solution new resources are added to
+}
+
+// AuthModeClientCredentials signs in as a service principal with a client
+// secret instead of a user, for unattended publishing
+const AuthModeClientCredentials = "clientCredentials"
+
+// AuthModeDeviceCode signs in a user with a code entered on another device,
+// for sessions that can't open a browser such as SSH
+const AuthModeDeviceCode = "deviceCode"
+
+// Watch modes of an environment; empty uses file events, but polls files on
+// network file systems
+const (
+	WatchModeEvents = "events" // always use file events
+	WatchModePoll   = "poll"   // check every file for changes on an interval
+)
+
+// UsesClientCredentials reports whether the environment signs in as a service principal
+func (e Environment) UsesClientCredentials() bool {
+	return e.AuthMode == AuthModeClientCredentials
+}
+
+// validateAuth checks that the sign-in settings of an environment are complete
+func (e Environment) validateAuth() error {
+	switch e.AuthMode {
+	case "", AuthModeDeviceCode:
+	case AuthModeClientCredentials:
+		if strings.TrimSpace(e.TenantID) == "" || strings.TrimSpace(e.ClientID) == "" {
+			return fmt.Errorf("authMode %q needs tenantId and clientId", AuthModeClientCredentials)
+		}
+	default:
+		return fmt.Errorf("authMode must be empty, %q or %q, got %q", AuthModeClientCredentials, AuthModeDeviceCode, e.AuthMode)
+	}
+	return nil
+}
+
+// validateWatchMode checks that the watch mode of an environment is known
+func (e Environment) validateWatchMode() error {
+	switch e.WatchMode {
+	case "", WatchModeEvents, WatchModePoll:
+		return nil
+	}
+	return fmt.Errorf("watchMode must be empty, %q or %q, got %q", WatchModeEvents, WatchModePoll, e.WatchMode)
 }
 
 // Binding maps a local file to a web resource
 type Binding struct {
-	Environment      string `json:"environment"`
-	LocalPath        string `json:"localPath"`
-	WebResourceName  string `json:"webResourceName"`
-	WebResourceID    string `json:"webResourceId"`
-	LastKnownVersion string `json:"lastKnownVersion"`
-	AutoPublish      bool   `json:"autoPublish"`
+	Environment       string `json:"environment"`
+	LocalPath         string `json:"localPath"`
+	WebResourceName   string `json:"webResourceName"`
+	WebResourceID     string `json:"webResourceId"`
+	LastKnownVersion  string `json:"lastKnownVersion"`
+	AutoPublish       bool   `json:"autoPublish"`
+	LastPublishedHash string `json:"lastPublishedHash,omitempty"`
+	ServerVersion     int64  `json:"serverVersion,omitempty"` // server versionnumber when last bound or published
+	Solution          string `json:"solution,omitempty"`      // unique name of the solution the resource was added to
+	DisplayName       string `json:"displayName,omitempty"`   // display name given when the resource was created or renamed
+	Label             string `json:"label,omitempty"`         // freeform note such as its purpose or a ticket number
+	Project           bool   `json:"-"`                       // defined in the project binding file rather than the global config
+	// LocalPaths, if set, lists files whose contents are joined with newlines,
+	// in order, into the web resource. LocalPath is then the first of them.
+	LocalPaths []string `json:"localPaths,omitempty"`
+	// BuildCommand runs in the folder of LocalPath before the file is read
+	// for publishing, e.g. to transpile or bundle it
+	BuildCommand string `json:"buildCommand,omitempty"`
+	// WatchPaths, if set, are watched for auto-publish instead of the
+	// sources, e.g. the inputs of a build whose output is bound
+	WatchPaths []string `json:"watchPaths,omitempty"`
+}
+
+// Sources returns the local files published to the binding's web resource
+func (b Binding) Sources() []string {
+	if len(b.LocalPaths) > 0 {
+		return b.LocalPaths
+	}
+	return []string{b.LocalPath}
+}
+
+// WatchedFiles returns the files whose changes auto-publish the binding. A
+// binding with a build command but no watchPaths watches nothing, as its
+// sources are the build output every build rewrites.
+func (b Binding) WatchedFiles() []string {
+	if len(b.WatchPaths) > 0 {
+		return b.WatchPaths
+	}
+	if b.BuildCommand != "" {
+		return nil
+	}
+	return b.Sources()
+}
+
+// Watches reports whether an absolute path is one of the binding's watched files
+func (b Binding) Watches(path string) bool {
+	for _, file := range b.WatchedFiles() {
+		if abs, err := filepath.Abs(file); err == nil && abs == path {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize fills LocalPath from LocalPaths for bindings that only list the latter
+func (b *Binding) normalize() {
+	if b.LocalPath == "" && len(b.LocalPaths) > 0 {
+		b.LocalPath = b.LocalPaths[0]
+	}
 }
 
 // Config represents the application configuration
 type Config struct {
+	// SchemaVersion is the version of the file format; see CurrentSchemaVersion
+	SchemaVersion      int           `json:"schemaVersion"`
 	CurrentEnvironment string        `json:"currentEnvironment"`
 	Environments       []Environment `json:"environments"`
 	PublisherPrefix    string        `json:"publisherPrefix"`
 	Bindings           []Binding     `json:"bindings"`
+	// DirectoryBindings publish every matching file below a folder
+	DirectoryBindings []DirectoryBinding `json:"directoryBindings,omitempty"`
+	ViewMode          string             `json:"viewMode,omitempty"`
+	WatchEvents       string             `json:"watchEvents,omitempty"`
+	// TreeSeparators are the characters that split resource names into the
+	// folders of the tree view. Empty splits on "/" only.
+	TreeSeparators string `json:"treeSeparators,omitempty"`
+	// ConnectivityCheckSeconds is the interval between connectivity checks.
+	// Zero uses DefaultConnectivityCheckSeconds; a negative value disables them.
+	ConnectivityCheckSeconds int `json:"connectivityCheckSeconds,omitempty"`
+	// PingEnvironmentsOnStartup pings every environment with a valid token at
+	// startup instead of only checking the cached tokens
+	PingEnvironmentsOnStartup bool `json:"pingEnvironmentsOnStartup,omitempty"`
+	// PromotionChain lists environment names in deployment order, e.g. Dev,
+	// Test, Prod. Promoting from one environment publishes to the next.
+	PromotionChain []string `json:"promotionChain,omitempty"`
+	// BackupDir is where snapshots of protected environments are kept.
+	// Empty uses a backups folder in the config directory.
+	BackupDir string `json:"backupDir,omitempty"`
+	// BackupRetention is how many snapshots are kept per resource. Zero uses
+	// DefaultBackupRetention.
+	BackupRetention int `json:"backupRetention,omitempty"`
+	// MaxRetries is how often a throttled (429) or failed (5xx) API request
+	// is retried. Zero uses DefaultMaxRetries; a negative value disables retries.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// CABundle is a PEM file of extra certificates to trust, e.g. the root
+	// of a proxy that inspects TLS
+	CABundle string `json:"caBundle,omitempty"`
+	// ConnectTimeoutSeconds limits connecting to a server, RequestTimeoutSeconds
+	// each read such as loading the resource list, and PublishTimeoutSeconds
+	// uploading and publishing a web resource. Zero uses the defaults.
+	ConnectTimeoutSeconds int `json:"connectTimeoutSeconds,omitempty"`
+	RequestTimeoutSeconds int `json:"requestTimeoutSeconds,omitempty"`
+	PublishTimeoutSeconds int `json:"publishTimeoutSeconds,omitempty"`
+	// Keymap remaps the keys of the TUI, per screen ("environments" or
+	// "list") from action name to key, e.g. {"list": {"publish": "P"}}.
+	// Actions left out keep their default keys.
+	Keymap map[string]map[string]string `json:"keymap,omitempty"`
+
+	projectPath string   // project binding file merged into Bindings, if any
+	warnings    []string // problems found while loading that don't stop the config being used
+	newer       bool     // written by a newer version; saving would drop what it doesn't know
+}
+
+// ErrNewerConfig is returned by Save for a config written by a newer version
+var ErrNewerConfig = errors.New("config.json was written by a newer version of d365tui and is not saved; upgrade to change it")
+
+// CurrentSchemaVersion is the config file format this version reads and
+// writes. Bump it and append to migrations when existing files need upgrading.
+const CurrentSchemaVersion = 1
+
+// migrations upgrade a config one schema version at a time: migrations[i]
+// upgrades version i to i+1
+var migrations = []func(*Config){
+	// 0 → 1: environments created before per-environment prefixes inherit the global one
+	func(c *Config) {
+		for i := range c.Environments {
+			if c.Environments[i].PublisherPrefix == "" {
+				c.Environments[i].PublisherPrefix = c.PublisherPrefix
+			}
+		}
+	},
+}
+
+// migrate upgrades a config written by an older version and reports whether
+// anything was upgraded
+func (c *Config) migrate() bool {
+	if c.SchemaVersion >= CurrentSchemaVersion {
+		return false
+	}
+	for v := c.SchemaVersion; v < CurrentSchemaVersion; v++ {
+		migrations[v](c)
+	}
+	c.SchemaVersion = CurrentSchemaVersion
+	return true
+}
+
+// Warnings returns the problems found while loading that didn't stop the
+// config being used, such as a file written by a newer version
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// DefaultConnectivityCheckSeconds is the connectivity check interval used when none is configured
+const DefaultConnectivityCheckSeconds = 30
+
+// ConnectivityCheckInterval returns the connectivity check interval, or zero when disabled
+func (c *Config) ConnectivityCheckInterval() time.Duration {
+	switch {
+	case c.ConnectivityCheckSeconds < 0:
+		return 0
+	case c.ConnectivityCheckSeconds == 0:
+		return DefaultConnectivityCheckSeconds * time.Second
+	default:
+		return time.Duration(c.ConnectivityCheckSeconds) * time.Second
+	}
+}
+
+// DefaultBackupRetention is the number of snapshots kept per resource when none is configured
+const DefaultBackupRetention = 20
+
+// BackupRoot returns the directory snapshots are written to
+func (c *Config) BackupRoot() string {
+	if dir := strings.TrimSpace(c.BackupDir); dir != "" {
+		return dir
+	}
+	return filepath.Join(configDir, "backups")
+}
+
+// BackupLimit returns how many snapshots are kept per resource
+func (c *Config) BackupLimit() int {
+	if c.BackupRetention > 0 {
+		return c.BackupRetention
+	}
+	return DefaultBackupRetention
+}
+
+// DefaultMaxRetries is the number of API retries used when none is configured
+const DefaultMaxRetries = 3
+
+// RetryLimit returns how often a failed API request is retried
+func (c *Config) RetryLimit() int {
+	switch {
+	case c.MaxRetries < 0:
+		return 0
+	case c.MaxRetries == 0:
+		return DefaultMaxRetries
+	default:
+		return c.MaxRetries
+	}
+}
+
+// Timeouts used when none are configured
+const (
+	DefaultConnectTimeoutSeconds = 10
+	DefaultRequestTimeoutSeconds = 30
+	DefaultPublishTimeoutSeconds = 120
+)
+
+// ConnectTimeout returns how long connecting to a server may take
+func (c *Config) ConnectTimeout() time.Duration {
+	return seconds(c.ConnectTimeoutSeconds, DefaultConnectTimeoutSeconds)
+}
+
+// RequestTimeout returns how long a read such as listing resources may take
+func (c *Config) RequestTimeout() time.Duration {
+	return seconds(c.RequestTimeoutSeconds, DefaultRequestTimeoutSeconds)
+}
+
+// PublishTimeout returns how long uploading and publishing a resource may take
+func (c *Config) PublishTimeout() time.Duration {
+	return seconds(c.PublishTimeoutSeconds, DefaultPublishTimeoutSeconds)
+}
+
+// seconds converts a configured number of seconds, falling back when unset
+func seconds(n, fallback int) time.Duration {
+	if n <= 0 {
+		n = fallback
+	}
+	return time.Duration(n) * time.Second
+}
+
+// View modes for the resource list
+const (
+	ViewModeTree = "tree"
+	ViewModeFlat = "flat"
+)
+
+// NameSeparators returns the characters that split resource names into tree folders
+func (c *Config) NameSeparators() string {
+	if c.TreeSeparators == "" {
+		return "/"
+	}
+	return c.TreeSeparators
+}
+
+// Watch event sets that trigger an auto-publish. An empty value means
+// WatchEventsAll.
+const (
+	WatchEventsAll   = "all"   // write, create, rename and remove (atomic saves)
+	WatchEventsWrite = "write" // in-place writes only
+)
+
+// WatchWriteOnly reports whether auto-publish should only react to writes
+func (c *Config) WatchWriteOnly() bool {
+	return c.WatchEvents == WatchEventsWrite
 }
 
 var configDir string
 var configPath string
 
+// readOnly keeps every change in memory instead of writing it to disk
+var readOnly bool
+
+// ConfigDirEnv is the environment variable that overrides the configuration
+// directory, e.g. for a per-project config or isolated instances
+const ConfigDirEnv = "D365TUI_CONFIG_DIR"
+
 func init() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		home = "."
+	if dir := strings.TrimSpace(os.Getenv(ConfigDirEnv)); dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		configDir = dir
+		// Keep the keychain entries of this instance apart from the default one
+		keychain.SetNamespace(dir)
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		configDir = filepath.Join(home, ".d365tui")
 	}
-	configDir = filepath.Join(home, ".d365tui")
 	configPath = filepath.Join(configDir, "config.json")
 }
 
@@ -51,16 +371,63 @@ func GetConfigDir() string {
 	return configDir
 }
 
-// Load reads the config from disk or returns defaults
+// TokenPath returns the path of the cached token file for an environment
+func TokenPath(envName string) string {
+	safeName := strings.ReplaceAll(envName, "/", "_")
+	safeName = strings.ReplaceAll(safeName, "\\", "_")
+	return filepath.Join(configDir, fmt.Sprintf("token-%s.json", safeName))
+}
+
+// ResourceCachePath returns the path of the cached web resource list of an
+// environment
+func ResourceCachePath(envName string) string {
+	safeName := strings.ReplaceAll(envName, "/", "_")
+	safeName = strings.ReplaceAll(safeName, "\\", "_")
+	return filepath.Join(configDir, fmt.Sprintf("resources-%s.json", safeName))
+}
+
+// SetReadOnly switches the package into read-only mode, in which Save and
+// token writes are skipped and nothing is created in the config directory
+func SetReadOnly(on bool) {
+	readOnly = on
+}
+
+// ReadOnly reports whether the application runs in read-only mode
+func ReadOnly() bool {
+	return readOnly
+}
+
+// Load reads the config from disk or returns defaults, then merges the
+// bindings of a project file found from the working directory. If only the
+// project file is invalid, the global config is returned along with the error.
 func Load() (*Config, error) {
-	if err := os.MkdirAll(configDir, 0700); err != nil {
+	cfg, err := loadGlobal()
+	if err != nil {
 		return nil, err
 	}
 
+	if path := FindProjectFile("."); path != "" {
+		if err := cfg.loadProject(path); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadGlobal reads the global config file or returns defaults
+func loadGlobal() (*Config, error) {
+	if !readOnly {
+		if err := os.MkdirAll(configDir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &Config{
+				SchemaVersion:      CurrentSchemaVersion,
 				CurrentEnvironment: "",
 				Environments:       []Environment{},
 				PublisherPrefix:    "new",
@@ -70,46 +437,323 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	cfg, err := parse(data)
+	if err != nil {
+		if !readOnly {
+			// Keep the broken file so a later save doesn't silently discard it
+			backup := fmt.Sprintf("%s.invalid-%s", configPath, time.Now().Format("20060102-150405"))
+			if writeErr := os.WriteFile(backup, data, 0600); writeErr != nil {
+				return nil, fmt.Errorf("%w (backup failed: %v)", err, writeErr)
+			}
+			err = fmt.Errorf("%w; backed up to %s", err, backup)
+		}
+		// Fall back to the copy of the last good file kept by Save
+		previous, bakErr := loadBackup()
+		if bakErr != nil {
+			return nil, err
+		}
+		previous.warnings = append(previous.warnings, fmt.Sprintf("%v; loaded %s instead", err, BackupPath()))
+		cfg = previous
+	}
+
+	// Upgrade and rewrite a file written by an older version
+	if cfg.migrate() && !readOnly {
+		if err := cfg.Save(); err != nil {
+			cfg.warnings = append(cfg.warnings, fmt.Sprintf("config.json was upgraded but could not be saved: %v", err))
+		}
+	}
+
+	return cfg, nil
+}
+
+// BackupPath returns the path of the copy of the previous good config.json
+func BackupPath() string {
+	return configPath + ".bak"
+}
+
+// loadBackup reads the copy of the previous good config.json
+func loadBackup() (*Config, error) {
+	data, err := os.ReadFile(BackupPath())
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+// parse decodes and validates config JSON
+func parse(data []byte) (*Config, error) {
+	cfg, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config.json is invalid: %w", err)
+	}
+	cfg.warnings = append(cfg.warnings, cfg.lint()...)
+
+	return cfg, nil
+}
+
+// decode decodes config JSON, reporting the line and column of syntax and
+// type errors. Settings this version doesn't know are ignored with a warning,
+// so a typo doesn't make the whole file unusable.
+func decode(data []byte) (*Config, error) {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	json.Unmarshal(data, &header) // syntax errors are reported by the decode below
+	newer := header.SchemaVersion > CurrentSchemaVersion
+
 	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return &Config{
-			CurrentEnvironment: "",
-			Environments:       []Environment{},
-			PublisherPrefix:    "new",
-			Bindings:           []Binding{},
-		}, nil
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&cfg); err != nil {
+		offset := dec.InputOffset()
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &typeErr):
+			offset = typeErr.Offset
+			err = fmt.Errorf("field %q must be %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		line, col := position(data, offset)
+		return nil, fmt.Errorf("config.json is invalid at line %d, column %d: %v", line, col, err)
 	}
 
+	for i := range cfg.Bindings {
+		cfg.Bindings[i].normalize()
+	}
+	if !newer {
+		// Decode again only to find a setting this version doesn't know
+		strict := json.NewDecoder(bytes.NewReader(data))
+		strict.DisallowUnknownFields()
+		if err := strict.Decode(&Config{}); err != nil {
+			line, col := position(data, strict.InputOffset())
+			cfg.warnings = append(cfg.warnings, fmt.Sprintf("config.json line %d, column %d: %v; it is ignored", line, col, err))
+		}
+	}
+	if newer {
+		cfg.newer = true
+		cfg.warnings = append(cfg.warnings, fmt.Sprintf(
+			"config.json has schema version %d but this version supports %d, so it is not saved and changes last until you quit; upgrade d365tui",
+			cfg.SchemaVersion, CurrentSchemaVersion))
+	}
 	return &cfg, nil
 }
 
-// Save writes the config to disk
+// validateTimeouts rejects negative timeouts
+func (c *Config) validateTimeouts() error {
+	for _, t := range []struct {
+		name  string
+		value int
+	}{
+		{"connectTimeoutSeconds", c.ConnectTimeoutSeconds},
+		{"requestTimeoutSeconds", c.RequestTimeoutSeconds},
+		{"publishTimeoutSeconds", c.PublishTimeoutSeconds},
+	} {
+		if t.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", t.name, t.value)
+		}
+	}
+	return nil
+}
+
+// validate checks the rules without which environments can't be told apart
+// or reached. Everything else is reported by lint.
+func (c *Config) validate() error {
+	names := make(map[string]bool, len(c.Environments))
+	for i, env := range c.Environments {
+		if strings.TrimSpace(env.Name) == "" {
+			return fmt.Errorf("environments[%d] has an empty name", i)
+		}
+		if names[env.Name] {
+			return fmt.Errorf("environment %q is defined more than once", env.Name)
+		}
+		names[env.Name] = true
+		if strings.TrimSpace(env.URL) == "" {
+			return fmt.Errorf("environment %q has an empty url", env.Name)
+		}
+	}
+	return nil
+}
+
+// lint reports settings that can't be used and references to environments
+// that don't exist. They don't stop the config loading: such settings fall
+// back to their defaults, and such references are ignored.
+func (c *Config) lint() []string {
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	switch c.WatchEvents {
+	case "", WatchEventsAll, WatchEventsWrite:
+	default:
+		warn("watchEvents must be %q or %q, got %q", WatchEventsAll, WatchEventsWrite, c.WatchEvents)
+	}
+	if c.BackupRetention < 0 {
+		warn("backupRetention must not be negative, got %d", c.BackupRetention)
+	}
+	if err := c.validateTimeouts(); err != nil {
+		warn("%v", err)
+	}
+
+	names := make(map[string]bool, len(c.Environments))
+	for _, env := range c.Environments {
+		names[env.Name] = true
+		for _, err := range []error{env.validateAuth(), env.validateCloud(), env.validateWatchMode(), validateIgnore(env.Ignore)} {
+			if err != nil {
+				warn("environment %q: %v", env.Name, err)
+			}
+		}
+	}
+
+	for i, name := range c.PromotionChain {
+		if !names[name] {
+			warn("promotionChain[%d] refers to unknown environment %q", i, name)
+		}
+	}
+	for i, b := range c.Bindings {
+		if !names[b.Environment] {
+			warn("bindings[%d] refers to unknown environment %q", i, b.Environment)
+		}
+		if b.WebResourceID == "" {
+			warn("bindings[%d] has an empty webResourceId", i)
+		}
+		if b.BuildCommand != "" && len(b.WatchPaths) == 0 && b.AutoPublish {
+			warn("bindings[%d] has a buildCommand but no watchPaths, so it is not auto-published", i)
+		}
+	}
+	for i, d := range c.DirectoryBindings {
+		if !names[d.Environment] {
+			warn("directoryBindings[%d] refers to unknown environment %q", i, d.Environment)
+		}
+		if err := d.validate(); err != nil {
+			warn("directoryBindings[%d] %v; it is not used", i, err)
+		}
+	}
+	return warnings
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, col := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// Save writes the config to disk. Project bindings go to the project file.
+// In read-only mode it does nothing. A file written by a newer version is
+// never overwritten, as that would drop the settings this version doesn't
+// know and lower its schemaVersion.
 func (c *Config) Save() error {
+	if readOnly {
+		return nil
+	}
+	if c.newer {
+		return ErrNewerConfig
+	}
 	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	global := *c
+	global.SchemaVersion = CurrentSchemaVersion
+	global.Bindings = make([]Binding, 0, len(c.Bindings))
+	for _, b := range c.Bindings {
+		if !b.Project {
+			global.Bindings = append(global.Bindings, b)
+		}
+	}
+
+	data, err := json.MarshalIndent(global, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0600)
+	// Keep the file being replaced as the backup, unless it is broken and
+	// the backup is the last good version
+	if previous, err := os.ReadFile(configPath); err == nil && !bytes.Equal(previous, data) {
+		if _, err := parse(previous); err == nil {
+			if err := writeFileAtomic(BackupPath(), previous, 0600); err != nil {
+				return fmt.Errorf("back up config: %w", err)
+			}
+		}
+	}
+	if err := writeFileAtomic(configPath, data, 0600); err != nil {
+		return err
+	}
+
+	if c.projectPath != "" {
+		return c.saveProject()
+	}
+	return nil
+}
+
+// writeFileAtomic writes a file through a temporary file in the same folder,
+// so a crash mid-write leaves either the old or the new content
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SetExpandedFolders persists the resource list folders left open in an environment
+func (c *Config) SetExpandedFolders(envName string, paths []string) error {
+	for i := range c.Environments {
+		if c.Environments[i].Name == envName {
+			c.Environments[i].ExpandedFolders = paths
+			return c.Save()
+		}
+	}
+	return errors.New("environment not found")
+}
+
+// SetViewMode persists the resource list view mode
+func (c *Config) SetViewMode(mode string) error {
+	c.ViewMode = mode
+	return c.Save()
 }
 
-// ValidateEnvironmentURL checks if the URL is a valid Dynamics 365 URL
+// ValidateEnvironmentURL checks if the URL is a valid Dynamics 365 URL in
+// any of the supported clouds
 func ValidateEnvironmentURL(url string) error {
 	if !strings.HasPrefix(url, "https://") {
 		return errors.New("URL must start with https://")
 	}
 
-	pattern := `^https://[a-zA-Z0-9-]+\.crm[0-9]*\.dynamics\.com$`
-	matched, err := regexp.MatchString(pattern, url)
-	if err != nil {
-		return err
-	}
-	if !matched {
-		return errors.New("URL must be a valid Dynamics 365 URL (e.g., https://myorg.crm.dynamics.com)")
+	if CloudForURL(url) == "" {
+		return errors.New("URL must be a valid Dynamics 365 URL (e.g., https://myorg.crm.dynamics.com or https://myorg.crm.microsoftdynamics.us)")
 	}
 
 	return nil
@@ -134,7 +778,7 @@ func (c *Config) AddEnvironment(name, url string) error {
 		return err
 	}
 
-	c.Environments = append(c.Environments, Environment{Name: name, URL: url})
+	c.Environments = append(c.Environments, Environment{Name: name, URL: url, PublisherPrefix: c.PublisherPrefix})
 	return c.Save()
 }
 
@@ -163,6 +807,13 @@ func (c *Config) UpdateEnvironment(oldName, newName, newURL string) error {
 		if env.Name == oldName {
 			c.Environments[i].Name = newName
 			c.Environments[i].URL = newURL
+			removeResourceCache(oldName)
+			// A token of the old org must not be sent to the new one
+			if newURL != env.URL {
+				if err := removeToken(oldName); err != nil {
+					return err
+				}
+			}
 
 			if oldName != newName {
 				for j, b := range c.Bindings {
@@ -170,9 +821,22 @@ func (c *Config) UpdateEnvironment(oldName, newName, newURL string) error {
 						c.Bindings[j].Environment = newName
 					}
 				}
+				for j, d := range c.DirectoryBindings {
+					if d.Environment == oldName {
+						c.DirectoryBindings[j].Environment = newName
+					}
+				}
 				if c.CurrentEnvironment == oldName {
 					c.CurrentEnvironment = newName
 				}
+				for k, name := range c.PromotionChain {
+					if name == oldName {
+						c.PromotionChain[k] = newName
+					}
+				}
+				if err := moveToken(oldName, newName); err != nil {
+					return err
+				}
 			}
 
 			return c.Save()
@@ -194,6 +858,49 @@ func (c *Config) UpdateEnvironmentTokenOutputDir(name, dir string) error {
 	return errors.New("environment not found")
 }
 
+// ApplyTokens replaces ${NAME} placeholders in content with the environment's
+// token values and returns the result along with the number of replacements made
+func (e *Environment) ApplyTokens(content []byte) ([]byte, int) {
+	if e == nil || len(e.Tokens) == 0 {
+		return content, 0
+	}
+
+	text := string(content)
+	count := 0
+	for name, value := range e.Tokens {
+		placeholder := "${" + name + "}"
+		n := strings.Count(text, placeholder)
+		if n == 0 {
+			continue
+		}
+		text = strings.ReplaceAll(text, placeholder, value)
+		count += n
+	}
+
+	return []byte(text), count
+}
+
+// UpdateEnvironmentPublisherPrefix sets the publisher prefix used for an environment.
+func (c *Config) UpdateEnvironmentPublisherPrefix(name, prefix string) error {
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "_")
+	for i := range c.Environments {
+		if c.Environments[i].Name == name {
+			c.Environments[i].PublisherPrefix = prefix
+			return c.Save()
+		}
+	}
+
+	return errors.New("environment not found")
+}
+
+// PublisherPrefixFor returns the environment's publisher prefix, falling back to the global default
+func (c *Config) PublisherPrefixFor(envName string) string {
+	if env := c.GetEnvironment(envName); env != nil && env.PublisherPrefix != "" {
+		return env.PublisherPrefix
+	}
+	return c.PublisherPrefix
+}
+
 // DeleteEnvironment removes an environment and its bindings
 func (c *Config) DeleteEnvironment(name string) error {
 	found := false
@@ -220,13 +927,119 @@ func (c *Config) DeleteEnvironment(name string) error {
 	}
 	c.Bindings = newBindings
 
+	dirs := c.DirectoryBindings[:0]
+	for _, d := range c.DirectoryBindings {
+		if d.Environment != name {
+			dirs = append(dirs, d)
+		}
+	}
+	c.DirectoryBindings = dirs
+
 	if c.CurrentEnvironment == name {
 		c.CurrentEnvironment = ""
 	}
 
+	chain := c.PromotionChain[:0]
+	for _, n := range c.PromotionChain {
+		if n != name {
+			chain = append(chain, n)
+		}
+	}
+	c.PromotionChain = chain
+
+	if err := removeToken(name); err != nil {
+		return err
+	}
+	removeResourceCache(name)
+
 	return c.Save()
 }
 
+// moveToken renames the cached token of a renamed environment. Keychain
+// errors are ignored: without a keychain the token file is the only copy.
+func moveToken(oldName, newName string) error {
+	if readOnly {
+		return nil
+	}
+	keychain.Move(oldName, newName)
+	err := os.Rename(TokenPath(oldName), TokenPath(newName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rename token file: %w", err)
+	}
+	return nil
+}
+
+// removeToken deletes the cached token of a deleted environment
+func removeToken(name string) error {
+	if readOnly {
+		return nil
+	}
+	keychain.Delete(name)
+	if err := os.Remove(TokenPath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove token file: %w", err)
+	}
+	return nil
+}
+
+// removeResourceCache deletes the cached resource list of an environment
+// that was removed or may now point at another org
+func removeResourceCache(name string) {
+	if !readOnly {
+		os.Remove(ResourceCachePath(name))
+	}
+}
+
+// OrphanedTokens returns the token files in the config directory and the
+// keychain entries that no configured environment uses. The keychain is
+// skipped if it is unavailable.
+func (c *Config) OrphanedTokens() (files, entries []string, err error) {
+	paths, err := filepath.Glob(filepath.Join(configDir, "token-*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usedPaths := make(map[string]bool, len(c.Environments))
+	for _, env := range c.Environments {
+		usedPaths[TokenPath(env.Name)] = true
+	}
+	for _, path := range paths {
+		if !usedPaths[path] {
+			files = append(files, path)
+		}
+	}
+
+	names, _ := keychain.Names()
+	for _, name := range names {
+		// MSAL caches are kept per app registration, not per environment
+		if c.GetEnvironment(name) == nil && !strings.HasPrefix(name, "msal:") {
+			entries = append(entries, name)
+		}
+	}
+	return files, entries, nil
+}
+
+// RemoveOrphanedTokens deletes the given token files and keychain entries and
+// returns how many were removed
+func RemoveOrphanedTokens(files, entries []string) (int, error) {
+	if readOnly {
+		return 0, nil
+	}
+	removed := 0
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	for _, name := range entries {
+		if err := keychain.Delete(name); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
 // GetEnvironment returns the environment by name
 func (c *Config) GetEnvironment(name string) *Environment {
 	for i := range c.Environments {
@@ -237,6 +1050,24 @@ func (c *Config) GetEnvironment(name string) *Environment {
 	return nil
 }
 
+// NextEnvironment returns the environment after envName in the promotion
+// chain, or an empty string if there is none. Names of environments that
+// don't exist are skipped.
+func (c *Config) NextEnvironment(envName string) string {
+	var chain []string
+	for _, name := range c.PromotionChain {
+		if c.GetEnvironment(name) != nil {
+			chain = append(chain, name)
+		}
+	}
+	for i, name := range chain {
+		if name == envName && i+1 < len(chain) {
+			return chain[i+1]
+		}
+	}
+	return ""
+}
+
 // GetBindingsForEnvironment returns bindings for a specific environment
 func (c *Config) GetBindingsForEnvironment(envName string) []Binding {
 	var result []Binding
@@ -248,8 +1079,43 @@ func (c *Config) GetBindingsForEnvironment(envName string) []Binding {
 	return result
 }
 
+// ValidateBindingPath checks that a path points to an existing regular file
+// and returns its absolute form
+func ValidateBindingPath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", errors.New("file path cannot be empty")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file does not exist: %s", absPath)
+		}
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("path is a directory: %s", absPath)
+	}
+
+	f, err := os.Open(absPath)
+	if err != nil {
+		return "", fmt.Errorf("file is not readable: %s", absPath)
+	}
+	f.Close()
+
+	return absPath, nil
+}
+
 // AddBinding adds or updates a binding
 func (c *Config) AddBinding(binding Binding) error {
+	// Files inside the project belong in the project binding file
+	binding.Project = c.inProject(binding.LocalPath)
 	for i, b := range c.Bindings {
 		if b.Environment == binding.Environment && b.WebResourceID == binding.WebResourceID {
 			c.Bindings[i] = binding
@@ -281,6 +1147,95 @@ func (c *Config) UpdateBindingVersion(envName, webResourceID, version string) er
 	return errors.New("binding not found")
 }
 
+// UpdateBindingLabel sets the freeform label of a binding
+func (c *Config) UpdateBindingLabel(envName, webResourceID, label string) error {
+	for i := range c.Bindings {
+		if c.Bindings[i].Environment == envName && c.Bindings[i].WebResourceID == webResourceID {
+			c.Bindings[i].Label = strings.TrimSpace(label)
+			return c.Save()
+		}
+	}
+	return errors.New("binding not found")
+}
+
+// SetBindingSolution records the solution a bound resource was added to
+func (c *Config) SetBindingSolution(envName, webResourceID, solution string) error {
+	for i := range c.Bindings {
+		if c.Bindings[i].Environment == envName && c.Bindings[i].WebResourceID == webResourceID {
+			c.Bindings[i].Solution = solution
+			return c.Save()
+		}
+	}
+	return errors.New("binding not found")
+}
+
+// SetDefaultSolution remembers the solution last picked in an environment
+func (c *Config) SetDefaultSolution(envName, solution string) error {
+	env := c.GetEnvironment(envName)
+	if env == nil {
+		return errors.New("environment not found")
+	}
+	env.DefaultSolution = solution
+	return c.Save()
+}
+
+// RecordPublish stores the version and content hash of a successful publish
+func (c *Config) RecordPublish(envName, webResourceID, version, hash string) error {
+	for i := range c.Bindings {
+		if c.Bindings[i].Environment == envName && c.Bindings[i].WebResourceID == webResourceID {
+			c.Bindings[i].LastKnownVersion = version
+			c.Bindings[i].LastPublishedHash = hash
+			if env := c.GetEnvironment(envName); env != nil {
+				env.LastPublished = webResourceID
+			}
+			return c.Save()
+		}
+	}
+	return errors.New("binding not found")
+}
+
+// RecordServerVersion stores the server version of a bound resource, so a
+// later change made outside this tool can be detected
+func (c *Config) RecordServerVersion(envName, webResourceID string, version int64) error {
+	for i := range c.Bindings {
+		if c.Bindings[i].Environment == envName && c.Bindings[i].WebResourceID == webResourceID {
+			c.Bindings[i].ServerVersion = version
+			return c.Save()
+		}
+	}
+	return errors.New("binding not found")
+}
+
+// LastPublishedBinding returns the binding most recently published in an
+// environment, or nil if there is none or it has since been unbound
+func (c *Config) LastPublishedBinding(envName string) *Binding {
+	env := c.GetEnvironment(envName)
+	if env == nil || env.LastPublished == "" {
+		return nil
+	}
+	return c.GetBinding(envName, env.LastPublished)
+}
+
+// SetAutoPublish sets the auto-publish flag on the given bindings and returns how many changed
+func (c *Config) SetAutoPublish(envName string, webResourceIDs []string, autoPublish bool) (int, error) {
+	ids := make(map[string]bool, len(webResourceIDs))
+	for _, id := range webResourceIDs {
+		ids[id] = true
+	}
+
+	changed := 0
+	for i := range c.Bindings {
+		if c.Bindings[i].Environment == envName && ids[c.Bindings[i].WebResourceID] && c.Bindings[i].AutoPublish != autoPublish {
+			c.Bindings[i].AutoPublish = autoPublish
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, c.Save()
+}
+
 // DeleteBinding removes a binding by environment and web resource ID
 func (c *Config) DeleteBinding(envName, webResourceID string) error {
 	newBindings := make([]Binding, 0, len(c.Bindings))
@@ -298,3 +1253,194 @@ func (c *Config) DeleteBinding(envName, webResourceID string) error {
 	c.Bindings = newBindings
 	return c.Save()
 }
+
+// ConfigExport is the part of the config that ExportConfig writes for a team
+// to share. It never holds tokens, which are stored apart from the config.
+type ConfigExport struct {
+	Environments      []Environment      `json:"environments"`
+	Bindings          []Binding          `json:"bindings"`
+	DirectoryBindings []DirectoryBinding `json:"directoryBindings,omitempty"`
+	PromotionChain    []string           `json:"promotionChain,omitempty"`
+}
+
+// ExportConfig writes the environments, global bindings, directory bindings
+// and promotion chain as JSON for ImportConfig. Local paths are written
+// relative to dir, the folder the export is saved in, so that a teammate who
+// keeps it in the same place in their own checkout gets working bindings.
+// State that only makes sense on this machine, such as the hash of the last
+// publish, is left out, and so are project bindings, which are shared through
+// the project file.
+func (c *Config) ExportConfig(w io.Writer, dir string) error {
+	shared := ConfigExport{
+		Environments:      make([]Environment, 0, len(c.Environments)),
+		Bindings:          []Binding{},
+		DirectoryBindings: make([]DirectoryBinding, 0, len(c.DirectoryBindings)),
+		PromotionChain:    c.PromotionChain,
+	}
+	for _, env := range c.Environments {
+		env.LastPublished = ""
+		env.ExpandedFolders = nil
+		if env.TokenOutputDir != "" {
+			env.TokenOutputDir = relativePath(dir, env.TokenOutputDir)
+		}
+		shared.Environments = append(shared.Environments, env)
+	}
+	for _, b := range c.Bindings {
+		if b.Project {
+			continue
+		}
+		b.LastPublishedHash = ""
+		b.ServerVersion = 0
+		b.LocalPath = relativePath(dir, b.LocalPath)
+		b.LocalPaths = relativePaths(dir, b.LocalPaths)
+		b.WatchPaths = relativePaths(dir, b.WatchPaths)
+		shared.Bindings = append(shared.Bindings, b)
+	}
+	for _, d := range c.DirectoryBindings {
+		d.LocalDir = relativePath(dir, d.LocalDir)
+		shared.DirectoryBindings = append(shared.DirectoryBindings, d)
+	}
+
+	data, err := json.MarshalIndent(shared, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadConfigExport reads a file written by ExportConfig, resolving its
+// relative paths against dir, the folder the file was read from. Absolute
+// paths, as in hand-written files, are kept. It fails if the file is invalid,
+// including an environment URL that isn't a Dynamics 365 URL.
+func ReadConfigExport(r io.Reader, dir string) (*ConfigExport, error) {
+	var shared ConfigExport
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&shared); err != nil {
+		return nil, fmt.Errorf("not a config export: %w", err)
+	}
+	for i, env := range shared.Environments {
+		if strings.TrimSpace(env.Name) == "" {
+			return nil, fmt.Errorf("environments[%d] has an empty name", i)
+		}
+		if err := ValidateEnvironmentURL(env.URL); err != nil {
+			return nil, fmt.Errorf("environment %q: %w", env.Name, err)
+		}
+		shared.Environments[i].TokenOutputDir = resolvePath(dir, env.TokenOutputDir)
+	}
+	for i := range shared.Bindings {
+		b := &shared.Bindings[i]
+		b.LocalPath = resolvePath(dir, b.LocalPath)
+		for j, path := range b.LocalPaths {
+			b.LocalPaths[j] = resolvePath(dir, path)
+		}
+		for j, path := range b.WatchPaths {
+			b.WatchPaths[j] = resolvePath(dir, path)
+		}
+	}
+	for i := range shared.DirectoryBindings {
+		shared.DirectoryBindings[i].LocalDir = resolvePath(dir, shared.DirectoryBindings[i].LocalDir)
+	}
+	return &shared, nil
+}
+
+// Commands lists the shell commands the export would run on publishing: the
+// prePublish hooks of its environments and the build commands of its
+// bindings, each labelled with where it comes from
+func (e *ConfigExport) Commands() []string {
+	var commands []string
+	for _, env := range e.Environments {
+		for _, hook := range env.PrePublish {
+			commands = append(commands, fmt.Sprintf("%s prePublish: %s", env.Name, hook))
+		}
+	}
+	for _, b := range e.Bindings {
+		if b.BuildCommand != "" {
+			commands = append(commands, fmt.Sprintf("%s build: %s", b.WebResourceName, b.BuildCommand))
+		}
+	}
+	return commands
+}
+
+// ImportResult counts what ImportConfig added
+type ImportResult struct {
+	Environments      int
+	Bindings          int
+	DirectoryBindings int
+}
+
+// ImportConfig adds an export read by ReadConfigExport. Merging adds only
+// what doesn't exist yet, matching environments by name, bindings by
+// environment and resource ID and directory bindings by environment and
+// folder; existing entries are left as they are. Replacing discards the
+// environments, global bindings, directory bindings and promotion chain
+// first. Nothing changes if the result would be invalid. Callers should show
+// the export's Commands and have them confirmed first, as they run on the
+// next publish.
+func (c *Config) ImportConfig(shared *ConfigExport, merge bool) (*ImportResult, error) {
+	// Build the result on a copy so a failed import leaves c untouched
+	next := *c
+	next.Environments = slices.Clone(c.Environments)
+	next.Bindings = slices.Clone(c.Bindings)
+	next.DirectoryBindings = slices.Clone(c.DirectoryBindings)
+	next.PromotionChain = slices.Clone(c.PromotionChain)
+	if !merge {
+		next.Environments = nil
+		next.Bindings = slices.DeleteFunc(next.Bindings, func(b Binding) bool { return !b.Project })
+		next.DirectoryBindings = nil
+		next.PromotionChain = nil
+	}
+
+	result := &ImportResult{}
+	for _, env := range shared.Environments {
+		if next.GetEnvironment(env.Name) != nil {
+			continue
+		}
+		if env.PublisherPrefix == "" {
+			env.PublisherPrefix = next.PublisherPrefix
+		}
+		next.Environments = append(next.Environments, env)
+		result.Environments++
+	}
+	for _, b := range shared.Bindings {
+		if next.GetBinding(b.Environment, b.WebResourceID) != nil {
+			continue
+		}
+		b.normalize()
+		b.Project = next.inProject(b.LocalPath)
+		next.Bindings = append(next.Bindings, b)
+		result.Bindings++
+	}
+	for _, d := range shared.DirectoryBindings {
+		if slices.ContainsFunc(next.DirectoryBindings, func(e DirectoryBinding) bool {
+			return e.Environment == d.Environment && e.LocalDir == d.LocalDir
+		}) {
+			continue
+		}
+		next.DirectoryBindings = append(next.DirectoryBindings, d)
+		result.DirectoryBindings++
+	}
+	if len(next.PromotionChain) == 0 {
+		next.PromotionChain = shared.PromotionChain
+	}
+	if next.GetEnvironment(next.CurrentEnvironment) == nil {
+		next.CurrentEnvironment = ""
+	}
+
+	if err := next.validate(); err != nil {
+		return nil, fmt.Errorf("import would leave the config invalid: %w", err)
+	}
+	// Loading only warns about these, but an import shouldn't add any
+	existing := c.lint()
+	for _, problem := range next.lint() {
+		if !slices.Contains(existing, problem) {
+			return nil, fmt.Errorf("import would leave the config invalid: %s", problem)
+		}
+	}
+	if err := next.Save(); err != nil {
+		return nil, err
+	}
+	*c = next
+	return result, nil
+}
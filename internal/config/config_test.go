@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/keychain"
+
+	"github.com/zalando/go-keyring"
+)
+
+// TestTokenCleanup checks that deleting or renaming an environment takes its
+// cached token along, and that tokens left behind are found and removed
+func TestTokenCleanup(t *testing.T) {
+	const devURL = "https://dev.crm.dynamics.com"
+
+	tests := []struct {
+		name        string
+		change      func(c *Config) error
+		wantTokens  []string // environments with a token file and keychain entry
+		wantOrphans []string // leftover token files and keychain entries
+	}{
+		{
+			name:        "delete",
+			change:      func(c *Config) error { return c.DeleteEnvironment("Dev") },
+			wantTokens:  []string{"Old", "Test"},
+			wantOrphans: []string{"Old"},
+		},
+		{
+			name:        "rename",
+			change:      func(c *Config) error { return c.UpdateEnvironment("Dev", "Prod", devURL) },
+			wantTokens:  []string{"Old", "Prod", "Test"},
+			wantOrphans: []string{"Old"},
+		},
+		{
+			name:        "change URL only",
+			change:      func(c *Config) error { return c.UpdateEnvironment("Dev", "Dev", "https://prod.crm.dynamics.com") },
+			wantTokens:  []string{"Old", "Test"},
+			wantOrphans: []string{"Old"},
+		},
+		{
+			name: "remove orphans",
+			change: func(c *Config) error {
+				files, entries, err := c.OrphanedTokens()
+				if err != nil {
+					return err
+				}
+				_, err = RemoveOrphanedTokens(files, entries)
+				return err
+			},
+			wantTokens: []string{"Dev", "Test"},
+		},
+	}
+
+	keyring.MockInit()
+	defer func(dir, path string) { configDir, configPath = dir, path }(configDir, configPath)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configDir = t.TempDir()
+			configPath = filepath.Join(configDir, "config.json")
+			c := &Config{Environments: []Environment{
+				{Name: "Dev", URL: devURL},
+				{Name: "Test", URL: "https://test.crm.dynamics.com"},
+			}}
+			// Old was deleted by a version that left its token behind
+			for _, name := range []string{"Dev", "Old", "Test"} {
+				if err := os.WriteFile(TokenPath(name), []byte("{}"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := keychain.Set(name, "secret"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer func() {
+				names, _ := keychain.Names()
+				for _, name := range names {
+					keychain.Delete(name)
+				}
+			}()
+
+			if err := tt.change(c); err != nil {
+				t.Fatalf("change: %v", err)
+			}
+
+			var files []string
+			for _, name := range tt.wantTokens {
+				files = append(files, TokenPath(name))
+			}
+			gotFiles, _ := filepath.Glob(filepath.Join(configDir, "token-*.json"))
+			if !slices.Equal(gotFiles, files) {
+				t.Errorf("token files %v, want %v", gotFiles, files)
+			}
+			gotEntries, _ := keychain.Names()
+			slices.Sort(gotEntries)
+			if !slices.Equal(gotEntries, tt.wantTokens) {
+				t.Errorf("keychain entries %v, want %v", gotEntries, tt.wantTokens)
+			}
+
+			orphanFiles, orphanEntries, err := c.OrphanedTokens()
+			if err != nil {
+				t.Fatalf("OrphanedTokens: %v", err)
+			}
+			var wantFiles []string
+			for _, name := range tt.wantOrphans {
+				wantFiles = append(wantFiles, TokenPath(name))
+			}
+			if !slices.Equal(orphanFiles, wantFiles) {
+				t.Errorf("orphaned files %v, want %v", orphanFiles, wantFiles)
+			}
+			if !slices.Equal(orphanEntries, tt.wantOrphans) {
+				t.Errorf("orphaned entries %v, want %v", orphanEntries, tt.wantOrphans)
+			}
+		})
+	}
+}
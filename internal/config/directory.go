@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DirectoryBinding publishes every file below a folder whose name matches a
+// pattern, creating the web resource if it doesn't exist yet. Files are bound
+// individually the first time they are published.
+type DirectoryBinding struct {
+	Environment string `json:"environment"`
+	LocalDir    string `json:"localDir"`
+	Pattern     string `json:"pattern"` // glob matched against the file name, e.g. *.js
+	// NameTemplate derives the web resource name. {path} is replaced with the
+	// file's path relative to LocalDir and {name} with its file name, e.g.
+	// new_/scripts/{path}.
+	NameTemplate string `json:"nameTemplate"`
+	// Ignore lists gitignore-style patterns of files and folders below
+	// LocalDir that are never published, on top of DefaultIgnore and the
+	// environment's ignore list
+	Ignore []string `json:"ignore,omitempty"`
+
+	inherited []string // DefaultIgnore and the environment's patterns
+}
+
+// Root returns the absolute path of the bound folder
+func (d DirectoryBinding) Root() string {
+	if abs, err := filepath.Abs(d.LocalDir); err == nil {
+		return abs
+	}
+	return d.LocalDir
+}
+
+// Matches reports whether a file belongs to the directory binding
+func (d DirectoryBinding) Matches(path string) bool {
+	_, ok := d.ResourceName(path)
+	return ok
+}
+
+// ResourceName returns the web resource name for a file below the folder,
+// or false if the file isn't covered by the binding
+func (d DirectoryBinding) ResourceName(path string) (string, bool) {
+	rel, err := filepath.Rel(d.Root(), path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if ok, err := filepath.Match(d.Pattern, filepath.Base(path)); err != nil || !ok {
+		return "", false
+	}
+	if Ignored(d.ignorePatterns(), rel, false) {
+		return "", false
+	}
+	name := strings.NewReplacer("{path}", filepath.ToSlash(rel), "{name}", filepath.Base(path)).Replace(d.NameTemplate)
+	return name, true
+}
+
+// SkipsDir reports whether a folder below the bound folder is ignored, so
+// nothing in it is watched or published
+func (d DirectoryBinding) SkipsDir(path string) bool {
+	rel, err := filepath.Rel(d.Root(), path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return Ignored(d.ignorePatterns(), rel, true)
+}
+
+// ignorePatterns returns the inherited patterns followed by the binding's own
+func (d DirectoryBinding) ignorePatterns() []string {
+	inherited := d.inherited
+	if inherited == nil {
+		inherited = DefaultIgnore
+	}
+	return append(inherited[:len(inherited):len(inherited)], d.Ignore...)
+}
+
+// validate checks the pattern and name template of a directory binding
+func (d DirectoryBinding) validate() error {
+	if strings.TrimSpace(d.LocalDir) == "" {
+		return fmt.Errorf("has an empty localDir")
+	}
+	if _, err := filepath.Match(d.Pattern, ""); err != nil || strings.TrimSpace(d.Pattern) == "" {
+		return fmt.Errorf("has an invalid pattern %q", d.Pattern)
+	}
+	if !strings.Contains(d.NameTemplate, "{path}") && !strings.Contains(d.NameTemplate, "{name}") {
+		return fmt.Errorf("has a nameTemplate without {path} or {name}: %q", d.NameTemplate)
+	}
+	if err := validateIgnore(d.Ignore); err != nil {
+		return fmt.Errorf("has an %w", err)
+	}
+	return nil
+}
+
+// GetDirectoryBindingsForEnvironment returns the directory bindings of an environment
+func (c *Config) GetDirectoryBindingsForEnvironment(envName string) []DirectoryBinding {
+	var result []DirectoryBinding
+	for _, d := range c.DirectoryBindings {
+		if d.Environment == envName && d.validate() == nil {
+			d.inherited = c.IgnorePatterns(envName)
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// DirectoryBindingFor returns the directory binding of an environment that
+// covers a file, if any
+func (c *Config) DirectoryBindingFor(envName, path string) (DirectoryBinding, bool) {
+	for _, d := range c.GetDirectoryBindingsForEnvironment(envName) {
+		if d.Matches(path) {
+			return d, true
+		}
+	}
+	return DirectoryBinding{}, false
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIgnore lists the swap, backup and temp files editors write next to
+// the files being edited. They are always ignored when scanning folders.
+var DefaultIgnore = []string{"*.swp", "*.swx", "*~", ".#*", "4913"}
+
+// IgnorePatterns returns the patterns a folder scan of an environment skips:
+// DefaultIgnore followed by the environment's own
+func (c *Config) IgnorePatterns(envName string) []string {
+	patterns := DefaultIgnore
+	if env := c.GetEnvironment(envName); env != nil {
+		patterns = append(patterns[:len(patterns):len(patterns)], env.Ignore...)
+	}
+	return patterns
+}
+
+// Ignored reports whether a path relative to a scanned folder is ignored by
+// gitignore-style patterns. A pattern without a slash matches a file or
+// folder name at any depth; one with a slash matches from the scanned folder,
+// and ** matches any number of folders. A trailing slash only matches
+// folders, and everything below an ignored folder is ignored. A pattern
+// starting with ! includes again what earlier patterns ignored.
+func Ignored(patterns []string, rel string, isDir bool) bool {
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	ignored := false
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if ignoreMatches(pattern, segments, isDir) {
+			ignored = !negate
+		}
+	}
+	return ignored
+}
+
+// ignoreMatches reports whether one pattern matches the path or one of the
+// folders it is in
+func ignoreMatches(pattern string, segments []string, isDir bool) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	parts := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+
+	for end := 1; end <= len(segments); end++ {
+		// Only the last segment can be a file
+		if dirOnly && end == len(segments) && !isDir {
+			break
+		}
+		if anchored {
+			if matchSegments(parts, segments[:end]) {
+				return true
+			}
+		} else if ok, _ := path.Match(pattern, segments[end-1]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where **
+// stands for any number of segments
+func matchSegments(parts, segments []string) bool {
+	if len(parts) == 0 {
+		return len(segments) == 0
+	}
+	if parts[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(parts[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(parts[0], segments[0])
+	return ok && matchSegments(parts[1:], segments[1:])
+}
+
+// validateIgnore checks that every ignore pattern is a valid glob
+func validateIgnore(patterns []string) error {
+	for _, pattern := range patterns {
+		for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(pattern), "!"), "/") {
+			if _, err := path.Match(part, ""); err != nil {
+				return fmt.Errorf("invalid ignore pattern %q", pattern)
+			}
+		}
+	}
+	return nil
+}
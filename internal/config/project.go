@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectFileName is the project-local binding file discovered from the working directory
+const ProjectFileName = ".d365tui.json"
+
+// projectFile is the on-disk format of a project-local binding file. Paths are
+// stored relative to the file's directory with forward slashes so the file can
+// be shared through version control.
+type projectFile struct {
+	Bindings []Binding `json:"bindings"`
+}
+
+// FindProjectFile walks up from dir looking for a project binding file and
+// returns its path, or an empty string if there is none
+func FindProjectFile(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ProjectFile returns the path of the loaded project binding file, if any
+func (c *Config) ProjectFile() string {
+	return c.projectPath
+}
+
+// loadProject merges the bindings from a project file into the config.
+// Project bindings take precedence over global bindings for the same resource.
+func (c *Config) loadProject(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var pf projectFile
+	if err := json.Unmarshal(data, &pf); err != nil {
+		return fmt.Errorf("%s is invalid: %w", path, err)
+	}
+
+	root := filepath.Dir(path)
+	for _, b := range pf.Bindings {
+		if b.Environment == "" || b.WebResourceID == "" {
+			return fmt.Errorf("%s is invalid: every binding needs an environment and webResourceId", path)
+		}
+		b.normalize()
+		// Paths outside the project root are written as absolute paths
+		b.LocalPath = resolvePath(root, b.LocalPath)
+		for i, source := range b.LocalPaths {
+			b.LocalPaths[i] = resolvePath(root, source)
+		}
+		for i, path := range b.WatchPaths {
+			b.WatchPaths[i] = resolvePath(root, path)
+		}
+		b.Project = true
+		c.removeBinding(b.Environment, b.WebResourceID)
+		c.Bindings = append(c.Bindings, b)
+	}
+
+	c.projectPath = path
+	return nil
+}
+
+// saveProject writes the project bindings back to the project file
+func (c *Config) saveProject() error {
+	root := filepath.Dir(c.projectPath)
+	pf := projectFile{Bindings: []Binding{}}
+	for _, b := range c.Bindings {
+		if !b.Project {
+			continue
+		}
+		b.LocalPath = relativePath(root, b.LocalPath)
+		b.LocalPaths = relativePaths(root, b.LocalPaths)
+		b.WatchPaths = relativePaths(root, b.WatchPaths)
+		pf.Bindings = append(pf.Bindings, b)
+	}
+
+	data, err := json.MarshalIndent(pf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.projectPath, append(data, '\n'), 0644)
+}
+
+// relativePath returns path relative to root with forward slashes, or path
+// itself if it can't be made relative, e.g. on another drive
+func relativePath(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// relativePaths returns a copy of paths relative to root
+func relativePaths(root string, paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	rel := make([]string, len(paths))
+	for i, path := range paths {
+		rel[i] = relativePath(root, path)
+	}
+	return rel
+}
+
+// resolvePath joins a relative path written by relativePath to root; an
+// absolute path is returned as it is
+func resolvePath(root, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, filepath.FromSlash(path))
+}
+
+// inProject reports whether a local path lives under the project root
+func (c *Config) inProject(path string) bool {
+	if c.projectPath == "" {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Dir(c.projectPath), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// removeBinding drops a binding from memory without saving
+func (c *Config) removeBinding(envName, webResourceID string) {
+	for i, b := range c.Bindings {
+		if b.Environment == envName && b.WebResourceID == webResourceID {
+			c.Bindings = append(c.Bindings[:i], c.Bindings[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package d365
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ResourceCache is a web resource list saved to disk, so a reconnect can show
+// it before the list is fetched again
+type ResourceCache struct {
+	Fetched        time.Time     `json:"fetched"`
+	IncludeManaged bool          `json:"includeManaged"`
+	Resources      []WebResource `json:"resources"`
+}
+
+// LoadResourceCache reads a cached resource list
+func LoadResourceCache(path string) (*ResourceCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cache ResourceCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// SaveResourceCache writes a resource list to path, replacing any earlier one
+func SaveResourceCache(path string, cache ResourceCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// Write beside the file and rename, so a reader never sees half a list.
+	// Each save has its own temporary file, as two fetches may overlap.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
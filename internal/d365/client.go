@@ -2,38 +2,279 @@ package d365
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 // ErrUnauthorized is returned when the API returns a 401 status
 var ErrUnauthorized = errors.New("unauthorized: token may be expired")
 
+// ErrNotFound is returned when the API returns a 404 status
+var ErrNotFound = errors.New("not found")
+
+// ErrThrottled is returned when the API still answers 429 after the retries,
+// because the service protection limits were exceeded
+var ErrThrottled = errors.New("too many requests: throttled by the service protection limits")
+
+// APIError describes a failed Web API request. It wraps ErrUnauthorized,
+// ErrNotFound or ErrThrottled for the matching status codes, so callers can
+// use errors.Is, or errors.As to look at the status and OData code.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	ODataCode  string // code of the OData error, e.g. 0x80040217, if any
+	Message    string // friendly message parsed from the OData error, if any
+	Body       string // raw response body
+}
+
+// Error returns the status code and friendly message
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = strings.TrimSpace(e.Body)
+	}
+	if msg == "" {
+		if sentinel := e.Unwrap(); sentinel != nil {
+			msg = sentinel.Error()
+		} else {
+			msg = http.StatusText(e.StatusCode)
+		}
+	}
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, msg)
+}
+
+// Unwrap allows errors.Is with ErrUnauthorized, ErrNotFound and ErrThrottled
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	}
+	return nil
+}
+
+// newAPIError builds an APIError, extracting the OData error code and message from the body
+func newAPIError(method, path string, statusCode int, body []byte) *APIError {
+	apiErr := &APIError{
+		Method:     method,
+		Path:       path,
+		StatusCode: statusCode,
+		Body:       string(body),
+	}
+
+	var odata struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &odata); err == nil {
+		apiErr.ODataCode = odata.Error.Code
+		apiErr.Message = odata.Error.Message
+	}
+
+	return apiErr
+}
+
+// IsNetworkError reports whether err is a transport failure (DNS, refused
+// connection, timeout) rather than a response from the server
+func IsNetworkError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// Ping performs a cheap WhoAmI request to check that the organization is reachable
+func (c *Client) Ping() error {
+	_, err := c.doRequest("GET", "/WhoAmI", nil)
+	return err
+}
+
+// WhoAmIResult identifies the user a token signs in as
+type WhoAmIResult struct {
+	UserID         string `json:"UserId"`
+	BusinessUnitID string `json:"BusinessUnitId"`
+	OrganizationID string `json:"OrganizationId"`
+	UserName       string `json:"-"` // full name, or the sign-in name if there is none; empty if it can't be read
+}
+
+// WhoAmI returns the user the token signs in as, looking up the user's name
+func (c *Client) WhoAmI() (*WhoAmIResult, error) {
+	body, err := c.doRequest("GET", "/WhoAmI", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result WhoAmIResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	// The name is only for display, so a failed lookup is not an error
+	body, err = c.doRequest("GET", "/systemusers("+result.UserID+")?$select=fullname,domainname", nil)
+	if err == nil {
+		var user struct {
+			FullName   string `json:"fullname"`
+			DomainName string `json:"domainname"`
+		}
+		if json.Unmarshal(body, &user) == nil {
+			result.UserName = user.FullName
+			if result.UserName == "" {
+				result.UserName = user.DomainName
+			}
+		}
+	}
+	return &result, nil
+}
+
 // TokenRefreshFunc is a callback function that attempts to refresh the token
 // It should return the new access token or an error
 type TokenRefreshFunc func() (string, error)
 
+// Timeouts used when ClientOptions leaves them zero
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultPublishTimeout = 2 * time.Minute
+)
+
+// ClientOptions sets a client's timeouts; zero fields use the defaults.
+// Request and publish timeouts only apply to requests whose context has no
+// deadline of its own.
+type ClientOptions struct {
+	ConnectTimeout time.Duration // dialing and the TLS handshake
+	RequestTimeout time.Duration // reads such as listing web resources
+	PublishTimeout time.Duration // content uploads, creates and publishes
+}
+
+// Backoff between retries starts at retryBaseDelay and doubles up to retryMaxDelay
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
 // Client represents a Dynamics 365 Web API client
 type Client struct {
-	baseURL      string
-	accessToken  string
-	httpClient   *http.Client
-	tokenRefresh TokenRefreshFunc
+	baseURL        string
+	token          *tokenStore // shared with the copies WithContext makes
+	httpClient     *http.Client
+	tokenRefresh   TokenRefreshFunc
+	ctx            context.Context
+	maxRetries     int
+	requestTimeout time.Duration
+	publishTimeout time.Duration
+}
+
+// tokenStore holds the access token. Requests may run concurrently and any
+// of them may replace the token after a 401, so access is locked.
+type tokenStore struct {
+	mu    sync.RWMutex
+	value string
+}
+
+func (t *tokenStore) get() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.value
+}
+
+func (t *tokenStore) set(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.value = token
+}
+
+// defaultHTTPClient is shared by clients created with NewClient
+var defaultHTTPClient = &http.Client{}
+
+// SetHTTPClient replaces the HTTP client of clients created from now on,
+// e.g. to go through a proxy or trust a custom CA. Timeouts come from each
+// request's context, so the client should not set its own.
+func SetHTTPClient(client *http.Client) {
+	defaultHTTPClient = client
 }
 
 // NewClient creates a new Dynamics 365 client
-func NewClient(orgURL, accessToken string) *Client {
+func NewClient(orgURL, accessToken string, opts ClientOptions) *Client {
 	return &Client{
-		baseURL:     orgURL + "/api/data/v9.2",
-		accessToken: accessToken,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		baseURL:        orgURL + "/api/data/v9.2",
+		token:          &tokenStore{value: accessToken},
+		httpClient:     withConnectTimeout(defaultHTTPClient, orDefault(opts.ConnectTimeout, DefaultConnectTimeout)),
+		ctx:            context.Background(),
+		requestTimeout: orDefault(opts.RequestTimeout, DefaultRequestTimeout),
+		publishTimeout: orDefault(opts.PublishTimeout, DefaultPublishTimeout),
+	}
+}
+
+// orDefault returns d unless it is zero or negative
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// connectTimeoutKey identifies a client made by withConnectTimeout
+type connectTimeoutKey struct {
+	base    *http.Client
+	timeout time.Duration
+}
+
+// connectTimeoutClients caches the clients of withConnectTimeout, so that
+// clients created with the same settings share one transport and its pool of
+// connections instead of opening new ones
+var (
+	connectTimeoutMu      sync.Mutex
+	connectTimeoutClients = map[connectTimeoutKey]*http.Client{}
+)
+
+// withConnectTimeout returns a copy of client whose connections give up
+// dialing and the TLS handshake after timeout. The copy is made once per
+// client and timeout and shared after that.
+func withConnectTimeout(client *http.Client, timeout time.Duration) *http.Client {
+	base, ok := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
 	}
+	if !ok {
+		return client
+	}
+
+	connectTimeoutMu.Lock()
+	defer connectTimeoutMu.Unlock()
+	key := connectTimeoutKey{base: client, timeout: timeout}
+	if shared, ok := connectTimeoutClients[key]; ok {
+		return shared
+	}
+	transport := base.Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	clone := *client
+	clone.Transport = transport
+	connectTimeoutClients[key] = &clone
+	return &clone
+}
+
+// WithContext returns a copy of the client whose requests use ctx, so callers
+// can give cheap calls a short deadline and uploads a longer one. Requests
+// without a deadline are limited by the client's request or publish timeout.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
 }
 
 // SetTokenRefreshFunc sets the callback function for token refresh
@@ -41,18 +282,44 @@ func (c *Client) SetTokenRefreshFunc(fn TokenRefreshFunc) {
 	c.tokenRefresh = fn
 }
 
+// SetMaxRetries sets how often a request is retried after a 429 or 5xx
+// response; zero, the default of a new client, disables retries
+func (c *Client) SetMaxRetries(n int) {
+	c.maxRetries = max(n, 0)
+}
+
 // UpdateToken updates the access token
 func (c *Client) UpdateToken(token string) {
-	c.accessToken = token
+	c.token.set(token)
+}
+
+// requestURL resolves a path relative to the Web API, or accepts an absolute
+// URL such as @odata.nextLink as long as it points at the same Web API so the
+// token is never sent elsewhere
+func (c *Client) requestURL(path string) (string, error) {
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		return c.baseURL + path, nil
+	}
+	if !strings.HasPrefix(strings.ToLower(path), strings.ToLower(c.baseURL)+"/") {
+		return "", fmt.Errorf("refusing to follow link outside %s: %s", c.baseURL, path)
+	}
+	return path, nil
 }
 
 // doRequest performs an HTTP request with authorization
 func (c *Client) doRequest(method, path string, body any) ([]byte, error) {
-	return c.doRequestWithRetry(method, path, body, true)
+	return c.doRequestWithRetry(method, path, body, true, c.requestTimeout)
+}
+
+// doPublishRequest performs an upload or publish, which may take much longer
+// than a read on a slow environment
+func (c *Client) doPublishRequest(method, path string, body any) ([]byte, error) {
+	return c.doRequestWithRetry(method, path, body, true, c.publishTimeout)
 }
 
-// doRequestWithRetry performs an HTTP request with optional token refresh retry
-func (c *Client) doRequestWithRetry(method, path string, body any, allowRetry bool) ([]byte, error) {
+// doRequestWithRetry performs an HTTP request with optional token refresh
+// retry, limited to timeout unless the client's context has a deadline
+func (c *Client) doRequestWithRetry(method, path string, body any, allowRetry bool, timeout time.Duration) ([]byte, error) {
 	// Store body for potential retry
 	var bodyBytes []byte
 	if body != nil {
@@ -63,49 +330,160 @@ func (c *Client) doRequestWithRetry(method, path string, body any, allowRetry bo
 		}
 	}
 
+	ctx := c.ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	target, err := c.requestURL(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, respBody, err := c.send(ctx, method, target, bodyBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		// Handle 401 Unauthorized - attempt token refresh
+		if resp.StatusCode == http.StatusUnauthorized {
+			if allowRetry && c.tokenRefresh != nil {
+				newToken, refreshErr := c.tokenRefresh()
+				if refreshErr == nil && newToken != "" {
+					c.token.set(newToken)
+					// Retry the request with the new token
+					return c.doRequestWithRetry(method, path, body, false, timeout)
+				}
+			}
+			return nil, newAPIError(method, path, resp.StatusCode, respBody)
+		}
+
+		if resp.StatusCode >= 400 {
+			apiErr := newAPIError(method, path, resp.StatusCode, respBody)
+			delay, ok := retryDelay(resp, attempt)
+			if !ok || !retryable(method, resp.StatusCode) || attempt >= c.maxRetries || !wait(ctx, delay) {
+				return nil, apiErr
+			}
+			continue
+		}
+
+		return respBody, nil
+	}
+}
+
+// Workers is how many requests forEach runs at once; enough to hide the
+// latency of per-resource lookups without tripping the service protection limits
+const Workers = 8
+
+// forEach calls fn for 0..n-1 on up to Workers goroutines and returns the
+// errors of all failed calls joined, or nil. The client is safe for
+// concurrent requests.
+func forEach(n int, fn func(i int) error) error {
+	jobs := make(chan int)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for range min(Workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// send performs one attempt of a request and reads the whole response
+func (c *Client) send(ctx context.Context, method, target string, bodyBytes []byte) (*http.Response, []byte, error) {
 	var reqBody io.Reader
 	if bodyBytes != nil {
 		reqBody = bytes.NewReader(bodyBytes)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Authorization", "Bearer "+c.token.get())
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("OData-MaxVersion", "4.0")
 	req.Header.Set("OData-Version", "4.0")
 	req.Header.Set("Accept", "application/json")
+	// Include display values such as the name behind a lookup ID
+	req.Header.Set("Prefer", `odata.include-annotations="OData.Community.Display.V1.FormattedValue"`)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return resp, respBody, nil
+}
 
-	// Handle 401 Unauthorized - attempt token refresh
-	if resp.StatusCode == http.StatusUnauthorized {
-		if allowRetry && c.tokenRefresh != nil {
-			newToken, refreshErr := c.tokenRefresh()
-			if refreshErr == nil && newToken != "" {
-				c.accessToken = newToken
-				// Retry the request with the new token
-				return c.doRequestWithRetry(method, path, body, false)
-			}
+// retryDelay reports whether a failed response is worth retrying and how long
+// to wait first. Throttling responses honor Retry-After; other server errors
+// back off exponentially.
+func retryDelay(resp *http.Response, attempt int) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusServiceUnavailable:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay, true
 		}
-		return nil, ErrUnauthorized
+	case resp.StatusCode < 500:
+		return 0, false
 	}
+	return min(retryBaseDelay<<attempt, retryMaxDelay), true
+}
 
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+// retryable reports whether a failed request may be sent again. A POST may
+// have taken effect despite a server error, e.g. creating a web resource, so
+// it is only retried when throttled, which the server rejects before running.
+func retryable(method string, status int) bool {
+	return method != http.MethodPost || status == http.StatusTooManyRequests
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
 	}
+	return 0, false
+}
 
-	return respBody, nil
+// wait sleeps for delay unless the context ends first or its deadline is too
+// close for the retry to finish, and reports whether the retry should go ahead
+func wait(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
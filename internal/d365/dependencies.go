@@ -0,0 +1,104 @@
+package d365
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// componentTypeWebResource is the solution component type of web resources
+const componentTypeWebResource = 61
+
+// componentTypeNames names the solution component types that commonly use a web resource
+var componentTypeNames = map[int]string{
+	1:   "table",
+	24:  "form",
+	26:  "view",
+	29:  "process",
+	48:  "ribbon command",
+	50:  "ribbon customization",
+	55:  "ribbon",
+	60:  "form",
+	61:  "web resource",
+	62:  "site map",
+	80:  "model-driven app",
+	300: "canvas app",
+}
+
+// Dependency is a component that requires a web resource
+type Dependency struct {
+	ComponentType int    `json:"dependentcomponenttype"`
+	ObjectID      string `json:"dependentcomponentobjectid"`
+	Name          string `json:"-"` // form name, when it could be looked up
+}
+
+// String describes the dependent component
+func (d Dependency) String() string {
+	kind, ok := componentTypeNames[d.ComponentType]
+	if !ok {
+		kind = fmt.Sprintf("component type %d", d.ComponentType)
+	}
+	if d.Name != "" {
+		return kind + " " + d.Name
+	}
+	return kind + " " + d.ObjectID
+}
+
+// DependentsError is returned when a web resource can't be deleted because
+// other components still use it
+type DependentsError struct {
+	Dependents []Dependency
+}
+
+func (e *DependentsError) Error() string {
+	names := make([]string, len(e.Dependents))
+	for i, d := range e.Dependents {
+		names[i] = d.String()
+	}
+	return fmt.Sprintf("still used by %d components; remove it from them first: %s", len(e.Dependents), strings.Join(names, ", "))
+}
+
+// GetDependenciesForDelete lists the components that prevent a web resource
+// from being deleted, naming the forms among them
+func (c *Client) GetDependenciesForDelete(webResourceID string) ([]Dependency, error) {
+	path := fmt.Sprintf("/RetrieveDependenciesForDelete(ObjectId=%s,ComponentType=%d)", webResourceID, componentTypeWebResource)
+
+	body, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Value []Dependency `json:"value"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	for i, d := range response.Value {
+		if d.ComponentType == 60 {
+			response.Value[i].Name = c.formName(d.ObjectID)
+		}
+	}
+	return response.Value, nil
+}
+
+// formName returns the name and table of a form, or an empty string if it
+// can't be read
+func (c *Client) formName(formID string) string {
+	body, err := c.doRequest("GET", "/systemforms("+formID+")?$select=name,objecttypecode", nil)
+	if err != nil {
+		return ""
+	}
+	var form struct {
+		Name  string `json:"name"`
+		Table string `json:"objecttypecode"`
+	}
+	if err := json.Unmarshal(body, &form); err != nil || form.Name == "" {
+		return ""
+	}
+	if form.Table != "" {
+		return fmt.Sprintf("%q (%s)", form.Name, form.Table)
+	}
+	return fmt.Sprintf("%q", form.Name)
+}
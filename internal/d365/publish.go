@@ -1,20 +1,49 @@
 package d365
 
-import "fmt"
+import "encoding/xml"
+
+// publishParameters is the ParameterXml document accepted by PublishXml
+type publishParameters struct {
+	XMLName      xml.Name `xml:"importexportxml"`
+	WebResources []string `xml:"webresources>webresource"`
+}
+
+// publishParameterXML builds the ParameterXml for publishing web resources,
+// escaping every value rather than formatting it into the document
+func publishParameterXML(webResourceIDs ...string) (string, error) {
+	data, err := xml.Marshal(publishParameters{WebResources: webResourceIDs})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
 
 // PublishWebResource publishes a web resource
 func (c *Client) PublishWebResource(webResourceID string) error {
+	return c.PublishWebResources([]string{webResourceID})
+}
+
+// PublishWebResources publishes several web resources with a single PublishXml call
+func (c *Client) PublishWebResources(webResourceIDs []string) error {
 	path := "/PublishXml"
 
-	paramXML := fmt.Sprintf(
-		"<importexportxml><webresources><webresource>%s</webresource></webresources></importexportxml>",
-		webResourceID,
-	)
+	paramXML, err := publishParameterXML(webResourceIDs...)
+	if err != nil {
+		return err
+	}
 
 	payload := map[string]string{
 		"ParameterXml": paramXML,
 	}
 
-	_, err := c.doRequest("POST", path, payload)
+	_, err = c.doPublishRequest("POST", path, payload)
+	return err
+}
+
+// PublishAllCustomizations publishes every unpublished customization in the
+// organization (forms, views, ribbons, web resources, ...). This is much
+// slower than publishing a single web resource.
+func (c *Client) PublishAllCustomizations() error {
+	_, err := c.doPublishRequest("POST", "/PublishAllXml", nil)
 	return err
 }
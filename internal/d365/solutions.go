@@ -4,14 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
 )
 
 // Solution represents a Dynamics 365 solution
 type Solution struct {
-	ID           string `json:"solutionid"`
-	UniqueName   string `json:"uniquename"`
-	FriendlyName string `json:"friendlyname"`
-	Version      string `json:"version"`
+	ID           string            `json:"solutionid"`
+	UniqueName   string            `json:"uniquename"`
+	FriendlyName string            `json:"friendlyname"`
+	Version      string            `json:"version"`
+	Publisher    SolutionPublisher `json:"publisherid"`
+}
+
+// SolutionPublisher represents the publisher that owns a solution
+type SolutionPublisher struct {
+	CustomizationPrefix string `json:"customizationprefix"`
 }
 
 // SolutionResponse represents the API response for solutions
@@ -23,7 +32,8 @@ type SolutionResponse struct {
 func (c *Client) ListSolutions() ([]Solution, error) {
 	filter := url.QueryEscape("ismanaged eq false")
 	orderby := url.QueryEscape("createdon desc")
-	path := "/solutions?$select=solutionid,uniquename,friendlyname,version&$filter=" + filter + "&$orderby=" + orderby
+	expand := url.QueryEscape("publisherid($select=customizationprefix)")
+	path := "/solutions?$select=solutionid,uniquename,friendlyname,version&$filter=" + filter + "&$orderby=" + orderby + "&$expand=" + expand
 
 	body, err := c.doRequest("GET", path, nil)
 	if err != nil {
@@ -58,3 +68,115 @@ func (c *Client) AddWebResourceToSolution(solutionUniqueName, webResourceID stri
 
 	return nil
 }
+
+// webResourceSolutions returns the unique names of the solutions containing
+// a web resource
+func (c *Client) webResourceSolutions(webResourceID string) ([]string, error) {
+	// ComponentType 61 = Web Resource
+	filter := url.QueryEscape("objectid eq " + webResourceID + " and componenttype eq 61")
+	expand := url.QueryEscape("solutionid($select=uniquename)")
+	path := "/solutioncomponents?$select=objectid&$filter=" + filter + "&$expand=" + expand
+
+	body, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Value []struct {
+			Solution struct {
+				UniqueName string `json:"uniquename"`
+			} `json:"solutionid"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(response.Value))
+	for _, component := range response.Value {
+		names = append(names, component.Solution.UniqueName)
+	}
+	return names, nil
+}
+
+// GetWebResourceSolutions looks up the solutions of several web resources,
+// one request each, Workers at a time. Resources whose lookup failed are left
+// out of the map and their errors are returned joined.
+func (c *Client) GetWebResourceSolutions(webResourceIDs []string) (map[string][]string, error) {
+	solutions := make(map[string][]string, len(webResourceIDs))
+	var mu sync.Mutex
+	err := forEach(len(webResourceIDs), func(i int) error {
+		id := webResourceIDs[i]
+		names, err := c.webResourceSolutions(id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		mu.Lock()
+		solutions[id] = names
+		mu.Unlock()
+		return nil
+	})
+	return solutions, err
+}
+
+// solutionFilterSize is how many web resource IDs one query of
+// ListSolutionWebResources filters on, keeping the URL well below its limit
+const solutionFilterSize = 50
+
+// solutionWebResourceIDs returns the IDs of the web resources in a solution
+func (c *Client) solutionWebResourceIDs(solutionID string) ([]string, error) {
+	// ComponentType 61 = Web Resource
+	filter := url.QueryEscape("_solutionid_value eq " + solutionID + " and componenttype eq 61")
+	path := "/solutioncomponents?$select=objectid&$filter=" + filter
+
+	var ids []string
+	for path != "" {
+		body, err := c.doRequest("GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			Value []struct {
+				ObjectID string `json:"objectid"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+
+		for _, component := range response.Value {
+			ids = append(ids, component.ObjectID)
+		}
+		path = response.NextLink
+	}
+	return ids, nil
+}
+
+// ListSolutionWebResources retrieves the web resources of a solution that
+// ListWebResources would return, ordered by name. The solution's components
+// are looked up first, then the web resources are fetched by ID in batches.
+func (c *Client) ListSolutionWebResources(solutionID string, includeManaged bool) ([]WebResource, error) {
+	ids, err := c.solutionWebResourceIDs(solutionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []WebResource
+	for batch := range slices.Chunk(ids, solutionFilterSize) {
+		clauses := make([]string, len(batch))
+		for i, id := range batch {
+			clauses[i] = "webresourceid eq " + id
+		}
+		page, err := c.queryWebResources(webResourceFilter(includeManaged) + " and (" + strings.Join(clauses, " or ") + ")")
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, page...)
+	}
+
+	slices.SortFunc(resources, func(a, b WebResource) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) })
+	return resources, nil
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // WebResourceType represents the type of web resource
@@ -26,17 +27,106 @@ const (
 	WebResourceTypeResx WebResourceType = 12
 )
 
+// IsText reports whether the web resource type holds text content
+func (t WebResourceType) IsText() bool {
+	switch t {
+	case WebResourceTypeHTML, WebResourceTypeCSS, WebResourceTypeJS, WebResourceTypeXML,
+		WebResourceTypeXSL, WebResourceTypeSVG, WebResourceTypeResx:
+		return true
+	}
+	return false
+}
+
+// String returns a short label for the web resource type
+func (t WebResourceType) String() string {
+	switch t {
+	case WebResourceTypeHTML:
+		return "HTML"
+	case WebResourceTypeCSS:
+		return "CSS"
+	case WebResourceTypeJS:
+		return "JS"
+	case WebResourceTypeXML:
+		return "XML"
+	case WebResourceTypePNG:
+		return "PNG"
+	case WebResourceTypeJPG:
+		return "JPG"
+	case WebResourceTypeGIF:
+		return "GIF"
+	case WebResourceTypeXAP:
+		return "XAP"
+	case WebResourceTypeXSL:
+		return "XSL"
+	case WebResourceTypeICO:
+		return "ICO"
+	case WebResourceTypeSVG:
+		return "SVG"
+	case WebResourceTypeResx:
+		return "RESX"
+	}
+	return "?"
+}
+
+// Extensions returns the local file extensions that map to the web resource type
+func (t WebResourceType) Extensions() []string {
+	switch t {
+	case WebResourceTypeHTML:
+		return []string{".html", ".htm"}
+	case WebResourceTypeCSS:
+		return []string{".css"}
+	case WebResourceTypeJS:
+		return []string{".js"}
+	case WebResourceTypeXML:
+		return []string{".xml"}
+	case WebResourceTypePNG:
+		return []string{".png"}
+	case WebResourceTypeJPG:
+		return []string{".jpg", ".jpeg"}
+	case WebResourceTypeGIF:
+		return []string{".gif"}
+	case WebResourceTypeXAP:
+		return []string{".xap"}
+	case WebResourceTypeXSL:
+		return []string{".xsl", ".xslt"}
+	case WebResourceTypeICO:
+		return []string{".ico"}
+	case WebResourceTypeSVG:
+		return []string{".svg"}
+	case WebResourceTypeResx:
+		return []string{".resx"}
+	}
+	return nil
+}
+
 // WebResource represents a Dynamics 365 web resource
 type WebResource struct {
-	ID        string `json:"webresourceid"`
-	Name      string `json:"name"`
-	Version   int64  `json:"versionnumber,omitempty"`
-	IsManaged bool   `json:"ismanaged"`
+	ID             string          `json:"webresourceid"`
+	Name           string          `json:"name"`
+	DisplayName    string          `json:"displayname,omitempty"`
+	Type           WebResourceType `json:"webresourcetype"`
+	Version        int64           `json:"versionnumber,omitempty"`
+	IsManaged      bool            `json:"ismanaged"`
+	ModifiedOn     time.Time       `json:"modifiedon,omitempty"`
+	ModifiedBy     string          `json:"_modifiedby_value,omitempty"`                                           // user ID
+	ModifiedByName string          `json:"_modifiedby_value@OData.Community.Display.V1.FormattedValue,omitempty"` // needs the formatted values annotation
 }
 
+// webResourceFields are the columns selected whenever web resources are listed or fetched
+const webResourceFields = "webresourceid,name,displayname,webresourcetype,versionnumber,ismanaged,modifiedon,_modifiedby_value"
+
 // WebResourceResponse represents the API response for web resources
 type WebResourceResponse struct {
-	Value []WebResource `json:"value"`
+	Value    []WebResource `json:"value"`
+	NextLink string        `json:"@odata.nextLink"` // absolute URL of the next page, empty on the last one
+}
+
+// WebResourceAudit holds who last modified a web resource and when
+type WebResourceAudit struct {
+	ModifiedOn time.Time `json:"modifiedon"`
+	ModifiedBy struct {
+		FullName string `json:"fullname"`
+	} `json:"modifiedby"`
 }
 
 // CreateWebResourceRequest represents the request to create a web resource
@@ -83,12 +173,67 @@ func GetWebResourceTypeFromExtension(filename string) (WebResourceType, error) {
 // ListWebResources retrieves web resources (HTML, CSS, JS only).
 // By default it returns unmanaged resources; includeManaged=true returns both managed and unmanaged.
 func (c *Client) ListWebResources(includeManaged bool) ([]WebResource, error) {
+	return c.queryWebResources(webResourceFilter(includeManaged))
+}
+
+// ListAllWebResources retrieves web resources of every type, including the
+// images and other types ListWebResources leaves out
+func (c *Client) ListAllWebResources(includeManaged bool) ([]WebResource, error) {
+	if includeManaged {
+		return c.queryWebResources("")
+	}
+	return c.queryWebResources("ismanaged eq false")
+}
+
+// SearchWebResources retrieves web resources whose name contains the query,
+// filtering on the server instead of loading the full list
+func (c *Client) SearchWebResources(query string, includeManaged bool) ([]WebResource, error) {
+	filter := webResourceFilter(includeManaged) + " and contains(name,'" + strings.ReplaceAll(query, "'", "''") + "')"
+	return c.queryWebResources(filter)
+}
+
+// webResourceFilter returns the base $filter for listable web resources
+func webResourceFilter(includeManaged bool) string {
 	// Filter by webresourcetype: 1=HTML, 2=CSS, 3=JS
 	filter := "(webresourcetype eq 1 or webresourcetype eq 2 or webresourcetype eq 3)"
 	if !includeManaged {
 		filter += " and ismanaged eq false"
 	}
-	path := "/webresourceset?$select=webresourceid,name,versionnumber,ismanaged&$filter=" + url.QueryEscape(filter) + "&$orderby=name"
+	return filter
+}
+
+// queryWebResources runs a web resource query with the given $filter, if
+// any, ordered by name, following @odata.nextLink until every page is read
+func (c *Client) queryWebResources(filter string) ([]WebResource, error) {
+	path := "/webresourceset?$select=" + webResourceFields + "&$orderby=name"
+	if filter != "" {
+		path += "&$filter=" + url.QueryEscape(filter)
+	}
+
+	// Large orgs are returned in pages; the server carries the query into nextLink
+	var resources []WebResource
+	for path != "" {
+		body, err := c.doRequest("GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var response WebResourceResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, response.Value...)
+		path = response.NextLink
+	}
+
+	return resources, nil
+}
+
+// GetWebResourceByName looks up a single web resource by its unique name
+func (c *Client) GetWebResourceByName(name string) (*WebResource, error) {
+	filter := "name eq '" + strings.ReplaceAll(name, "'", "''") + "'"
+	path := "/webresourceset?$select=" + webResourceFields + "&$filter=" + url.QueryEscape(filter)
 
 	body, err := c.doRequest("GET", path, nil)
 	if err != nil {
@@ -100,7 +245,140 @@ func (c *Client) ListWebResources(includeManaged bool) ([]WebResource, error) {
 		return nil, err
 	}
 
-	return response.Value, nil
+	if len(response.Value) == 0 {
+		return nil, fmt.Errorf("%w: web resource %s", ErrNotFound, name)
+	}
+
+	return &response.Value[0], nil
+}
+
+// GetWebResource fetches a single web resource by ID, returning ErrNotFound if it was deleted
+func (c *Client) GetWebResource(webResourceID string) (*WebResource, error) {
+	path := "/webresourceset(" + webResourceID + ")?$select=" + webResourceFields
+
+	body, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res WebResource
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// UpdateWebResourceDisplayName changes the display name shown in the maker portal
+func (c *Client) UpdateWebResourceDisplayName(webResourceID, displayName string) error {
+	path := "/webresourceset(" + webResourceID + ")"
+
+	payload := map[string]string{
+		"displayname": displayName,
+	}
+
+	_, err := c.doRequest("PATCH", path, payload)
+	return err
+}
+
+// RenameWebResource changes the unique name of a web resource. Dataverse
+// doesn't always allow updating the name and may accept the request while
+// keeping the old one, so the resource is read back and an error returned
+// unless it has the new name.
+func (c *Client) RenameWebResource(webResourceID, newName string) error {
+	path := "/webresourceset(" + webResourceID + ")"
+
+	payload := map[string]string{
+		"name": newName,
+	}
+
+	if _, err := c.doRequest("PATCH", path, payload); err != nil {
+		return err
+	}
+
+	res, err := c.GetWebResource(webResourceID)
+	if err != nil {
+		return fmt.Errorf("renamed, but reading it back failed: %w", err)
+	}
+	if res.Name != newName {
+		return fmt.Errorf("the server kept the name %s", res.Name)
+	}
+	return nil
+}
+
+// DeleteWebResource deletes a web resource. If forms or other components
+// still use it, nothing is deleted and a *DependentsError lists them.
+func (c *Client) DeleteWebResource(webResourceID string) error {
+	// Check first: the server's own refusal only says that a dependency exists
+	dependents, err := c.GetDependenciesForDelete(webResourceID)
+	if err == nil && len(dependents) > 0 {
+		return &DependentsError{Dependents: dependents}
+	}
+
+	_, err = c.doRequest("DELETE", "/webresourceset("+webResourceID+")", nil)
+	return err
+}
+
+// GetWebResourceContent retrieves the base64 encoded content of a web resource
+func (c *Client) GetWebResourceContent(webResourceID string) (string, error) {
+	path := "/webresourceset(" + webResourceID + ")?$select=content"
+
+	body, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	return response.Content, nil
+}
+
+// GetWebResourceAudit retrieves the last modified date and user of a web resource
+func (c *Client) GetWebResourceAudit(webResourceID string) (*WebResourceAudit, error) {
+	path := "/webresourceset(" + webResourceID + ")?$select=modifiedon&$expand=" + url.QueryEscape("modifiedby($select=fullname)")
+
+	body, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var audit WebResourceAudit
+	if err := json.Unmarshal(body, &audit); err != nil {
+		return nil, err
+	}
+
+	return &audit, nil
+}
+
+// DeployedWebResource is the content of a web resource with its version and
+// who last changed it
+type DeployedWebResource struct {
+	Content string `json:"content"` // base64 encoded
+	Version int64  `json:"versionnumber"`
+	WebResourceAudit
+}
+
+// GetDeployedWebResource retrieves the content, version and last change of a
+// web resource in one request
+func (c *Client) GetDeployedWebResource(webResourceID string) (*DeployedWebResource, error) {
+	path := "/webresourceset(" + webResourceID + ")?$select=content,versionnumber,modifiedon&$expand=" + url.QueryEscape("modifiedby($select=fullname)")
+
+	body, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var deployed DeployedWebResource
+	if err := json.Unmarshal(body, &deployed); err != nil {
+		return nil, err
+	}
+
+	return &deployed, nil
 }
 
 // UpdateWebResourceContent updates the content of a web resource
@@ -111,7 +389,7 @@ func (c *Client) UpdateWebResourceContent(webResourceID, base64Content string) e
 		"content": base64Content,
 	}
 
-	_, err := c.doRequest("PATCH", path, payload)
+	_, err := c.doPublishRequest("PATCH", path, payload)
 	return err
 }
 
@@ -126,7 +404,7 @@ func (c *Client) CreateWebResource(name, displayName, base64Content string, reso
 		WebResourceType: int(resourceType),
 	}
 
-	body, err := c.doRequest("POST", path, payload)
+	body, err := c.doPublishRequest("POST", path, payload)
 	if err != nil {
 		return "", fmt.Errorf("failed to create web resource: %w", err)
 	}
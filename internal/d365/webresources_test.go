@@ -0,0 +1,93 @@
+package d365
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestListWebResourcesPaging serves the web resource list in pages linked by
+// @odata.nextLink and checks that every page ends up in the result
+func TestListWebResourcesPaging(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   map[string]string // response body by $skiptoken; {{org}} is the org URL
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "one page",
+			pages: map[string]string{
+				"": `{"value": [{"name": "new_/a.js"}, {"name": "new_/b.js"}]}`,
+			},
+			want: []string{"new_/a.js", "new_/b.js"},
+		},
+		{
+			name: "two pages",
+			pages: map[string]string{
+				"": `{"value": [{"name": "new_/a.js"}, {"name": "new_/b.js"}],
+					"@odata.nextLink": "{{org}}/api/data/v9.2/webresourceset?$orderby=name&$skiptoken=2"}`,
+				"2": `{"value": [{"name": "new_/c.js"}]}`,
+			},
+			want: []string{"new_/a.js", "new_/b.js", "new_/c.js"},
+		},
+		{
+			name: "second page fails",
+			pages: map[string]string{
+				"": `{"value": [{"name": "new_/a.js"}],
+					"@odata.nextLink": "{{org}}/api/data/v9.2/webresourceset?$orderby=name&$skiptoken=missing"}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "next link outside the org",
+			pages: map[string]string{
+				"": `{"value": [{"name": "new_/a.js"}],
+					"@odata.nextLink": "https://elsewhere.example.com/api/data/v9.2/webresourceset?$skiptoken=2"}`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				if r.URL.Path != "/api/data/v9.2/webresourceset" || query.Get("$orderby") != "name" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				body, ok := tt.pages[query.Get("$skiptoken")]
+				if !ok {
+					http.Error(w, `{"error": {"message": "page not found"}}`, http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(strings.ReplaceAll(body, "{{org}}", server.URL)))
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, "token", ClientOptions{})
+			resources, err := client.ListWebResources(false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %d resources, want an error", len(resources))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, r := range resources {
+				got = append(got, r.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package diff
+
+import "strings"
+
+// Op is the kind of change a line represents
+type Op int
+
+const (
+	Equal  Op = iota
+	Insert    // only in the new text
+	Delete    // only in the old text
+	Skip      // marks unchanged lines left out of a unified view
+)
+
+// Line is one line of a diff
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the LCS table; larger changes fall back to replacing the
+// whole changed region, which is still a correct diff
+const maxCells = 4_000_000
+
+// Lines returns the line diff that turns oldText into newText
+func Lines(oldText, newText string) []Line {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	// Common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	for _, text := range a[:prefix] {
+		lines = append(lines, Line{Equal, text})
+	}
+	lines = append(lines, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Equal, text})
+	}
+	return lines
+}
+
+// middle diffs the changed region with a longest common subsequence table
+func middle(a, b []string) []Line {
+	var lines []Line
+	if len(a)*len(b) > maxCells {
+		for _, text := range a {
+			lines = append(lines, Line{Delete, text})
+		}
+		for _, text := range b {
+			lines = append(lines, Line{Insert, text})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Insert, b[j]})
+	}
+	return lines
+}
+
+// Unified keeps only the changed lines and up to context unchanged lines
+// around them, replacing each gap with a Skip line
+func Unified(lines []Line, context int) []Line {
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Op == Equal {
+			continue
+		}
+		for k := max(0, i-context); k <= min(len(lines)-1, i+context); k++ {
+			keep[k] = true
+		}
+	}
+
+	var out []Line
+	skipped := false
+	for i, line := range lines {
+		if keep[i] {
+			out = append(out, line)
+			skipped = false
+		} else if !skipped {
+			out = append(out, Line{Op: Skip})
+			skipped = true
+		}
+	}
+	return out
+}
+
+// Changed reports whether a diff contains any insertions or deletions
+func Changed(lines []Line) bool {
+	for _, line := range lines {
+		if line.Op == Insert || line.Op == Delete {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits text into lines, treating CRLF like LF
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,50 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/auth"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// New returns an HTTP client that goes through the proxy named by
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY and, if caBundle is set, also trusts
+// the PEM certificates in it, e.g. the root of a TLS-inspecting proxy
+func New(caBundle string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", caBundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// Configure sends Web API and sign-in requests through a client built from
+// the config's network settings
+func Configure(cfg *config.Config) error {
+	client, err := New(cfg.CABundle)
+	if err != nil {
+		return err
+	}
+	d365.SetHTTPClient(client)
+	auth.SetHTTPClient(client)
+	return nil
+}
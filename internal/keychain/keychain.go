@@ -0,0 +1,107 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// service names the entries in the macOS Keychain, Windows Credential Manager
+// or Secret Service; each environment's token is stored under its name
+var service = "d365tui"
+
+// SetNamespace keeps the entries of a config directory other than the
+// default apart, so instances with separate configs don't share tokens
+func SetNamespace(configDir string) {
+	service = "d365tui:" + configDir
+}
+
+// ErrNotFound is returned by Get when an environment has no entry
+var ErrNotFound = keyring.ErrNotFound
+
+// Get returns the secret stored for an environment
+func Get(envName string) (string, error) {
+	return keyring.Get(service, envName)
+}
+
+// Set stores the secret of an environment. It fails when no keychain is
+// available, or when the secret is too large for it (Windows limits entries
+// to 2560 bytes).
+func Set(envName, secret string) error {
+	if err := keyring.Set(service, envName, secret); err != nil {
+		return err
+	}
+	updateIndex(envName, true)
+	return nil
+}
+
+// Delete removes the entry of an environment; a missing entry is not an error
+func Delete(envName string) error {
+	err := keyring.Delete(service, envName)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	updateIndex(envName, false)
+	return nil
+}
+
+// indexName is the entry listing the names of the others, as go-keyring
+// can't enumerate a service's entries
+const indexName = "d365tui:index"
+
+// indexMu serializes updates of the index within this process
+var indexMu sync.Mutex
+
+// Names returns the names of the entries stored through Set. Entries stored
+// by versions before the index was kept are not included.
+func Names() ([]string, error) {
+	data, err := keyring.Get(service, indexName)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(data), &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// updateIndex adds a name to the index or removes it. Failures are ignored:
+// the index only serves to find entries to clean up.
+func updateIndex(name string, present bool) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	names, err := Names()
+	if err != nil || slices.Contains(names, name) == present {
+		return
+	}
+	if present {
+		names = append(names, name)
+	} else {
+		names = slices.DeleteFunc(names, func(n string) bool { return n == name })
+	}
+	if data, err := json.Marshal(names); err == nil {
+		keyring.Set(service, indexName, string(data))
+	}
+}
+
+// Move stores the entry of a renamed environment under its new name
+func Move(oldName, newName string) error {
+	secret, err := keyring.Get(service, oldName)
+	if err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := Set(newName, secret); err != nil {
+		return err
+	}
+	return Delete(oldName)
+}
@@ -0,0 +1,121 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// DirectoryResult describes a file published through a directory binding
+type DirectoryResult struct {
+	Resource d365.WebResource
+	Created  bool // the web resource did not exist and was created
+	Result   *Result
+}
+
+// PublishDirectoryFile publishes a file covered by a directory binding. The
+// web resource named by the binding's template is created if it doesn't
+// exist, and the file is bound to it so later changes publish like any other
+// bound file.
+func PublishDirectoryFile(client *d365.Client, cfg *config.Config, dir config.DirectoryBinding, localPath string) (*DirectoryResult, error) {
+	if client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	name, ok := dir.ResourceName(localPath)
+	if !ok {
+		return nil, fmt.Errorf("%s is not covered by the binding of %s", localPath, dir.LocalDir)
+	}
+
+	res, err := client.GetWebResourceByName(name)
+	if errors.Is(err, d365.ErrNotFound) {
+		return createDirectoryFile(client, cfg, dir.Environment, name, localPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	binding := config.Binding{
+		Environment:      dir.Environment,
+		LocalPath:        localPath,
+		WebResourceName:  res.Name,
+		WebResourceID:    res.ID,
+		LastKnownVersion: "1.0.0",
+		AutoPublish:      true,
+		DisplayName:      res.DisplayName,
+		ServerVersion:    res.Version,
+	}
+	if err := cfg.AddBinding(binding); err != nil {
+		return nil, fmt.Errorf("save binding: %w", err)
+	}
+
+	result, err := Publish(client, cfg, binding)
+	if err != nil {
+		return nil, err
+	}
+	return &DirectoryResult{Resource: *res, Result: result}, nil
+}
+
+// createDirectoryFile creates, publishes and binds the web resource of a new file
+func createDirectoryFile(client *d365.Client, cfg *config.Config, envName, name, localPath string) (*DirectoryResult, error) {
+	resourceType, err := d365.GetWebResourceTypeFromExtension(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", localPath, err)
+	}
+
+	env := cfg.GetEnvironment(envName)
+	if env != nil {
+		if err := RunPrePublishHooks(env.PrePublish, localPath); err != nil {
+			return nil, err
+		}
+	}
+	encoded, replaced := PrepareContent(env, localPath, content)
+
+	displayName := path.Base(name)
+	id, err := client.CreateWebResource(name, displayName, encoded, resourceType)
+	if err == nil {
+		err = client.PublishWebResource(id)
+	}
+	if err != nil {
+		appendHistory(HistoryEntry{Environment: envName, Resource: name, LocalPath: localPath, Error: err.Error()})
+		return nil, err
+	}
+
+	hash := ContentHash(encoded)
+	binding := config.Binding{
+		Environment:       envName,
+		LocalPath:         localPath,
+		WebResourceName:   name,
+		WebResourceID:     id,
+		LastKnownVersion:  "1.0.0",
+		AutoPublish:       true,
+		LastPublishedHash: hash,
+		DisplayName:       displayName,
+	}
+	if err := cfg.AddBinding(binding); err != nil {
+		return nil, fmt.Errorf("save binding: %w", err)
+	}
+	recordServerVersion(client, cfg, binding)
+	appendHistory(HistoryEntry{Environment: envName, Resource: name, LocalPath: localPath, NewVersion: binding.LastKnownVersion})
+
+	result := &Result{Replaced: replaced, Hash: hash, Version: binding.LastKnownVersion}
+	if LooksBase64(content) {
+		result.Warning = encodingWarning
+	}
+	if err := addToDefaultSolution(client, cfg, binding); err != nil {
+		result.addWarning(err.Error())
+	}
+	return &DirectoryResult{
+		Resource: d365.WebResource{ID: id, Name: name, DisplayName: displayName, Type: resourceType},
+		Created:  true,
+		Result:   result,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package publisher
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// minEncodedLength keeps short words that happen to be valid base64 from
+// triggering the double-encoding warning
+const minEncodedLength = 24
+
+// LooksBase64 reports whether content is already base64 of plausible text or
+// binary data. Publishing such a file encodes it a second time, so the
+// deployed resource is garbage. It is a heuristic: some files legitimately
+// look like base64.
+func LooksBase64(content []byte) bool {
+	compact := strings.Join(strings.Fields(string(content)), "")
+	if len(compact) < minEncodedLength || len(compact)%4 != 0 {
+		return false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(compact)
+	if err != nil {
+		return false
+	}
+	// Random bytes sniff as octet-stream; text and known file formats don't
+	return http.DetectContentType(decoded) != "application/octet-stream"
+}
+
+// EncodingWarning returns a warning for a file that looks already base64
+// encoded, or an empty string
+func EncodingWarning(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return ContentWarning(content)
+}
+
+// ContentWarning returns the warning of EncodingWarning for content read
+// already, e.g. to show it before publishing
+func ContentWarning(content []byte) string {
+	if !LooksBase64(content) {
+		return ""
+	}
+	return encodingWarning
+}
+
+const encodingWarning = "content looks already base64 encoded and will be encoded again"
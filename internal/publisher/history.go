@@ -0,0 +1,102 @@
+package publisher
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+)
+
+// HistoryEntry is one publish attempt in the publish log
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	Environment string    `json:"environment"`
+	Resource    string    `json:"resource"`
+	LocalPath   string    `json:"localPath,omitempty"`
+	OldVersion  string    `json:"oldVersion,omitempty"`
+	NewVersion  string    `json:"newVersion,omitempty"`
+	Error       string    `json:"error,omitempty"` // empty when the publish succeeded
+}
+
+// HistoryPath returns the path of the append-only publish log
+func HistoryPath() string {
+	return filepath.Join(config.GetConfigDir(), "publish-log.jsonl")
+}
+
+// logPublish appends a publish attempt of a bound file to the publish log
+func logPublish(binding config.Binding, result *Result, err error) {
+	entry := HistoryEntry{
+		Environment: binding.Environment,
+		Resource:    binding.WebResourceName,
+		LocalPath:   binding.LocalPath,
+		OldVersion:  binding.LastKnownVersion,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else if result != nil {
+		entry.NewVersion = result.Version
+	}
+	appendHistory(entry)
+}
+
+// appendHistory writes an entry to the publish log. The log is an audit
+// trail, not part of publishing, so failing to write it is not an error.
+func appendHistory(entry HistoryEntry) {
+	if config.ReadOnly() {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(config.GetConfigDir(), 0700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(HistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// History returns up to limit of the most recent publish log entries, newest
+// first. Lines that can't be parsed are skipped.
+func History(limit int) ([]HistoryEntry, error) {
+	f, err := os.Open(HistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
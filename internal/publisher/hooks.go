@@ -0,0 +1,86 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+)
+
+// RunPrePublishHooks runs each validation command against a file. The file path
+// is available as {file} in the command and as the D365TUI_FILE environment
+// variable. A non-zero exit aborts with the command's output.
+func RunPrePublishHooks(commands []string, path string) error {
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+
+		cmd := shellCommand(context.Background(), strings.ReplaceAll(command, "{file}", path))
+		cmd.Dir = filepath.Dir(path)
+		cmd.Env = append(os.Environ(), "D365TUI_FILE="+path)
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			detail := strings.Join(strings.Fields(string(output)), " ")
+			if detail == "" {
+				detail = err.Error()
+			}
+			return fmt.Errorf("pre-publish check %q failed: %s", command, detail)
+		}
+	}
+	return nil
+}
+
+// RunBuild runs a binding's build command in the folder of its file, which
+// is available as the D365TUI_FILE environment variable. A non-zero exit
+// aborts with the command's error output, and the build is stopped once it
+// takes longer than timeout.
+func RunBuild(binding config.Binding, timeout time.Duration) error {
+	command := strings.TrimSpace(binding.BuildCommand)
+	if command == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = filepath.Dir(binding.LocalPath)
+	cmd.Env = append(os.Environ(), "D365TUI_FILE="+binding.LocalPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("build %q timed out after %s", command, timeout)
+		}
+		detail := strings.Join(strings.Fields(stderr.String()), " ")
+		if detail == "" {
+			detail = err.Error()
+		}
+		return fmt.Errorf("build %q failed: %s", command, detail)
+	}
+	return nil
+}
+
+// shellCommand wraps a command line in the platform shell, killed when ctx
+// is done
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// Don't wait on output pipes held open by children of the killed shell
+	cmd.WaitDelay = time.Second
+	return cmd
+}
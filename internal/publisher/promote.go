@@ -0,0 +1,82 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// PromotedFile is a bound file whose content is captured once so every
+// environment in a promotion receives the same bytes
+type PromotedFile struct {
+	Name      string // web resource name, used to find the resource in each environment
+	LocalPath string
+	Content   []byte
+}
+
+// CaptureFile reads a bound file for promotion
+func CaptureFile(binding config.Binding) (PromotedFile, error) {
+	content, err := ReadBinding(binding)
+	if err != nil {
+		return PromotedFile{}, err
+	}
+	return PromotedFile{Name: binding.WebResourceName, LocalPath: binding.LocalPath, Content: content}, nil
+}
+
+// Promote uploads captured content to the web resource of the same name in
+// another environment and publishes it. The environment's pre-publish checks,
+// tokens and snapshots apply as they would for a bound file. The publish log
+// records the server versions before and after, as the target environment
+// keeps no version of its own for the file.
+func Promote(client *d365.Client, cfg *config.Config, env *config.Environment, file PromotedFile) error {
+	if client == nil {
+		return fmt.Errorf("not connected")
+	}
+	entry := HistoryEntry{Environment: env.Name, Resource: file.Name, LocalPath: file.LocalPath}
+	err := promote(client, cfg, env, file, &entry)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	appendHistory(entry)
+	return err
+}
+
+// promote uploads and publishes promoted content, noting the server versions
+// in entry
+func promote(client *d365.Client, cfg *config.Config, env *config.Environment, file PromotedFile, entry *HistoryEntry) error {
+
+	if err := RunPrePublishHooks(env.PrePublish, file.LocalPath); err != nil {
+		return err
+	}
+
+	res, err := client.GetWebResourceByName(file.Name)
+	if errors.Is(err, d365.ErrNotFound) {
+		return fmt.Errorf("%s does not exist in %s", file.Name, env.Name)
+	}
+	if err != nil {
+		return err
+	}
+	entry.OldVersion = strconv.FormatInt(res.Version, 10)
+
+	if env.Protected {
+		target := config.Binding{Environment: env.Name, WebResourceName: res.Name, WebResourceID: res.ID}
+		if _, err := TakeSnapshot(client, cfg, target); err != nil {
+			return fmt.Errorf("%s is protected and the snapshot failed, so nothing was published: %w", env.Name, err)
+		}
+	}
+
+	encoded, _ := PrepareContent(env, file.LocalPath, file.Content)
+	if err := client.UpdateWebResourceContent(res.ID, encoded); err != nil {
+		return err
+	}
+	if err := client.PublishWebResource(res.ID); err != nil {
+		return err
+	}
+	if published, err := client.GetWebResource(res.ID); err == nil {
+		entry.NewVersion = strconv.FormatInt(published.Version, 10)
+	}
+	return nil
+}
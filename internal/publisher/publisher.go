@@ -0,0 +1,340 @@
+package publisher
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// Result describes a successful publish
+type Result struct {
+	Replaced int    // number of environment tokens substituted
+	Hash     string // hash of the published content
+	Version  string // new local version of the binding
+	Warning  string // soft warning about the content, e.g. likely double encoding
+	Snapshot string // path of the pre-publish snapshot for protected environments
+	// Unchanged is set when the content matches the last publish, in which
+	// case nothing was sent to the server
+	Unchanged bool
+
+	previous []byte // server content the upload replaced, kept for UndoLast
+}
+
+// ServerChangedError is returned when a web resource was changed on the
+// server since it was last bound or published, e.g. in the maker portal
+type ServerChangedError struct {
+	Name    string
+	Known   int64                 // version recorded in the binding
+	Current int64                 // version on the server
+	Audit   d365.WebResourceAudit // who made the change and when
+}
+
+func (e *ServerChangedError) Error() string {
+	return fmt.Sprintf("%s was changed on the server since your last publish", e.Name)
+}
+
+// Publish uploads a bound file to its web resource, publishes it and records
+// the new version in the config. Content identical to the last publish is
+// skipped and reported as Unchanged. If the resource changed on the server
+// since it was last published, a ServerChangedError is returned instead.
+// Every attempt except a skipped one is written to the publish log, including
+// those that fail before reaching the server, e.g. in a build or hook.
+func Publish(client *d365.Client, cfg *config.Config, binding config.Binding) (*Result, error) {
+	result, err := upload(client, cfg, binding)
+	if err == nil && result.Unchanged {
+		return result, nil
+	}
+	if err == nil {
+		err = client.PublishWebResource(binding.WebResourceID)
+	}
+	logPublish(binding, result, err)
+	if err != nil {
+		return nil, err
+	}
+
+	pushUndo(binding, result.previous)
+	cfg.RecordPublish(binding.Environment, binding.WebResourceID, result.Version, result.Hash)
+	recordServerVersion(client, cfg, binding)
+	if err := addToDefaultSolution(client, cfg, binding); err != nil {
+		result.addWarning(err.Error())
+	}
+	return result, nil
+}
+
+// addWarning appends a warning to any already on the result
+func (r *Result) addWarning(warning string) {
+	if r.Warning != "" {
+		r.Warning += "; "
+	}
+	r.Warning += warning
+}
+
+// systemSolutions are solutions every unmanaged resource belongs to, which
+// don't count as it being in a solution
+var systemSolutions = map[string]bool{"Default": true, "Active": true}
+
+// addToDefaultSolution adds a published resource to the default solution of
+// its environment, unless the binding records a solution. A resource that the
+// server already has in a solution is not added; that solution is recorded
+// instead, so the server is only asked once.
+func addToDefaultSolution(client *d365.Client, cfg *config.Config, binding config.Binding) error {
+	env := cfg.GetEnvironment(binding.Environment)
+	if binding.Solution != "" || env == nil || env.DefaultSolution == "" {
+		return nil
+	}
+	solutions, err := client.GetWebResourceSolutions([]string{binding.WebResourceID})
+	if err != nil {
+		return fmt.Errorf("published, but looking up its solutions failed: %w", err)
+	}
+	for _, name := range solutions[binding.WebResourceID] {
+		if !systemSolutions[name] {
+			return cfg.SetBindingSolution(binding.Environment, binding.WebResourceID, name)
+		}
+	}
+	if err := client.AddWebResourceToSolution(env.DefaultSolution, binding.WebResourceID); err != nil {
+		return fmt.Errorf("published, but adding to %s failed: %w", env.DefaultSolution, err)
+	}
+	return cfg.SetBindingSolution(binding.Environment, binding.WebResourceID, env.DefaultSolution)
+}
+
+// Republish publishes a bound file even if its content matches the last
+// publish. A resource changed on the server is still not overwritten.
+func Republish(client *d365.Client, cfg *config.Config, binding config.Binding) (*Result, error) {
+	binding.LastPublishedHash = ""
+	return Publish(client, cfg, binding)
+}
+
+// Overwrite publishes a bound file even if its content matches the last
+// publish or the resource was changed on the server
+func Overwrite(client *d365.Client, cfg *config.Config, binding config.Binding) (*Result, error) {
+	binding.LastPublishedHash = ""
+	binding.ServerVersion = 0
+	return Publish(client, cfg, binding)
+}
+
+// recordServerVersion stores the version the server gave a resource after
+// our upload or publish, as the baseline for detecting changes made elsewhere
+func recordServerVersion(client *d365.Client, cfg *config.Config, binding config.Binding) {
+	if res, err := client.GetWebResource(binding.WebResourceID); err == nil {
+		cfg.RecordServerVersion(binding.Environment, binding.WebResourceID, res.Version)
+	}
+}
+
+// BatchResult describes a PublishBatch run
+type BatchResult struct {
+	Published []config.Binding // bindings uploaded and published
+	Unchanged int              // bindings skipped because their content matched the last publish
+	Failed    []error          // upload and solution failures, one per binding
+}
+
+// PublishBatch uploads every changed binding individually and then publishes
+// them all with a single PublishXml call, instead of one call per resource.
+// If progress is not nil it is called after each binding is uploaded.
+func PublishBatch(client *d365.Client, cfg *config.Config, bindings []config.Binding, progress func(done, total int)) (*BatchResult, error) {
+	batch := &BatchResult{}
+	var ids []string
+	var uploaded []config.Binding
+	var results []*Result
+	for i, binding := range bindings {
+		if progress != nil && i > 0 {
+			progress(i, len(bindings))
+		}
+		result, err := upload(client, cfg, binding)
+		if err != nil {
+			logPublish(binding, nil, err)
+			batch.Failed = append(batch.Failed, fmt.Errorf("%s: %w", binding.WebResourceName, err))
+			continue
+		}
+		if result.Unchanged {
+			batch.Unchanged++
+			continue
+		}
+		ids = append(ids, binding.WebResourceID)
+		uploaded = append(uploaded, binding)
+		results = append(results, result)
+	}
+
+	if progress != nil {
+		progress(len(bindings), len(bindings))
+	}
+
+	if len(ids) == 0 {
+		return batch, nil
+	}
+	if err := client.PublishWebResources(ids); err != nil {
+		for _, binding := range uploaded {
+			logPublish(binding, nil, err)
+		}
+		return batch, err
+	}
+
+	for i, binding := range uploaded {
+		logPublish(binding, results[i], nil)
+		pushUndo(binding, results[i].previous)
+		cfg.RecordPublish(binding.Environment, binding.WebResourceID, results[i].Version, results[i].Hash)
+		recordServerVersion(client, cfg, binding)
+		batch.Published = append(batch.Published, binding)
+		if err := addToDefaultSolution(client, cfg, binding); err != nil {
+			batch.Failed = append(batch.Failed, fmt.Errorf("%s: %w", binding.WebResourceName, err))
+		}
+	}
+	return batch, nil
+}
+
+// upload runs the pre-publish checks and uploads a bound file's content
+// without publishing it
+func upload(client *d365.Client, cfg *config.Config, binding config.Binding) (*Result, error) {
+	if client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if err := RunBuild(binding, cfg.PublishTimeout()); err != nil {
+		return nil, err
+	}
+	content, err := ReadBinding(binding)
+	if err != nil {
+		return nil, err
+	}
+
+	env := cfg.GetEnvironment(binding.Environment)
+	encoded, replaced := PrepareContent(env, binding.LocalPath, content)
+
+	// Editors often touch files without changing them; skip the round trip
+	hash := ContentHash(encoded)
+	if binding.LastPublishedHash != "" && hash == binding.LastPublishedHash {
+		return &Result{Unchanged: true, Hash: hash, Version: binding.LastKnownVersion}, nil
+	}
+
+	// Don't clobber a teammate's edit made since our last publish
+	if binding.ServerVersion != 0 {
+		res, err := client.GetWebResource(binding.WebResourceID)
+		if err != nil {
+			return nil, err
+		}
+		if res.Version > binding.ServerVersion {
+			changed := &ServerChangedError{Name: binding.WebResourceName, Known: binding.ServerVersion, Current: res.Version}
+			changed.Audit.ModifiedOn = res.ModifiedOn
+			changed.Audit.ModifiedBy.FullName = res.ModifiedByName
+			return nil, changed
+		}
+	}
+
+	if env != nil {
+		for _, source := range binding.Sources() {
+			if err := RunPrePublishHooks(env.PrePublish, source); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Keep what is being replaced, for UndoLast and the snapshot of a
+	// protected environment
+	previous, err := serverContent(client, binding.WebResourceID)
+	var snapshot string
+	if env != nil && env.Protected {
+		if err == nil {
+			snapshot, err = saveSnapshot(cfg, binding, previous)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s is protected and the snapshot failed, so nothing was published: %w", env.Name, err)
+		}
+	}
+
+	if err := client.UpdateWebResourceContent(binding.WebResourceID, encoded); err != nil {
+		return nil, err
+	}
+	// The upload raises the server version whether or not the publish that
+	// follows succeeds, so record it now to not report our own upload as a
+	// change made elsewhere
+	recordServerVersion(client, cfg, binding)
+
+	result := &Result{
+		Replaced: replaced,
+		Hash:     hash,
+		Version:  IncrementVersion(binding.LastKnownVersion),
+		Snapshot: snapshot,
+		previous: previous,
+	}
+	if LooksBase64(content) {
+		result.Warning = encodingWarning
+	}
+	return result, nil
+}
+
+// ReadFile reads a file, retrying briefly to ride out atomic saves where
+// editors like Neovim delete the original and rename a temp file into place
+func ReadFile(path string) ([]byte, error) {
+	var content []byte
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		if attempt > 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		content, err = os.ReadFile(path)
+		if err == nil {
+			return content, nil
+		}
+	}
+	return nil, err
+}
+
+// ReadBinding reads the content published for a binding. The files of a
+// binding with several sources are joined so each starts on a new line.
+func ReadBinding(binding config.Binding) ([]byte, error) {
+	var content []byte
+	for _, source := range binding.Sources() {
+		data, err := ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", source, err)
+		}
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			content = append(content, '\n')
+		}
+		content = append(content, data...)
+	}
+	return content, nil
+}
+
+// PrepareContent substitutes the environment's tokens into text resources and
+// base64 encodes the result. The local file itself is never modified.
+func PrepareContent(env *config.Environment, localPath string, content []byte) (string, int) {
+	replaced := 0
+	if resourceType, err := d365.GetWebResourceTypeFromExtension(localPath); err == nil && resourceType.IsText() {
+		content, replaced = env.ApplyTokens(content)
+	}
+	return base64.StdEncoding.EncodeToString(content), replaced
+}
+
+// ContentHash returns a stable hash of base64 encoded web resource content
+func ContentHash(encoded string) string {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		data = []byte(encoded)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// IncrementVersion bumps the patch component of an x.y.z version
+func IncrementVersion(version string) string {
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return "1.0.1"
+	}
+
+	minor, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "1.0.1"
+	}
+
+	parts[2] = strconv.Itoa(minor + 1)
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,170 @@
+package publisher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// snapshotTimeFormat names snapshot files so they sort chronologically. The
+// milliseconds follow a comma rather than a dot, which would be taken for the
+// extension of a resource name without one.
+const snapshotTimeFormat = "20060102-150405,000"
+
+// legacySnapshotTimeFormat named snapshots to the second, so snapshots taken
+// within one second overwrote each other; such files are still listed
+const legacySnapshotTimeFormat = "20060102-150405"
+
+// Snapshot is an archived copy of a web resource's server content
+type Snapshot struct {
+	Path string
+	Time time.Time
+}
+
+// snapshotDir returns the folder holding the snapshots of one resource
+func snapshotDir(cfg *config.Config, envName, resourceName string) string {
+	safe := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(resourceName)
+	return filepath.Join(cfg.BackupRoot(), strings.ReplaceAll(envName, "/", "_"), safe)
+}
+
+// TakeSnapshot downloads the current server content of a bound resource into
+// the backup folder and prunes snapshots beyond the retention limit
+func TakeSnapshot(client *d365.Client, cfg *config.Config, binding config.Binding) (string, error) {
+	content, err := serverContent(client, binding.WebResourceID)
+	if err != nil {
+		return "", err
+	}
+	return saveSnapshot(cfg, binding, content)
+}
+
+// serverContent downloads and decodes the current content of a web resource
+func serverContent(client *d365.Client, id string) ([]byte, error) {
+	encoded, err := client.GetWebResourceContent(id)
+	if err != nil {
+		return nil, fmt.Errorf("download current content: %w", err)
+	}
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode current content: %w", err)
+	}
+	return content, nil
+}
+
+// saveSnapshot writes server content into the backup folder and prunes
+// snapshots beyond the retention limit
+func saveSnapshot(cfg *config.Config, binding config.Binding, content []byte) (string, error) {
+	dir := snapshotDir(cfg, binding.Environment, binding.WebResourceName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	target, err := createSnapshotFile(dir, path.Ext(binding.WebResourceName), content)
+	if err != nil {
+		return "", err
+	}
+
+	snapshots, err := Snapshots(cfg, binding.Environment, binding.WebResourceName)
+	if err != nil {
+		return target, nil
+	}
+	for _, old := range snapshots[min(len(snapshots), cfg.BackupLimit()):] {
+		os.Remove(old.Path)
+	}
+	return target, nil
+}
+
+// createSnapshotFile writes content to a new file named after the current
+// time, moving on a millisecond if a snapshot of that time already exists
+func createSnapshotFile(dir, ext string, content []byte) (string, error) {
+	stamp := time.Now()
+	for {
+		target := filepath.Join(dir, stamp.Format(snapshotTimeFormat)+ext)
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			stamp = stamp.Add(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(content); err != nil {
+			f.Close()
+			return "", err
+		}
+		return target, f.Close()
+	}
+}
+
+// Snapshots lists the snapshots of a resource, newest first
+func Snapshots(cfg *config.Config, envName, resourceName string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(snapshotDir(cfg, envName, resourceName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		stamp := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		t, err := time.ParseInLocation(snapshotTimeFormat, stamp, time.Local)
+		if err != nil {
+			if t, err = time.ParseInLocation(legacySnapshotTimeFormat, stamp, time.Local); err != nil {
+				continue
+			}
+		}
+		snapshots = append(snapshots, Snapshot{Path: filepath.Join(snapshotDir(cfg, envName, resourceName), entry.Name()), Time: t})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.After(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// Restore uploads a snapshot back to its web resource and publishes it. The
+// content replacing it is snapshotted first, so a restore can be undone too.
+// The snapshot is uploaded as is; it already holds the environment's tokens.
+func Restore(client *d365.Client, cfg *config.Config, binding config.Binding, snapshot Snapshot) error {
+	if client == nil {
+		return fmt.Errorf("not connected")
+	}
+	version, err := restore(client, cfg, binding, snapshot)
+	logged := binding
+	logged.LocalPath = snapshot.Path
+	logPublish(logged, &Result{Version: version}, err)
+	return err
+}
+
+// restore uploads and publishes a snapshot and returns the new version
+func restore(client *d365.Client, cfg *config.Config, binding config.Binding, snapshot Snapshot) (string, error) {
+	content, err := os.ReadFile(snapshot.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := TakeSnapshot(client, cfg, binding); err != nil {
+		return "", fmt.Errorf("snapshot before restore: %w", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(content)
+	if err := client.UpdateWebResourceContent(binding.WebResourceID, encoded); err != nil {
+		return "", err
+	}
+	if err := client.PublishWebResource(binding.WebResourceID); err != nil {
+		return "", err
+	}
+
+	version := IncrementVersion(binding.LastKnownVersion)
+	cfg.RecordPublish(binding.Environment, binding.WebResourceID, version, ContentHash(encoded))
+	recordServerVersion(client, cfg, binding)
+	return version, nil
+}
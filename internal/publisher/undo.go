@@ -0,0 +1,123 @@
+package publisher
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// UndoLimit is how many publishes are remembered for UndoLast
+const UndoLimit = 10
+
+// undoEntry is the server content a publish replaced
+type undoEntry struct {
+	binding config.Binding
+	content []byte
+}
+
+// undoRing holds the content replaced by the most recent publishes, oldest
+// first. It lives in memory only, for the current session.
+var (
+	undoMu   sync.Mutex
+	undoRing []undoEntry
+)
+
+// pushUndo remembers the content a publish replaced. Without it, e.g. when
+// downloading it failed, the publish simply can't be undone.
+func pushUndo(binding config.Binding, previous []byte) {
+	if previous == nil {
+		return
+	}
+	undoMu.Lock()
+	defer undoMu.Unlock()
+	undoRing = append(undoRing, undoEntry{binding: binding, content: previous})
+	if len(undoRing) > UndoLimit {
+		undoRing = undoRing[len(undoRing)-UndoLimit:]
+	}
+}
+
+// popUndo takes the most recent entry of an environment off the ring
+func popUndo(envName string) (undoEntry, bool) {
+	undoMu.Lock()
+	defer undoMu.Unlock()
+	for i := len(undoRing) - 1; i >= 0; i-- {
+		if undoRing[i].binding.Environment == envName {
+			entry := undoRing[i]
+			undoRing = append(undoRing[:i], undoRing[i+1:]...)
+			return entry, true
+		}
+	}
+	return undoEntry{}, false
+}
+
+// UndoLast reverts the most recent publish in an environment: the server
+// content it replaced is uploaded and published again. Calling it again
+// reverts the publish before that, up to UndoLimit publishes back. If the
+// undo fails before the old content is uploaded, e.g. with a ServerChangedError
+// because the resource was changed on the server since, the publish stays
+// undoable.
+func UndoLast(client *d365.Client, cfg *config.Config, envName string) (config.Binding, error) {
+	if client == nil {
+		return config.Binding{}, fmt.Errorf("not connected")
+	}
+	entry, ok := popUndo(envName)
+	if !ok {
+		return config.Binding{}, fmt.Errorf("nothing to undo in %s", envName)
+	}
+
+	binding := entry.binding
+	if current := cfg.GetBinding(envName, binding.WebResourceID); current != nil {
+		binding = *current
+	}
+	version, uploaded, err := undo(client, cfg, binding, entry.content)
+	if err != nil && !uploaded {
+		pushUndo(entry.binding, entry.content)
+	}
+	var changed *ServerChangedError
+	if errors.As(err, &changed) {
+		return binding, err
+	}
+	logged := binding
+	logged.LocalPath = ""
+	logPublish(logged, &Result{Version: version}, err)
+	return binding, err
+}
+
+// undo uploads and publishes replaced content and returns the new version.
+// uploaded reports whether the content reached the server, even if the
+// publish that follows failed.
+func undo(client *d365.Client, cfg *config.Config, binding config.Binding, content []byte) (version string, uploaded bool, err error) {
+	if binding.ServerVersion != 0 {
+		res, err := client.GetWebResource(binding.WebResourceID)
+		if err != nil {
+			return "", false, err
+		}
+		if res.Version > binding.ServerVersion {
+			return "", false, &ServerChangedError{Name: binding.WebResourceName, Known: binding.ServerVersion, Current: res.Version}
+		}
+	}
+
+	if env := cfg.GetEnvironment(binding.Environment); env != nil && env.Protected {
+		if _, err := TakeSnapshot(client, cfg, binding); err != nil {
+			return "", false, fmt.Errorf("snapshot before undo: %w", err)
+		}
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(content)
+	if err := client.UpdateWebResourceContent(binding.WebResourceID, encoded); err != nil {
+		return "", false, err
+	}
+	recordServerVersion(client, cfg, binding)
+	if err := client.PublishWebResource(binding.WebResourceID); err != nil {
+		return "", true, err
+	}
+
+	version = IncrementVersion(binding.LastKnownVersion)
+	cfg.RecordPublish(binding.Environment, binding.WebResourceID, version, ContentHash(encoded))
+	recordServerVersion(client, cfg, binding)
+	return version, true, nil
+}
@@ -0,0 +1,99 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// SyncStatus describes how a bound file compares to the deployed web resource
+type SyncStatus int
+
+const (
+	SyncInSync SyncStatus = iota
+	SyncLocalAhead
+	SyncConflict
+	SyncMissing
+	SyncError
+)
+
+// String returns the display label for the sync status
+func (s SyncStatus) String() string {
+	switch s {
+	case SyncInSync:
+		return "In sync"
+	case SyncLocalAhead:
+		return "Local ahead"
+	case SyncConflict:
+		return "Conflict"
+	case SyncMissing:
+		return "Missing"
+	case SyncError:
+		return "Error"
+	}
+	return "Unknown"
+}
+
+// SyncResult holds the outcome of comparing one binding against the server
+type SyncResult struct {
+	Binding config.Binding
+	Status  SyncStatus
+	Err     error
+	Audit   *d365.WebResourceAudit // who changed the server copy, set for conflicts
+}
+
+// CheckSync compares the content a bound file would publish with the content
+// deployed on the server. A difference is a conflict when the server copy
+// changed since the last publish, as someone else deployed since: its version
+// differs from the one recorded then, or, for bindings without a recorded
+// version, its content differs from the last publish.
+func CheckSync(client *d365.Client, env *config.Environment, binding config.Binding) SyncResult {
+	result := SyncResult{Binding: binding}
+	if client == nil {
+		result.Status = SyncError
+		result.Err = fmt.Errorf("not connected")
+		return result
+	}
+
+	content, err := ReadBinding(binding)
+	if err != nil {
+		result.Status = SyncMissing
+		result.Err = err
+		return result
+	}
+	local, _ := PrepareContent(env, binding.LocalPath, content)
+
+	deployed, err := client.GetDeployedWebResource(binding.WebResourceID)
+	if err != nil {
+		if errors.Is(err, d365.ErrNotFound) {
+			result.Status = SyncMissing
+		} else {
+			result.Status = SyncError
+		}
+		result.Err = err
+		return result
+	}
+
+	remoteHash := ContentHash(deployed.Content)
+	switch {
+	case ContentHash(local) == remoteHash:
+		result.Status = SyncInSync
+	case serverChanged(binding, deployed.Version, remoteHash):
+		result.Status = SyncConflict
+		result.Audit = &deployed.WebResourceAudit
+	default:
+		result.Status = SyncLocalAhead
+	}
+	return result
+}
+
+// serverChanged reports whether a resource changed on the server since the
+// binding was last published or bound
+func serverChanged(binding config.Binding, version int64, hash string) bool {
+	if binding.ServerVersion != 0 {
+		return version != binding.ServerVersion
+	}
+	return binding.LastPublishedHash != "" && hash != binding.LastPublishedHash
+}
@@ -0,0 +1,137 @@
+package solution
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+)
+
+// WebResource is a web resource entry in a solution export
+type WebResource struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Type        d365.WebResourceType
+	FileName    string // path of the content inside the zip
+}
+
+// customizations mirrors the parts of customizations.xml that describe web resources
+type customizations struct {
+	WebResources []struct {
+		WebResourceID   string `xml:"WebResourceId"`
+		Name            string `xml:"Name"`
+		DisplayName     string `xml:"DisplayName"`
+		WebResourceType int    `xml:"WebResourceType"`
+		FileName        string `xml:"FileName"`
+	} `xml:"WebResources>WebResource"`
+}
+
+// Archive is an opened solution export zip
+type Archive struct {
+	zip   *zip.ReadCloser
+	files map[string]*zip.File
+}
+
+// Open opens a solution export zip
+func Open(zipPath string) (*Archive, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{zip: r, files: make(map[string]*zip.File, len(r.File))}
+	for _, f := range r.File {
+		a.files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+	return a, nil
+}
+
+// Close closes the underlying zip file
+func (a *Archive) Close() error {
+	return a.zip.Close()
+}
+
+// WebResources lists the web resources declared in customizations.xml
+func (a *Archive) WebResources() ([]WebResource, error) {
+	f, ok := a.files["customizations.xml"]
+	if !ok {
+		return nil, errors.New("customizations.xml not found; is this a solution export?")
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var c customizations
+	if err := xml.NewDecoder(rc).Decode(&c); err != nil {
+		return nil, fmt.Errorf("parse customizations.xml: %w", err)
+	}
+
+	resources := make([]WebResource, 0, len(c.WebResources))
+	for _, wr := range c.WebResources {
+		resources = append(resources, WebResource{
+			ID:          strings.Trim(wr.WebResourceID, "{}"),
+			Name:        wr.Name,
+			DisplayName: wr.DisplayName,
+			Type:        d365.WebResourceType(wr.WebResourceType),
+			FileName:    strings.TrimPrefix(wr.FileName, "/"),
+		})
+	}
+	return resources, nil
+}
+
+// LocalPath returns where a web resource is extracted under dir, refusing
+// names that would escape it
+func LocalPath(dir string, wr WebResource) (string, error) {
+	return NamePath(dir, wr.Name)
+}
+
+// NamePath returns the path under dir that mirrors a web resource name, e.g.
+// new_/scripts/form.js, refusing names that would escape dir
+func NamePath(dir, name string) (string, error) {
+	clean := path.Clean("/" + name)
+	target := filepath.Join(dir, filepath.FromSlash(clean))
+	rel, err := filepath.Rel(dir, target)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("unsafe web resource name %q", name)
+	}
+	return target, nil
+}
+
+// Extract writes the content of a web resource to target, creating parent directories
+func (a *Archive) Extract(wr WebResource, target string) error {
+	f, ok := a.files[wr.FileName]
+	if !ok {
+		return fmt.Errorf("content for %s not found in zip (%s)", wr.Name, wr.FileName)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,361 @@
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// tabScope limits a list action to one of the tabs
+type tabScope int
+
+const (
+	bothTabs tabScope = iota
+	bindTabOnly
+	fileListTabOnly
+)
+
+// keyAction is one thing a key does on a screen
+type keyAction struct {
+	name  string   // identifies the action
+	keys  []string // as tea.KeyMsg.String() reports them
+	help  string   // description in the help overlay
+	short string   // label in the help line; empty leaves the action out of it
+	tab   tabScope // StateList only
+}
+
+// keyScreen lists the actions of a state, in the order they are shown
+type keyScreen struct {
+	title   string
+	actions []keyAction
+}
+
+// keymap is the single source of the keys of every screen. The help overlay
+// and the help lines below each screen are generated from it.
+var keymap = map[State]keyScreen{
+	StateEnvironmentSelect: {title: "Environments", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Move up", short: "navigate"},
+		{name: "down", keys: []string{"down", "j"}, help: "Move down"},
+		{name: "select", keys: []string{"enter"}, help: "Open the environment", short: "select"},
+		{name: "add", keys: []string{"a"}, help: "Add an environment", short: "add"},
+		{name: "edit", keys: []string{"e"}, help: "Edit the name and URL", short: "edit"},
+		{name: "delete", keys: []string{"d"}, help: "Delete the environment", short: "delete"},
+		{name: "prefix", keys: []string{"p"}, help: "Set the publisher prefix", short: "prefix"},
+		{name: "signOut", keys: []string{"L"}, help: "Sign out and remove the stored token", short: "sign out"},
+		{name: "cleanTokens", keys: []string{"o"}, help: "Delete tokens of removed environments", short: "clean up tokens"},
+		{name: "health", keys: []string{"h"}, help: "Check the health of every environment", short: "check health"},
+		{name: "refreshExpired", keys: []string{"R"}, help: "Sign in again to every expired environment", short: "refresh expired"},
+		{name: "nextLogin", keys: []string{"n"}, help: "Sign in to the next environment in the queue", short: "next login"},
+		{name: "tokenRoot", keys: []string{"t"}, help: "Set the folder tokens are exported to", short: "set token root"},
+		{name: "clearTokenRoot", keys: []string{"x"}, help: "Clear the token export folder", short: "clear token root"},
+		{name: "exportConfig", keys: []string{"X"}, help: "Export environments and bindings to a file", short: "export config"},
+		{name: "importConfig", keys: []string{"I"}, help: "Import environments and bindings from a file", short: "import config"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit", short: "quit"},
+	}},
+	StateAuth: {title: "Sign In", actions: []keyAction{
+		{name: "retry", keys: []string{"enter", "r"}, help: "Retry the sign-in"},
+		{name: "deviceCode", keys: []string{"d"}, help: "Sign in with a device code instead"},
+		{name: "browser", keys: []string{"b"}, help: "Sign in in the browser instead"},
+		{name: "errorDetails", keys: []string{"E"}, help: "Show the details of the last error"},
+		{name: "back", keys: []string{"esc"}, help: "Back to the environments"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateList: {title: "Web Resources", actions: []keyAction{
+		{name: "switchTab", keys: []string{"tab"}, help: "Switch between Bind Files and File List", short: "switch"},
+		{name: "up", keys: []string{"up", "k"}, help: "Move up", short: "navigate"},
+		{name: "down", keys: []string{"down", "j"}, help: "Move down"},
+		{name: "expand", keys: []string{"enter"}, help: "Expand or collapse a folder", short: "expand/collapse", tab: bindTabOnly},
+		{name: "mark", keys: []string{" "}, help: "Mark a file, or every file in a folder, so unbind, toggle auto, publish and check sync act on all marked files", short: "mark"},
+		{name: "bind", keys: []string{"b"}, help: "Bind a local file to the web resource", short: "bind", tab: bindTabOnly},
+		{name: "bindPath", keys: []string{"F"}, help: "Bind a local file by typing its path", short: "bind by path", tab: bindTabOnly},
+		{name: "unbind", keys: []string{"u"}, help: "Remove the binding", short: "unbind"},
+		{name: "toggleAuto", keys: []string{"a"}, help: "Toggle auto-publish on save", short: "toggle auto"},
+		{name: "snooze", keys: []string{"z"}, help: "Snooze auto-publish for a while", short: "snooze auto"},
+		{name: "publish", keys: []string{"p"}, help: "Publish the bound file", short: "publish"},
+		{name: "clone", keys: []string{"C"}, help: "Bind another resource from this file's folder, with its settings", short: "clone", tab: fileListTabOnly},
+		{name: "label", keys: []string{"n"}, help: "Label the binding", short: "label", tab: fileListTabOnly},
+		{name: "search", keys: []string{"/"}, help: "Search (Bind Files) or filter (File List)", short: "search"},
+		{name: "refreshToken", keys: []string{"t"}, help: "Refresh the token and export it", short: "refresh token", tab: fileListTabOnly},
+		{name: "addToSolution", keys: []string{"s"}, help: "Add the web resource to a solution", short: "add to solution"},
+		{name: "create", keys: []string{"N"}, help: "Create web resources from local files", short: "new"},
+		{name: "errorDetails", keys: []string{"E"}, help: "Show the details of the last error", short: "error details"},
+		{name: "checkSync", keys: []string{"c"}, help: "Compare bound files, or only the marked ones, with the server", short: "check sync"},
+		{name: "details", keys: []string{"i"}, help: "Show the web resource details", short: "details"},
+		{name: "delete", keys: []string{"x"}, help: "Delete the web resource", short: "delete"},
+		{name: "treeView", keys: []string{"v"}, help: "Switch between tree and flat view", short: "tree/flat", tab: bindTabOnly},
+		{name: "sort", keys: []string{"o"}, help: "Change the sort order of the flat view", short: "sort", tab: bindTabOnly},
+		{name: "diffPublish", keys: []string{"D"}, help: "Show the diff against the server, then publish", short: "diff & publish"},
+		{name: "publishBound", keys: []string{"A"}, help: "Publish every bound file", short: "publish bound"},
+		{name: "publishAll", keys: []string{"P"}, help: "Publish all customizations", short: "publish all"},
+		{name: "republishLast", keys: []string{"."}, help: "Publish the last published file again"},
+		{name: "undo", keys: []string{"U"}, help: "Undo the last publish in this environment", short: "undo publish"},
+		{name: "arm", keys: []string{"W"}, help: "Arm or disarm auto-publish in an environment that requires confirmation"},
+		{name: "promote", keys: []string{"M"}, help: "Promote changed files along the promotion chain", short: "promote"},
+		{name: "restore", keys: []string{"B"}, help: "Restore a snapshot of the bound file", short: "restore snapshot"},
+		{name: "repoint", keys: []string{"R"}, help: "Re-point the binding to another local file", short: "re-point binding"},
+		{name: "history", keys: []string{"H"}, help: "Show the publish history", short: "history"},
+		{name: "managed", keys: []string{"m"}, help: "Show managed resources or all of them", short: "managed/all"},
+		{name: "solutionFilter", keys: []string{"S"}, help: "Show only the resources of a solution, or all again", short: "solution filter"},
+		{name: "refresh", keys: []string{"r"}, help: "Reload the web resources", short: "refresh"},
+		{name: "login", keys: []string{"l"}, help: "Sign in again", short: "login"},
+		{name: "back", keys: []string{"esc"}, help: "Back to the environments", short: "back"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit", short: "quit"},
+	}},
+	StateSolutionPicker: {title: "Solutions", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Move up", short: "navigate"},
+		{name: "down", keys: []string{"down", "j"}, help: "Move down"},
+		{name: "select", keys: []string{"enter"}, help: "Add to the solution", short: "select"},
+		{name: "back", keys: []string{"esc"}, help: "Cancel", short: "cancel"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateDiff: {title: "Diff", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Scroll up", short: "scroll"},
+		{name: "down", keys: []string{"down", "j"}, help: "Scroll down"},
+		{name: "pageUp", keys: []string{"pgup"}, help: "Scroll a page up"},
+		{name: "pageDown", keys: []string{"pgdown", " "}, help: "Scroll a page down"},
+		{name: "publish", keys: []string{"y", "enter"}, help: "Publish the local file", short: "publish"},
+		{name: "back", keys: []string{"esc", "n"}, help: "Cancel the publish", short: "cancel"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateSnapshotPicker: {title: "Snapshots", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Move up", short: "navigate"},
+		{name: "down", keys: []string{"down", "j"}, help: "Move down"},
+		{name: "select", keys: []string{"enter"}, help: "Restore the snapshot", short: "restore"},
+		{name: "back", keys: []string{"esc"}, help: "Cancel", short: "cancel"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateHistory: {title: "Publish History", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Move up", short: "navigate"},
+		{name: "down", keys: []string{"down", "j"}, help: "Move down"},
+		{name: "pageUp", keys: []string{"pgup"}, help: "Move a page up"},
+		{name: "pageDown", keys: []string{"pgdown", " "}, help: "Move a page down"},
+		{name: "back", keys: []string{"esc"}, help: "Back to the list", short: "back"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateSyncScan: {title: "Sync Check", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Move up"},
+		{name: "down", keys: []string{"down", "j"}, help: "Move down"},
+		{name: "back", keys: []string{"esc"}, help: "Stop the scan, or go back when it is done"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateErrorDetails: {title: "Error Details", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Scroll up", short: "scroll"},
+		{name: "down", keys: []string{"down", "j"}, help: "Scroll down"},
+		{name: "copy", keys: []string{"y"}, help: "Copy the details to the clipboard", short: "copy to clipboard"},
+		{name: "back", keys: []string{"esc", "E"}, help: "Back", short: "back"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateResourceDetails: {title: "Web Resource Details", actions: []keyAction{
+		{name: "displayName", keys: []string{"n"}, help: "Edit the display name", short: "edit display name"},
+		{name: "rename", keys: []string{"N"}, help: "Rename the web resource", short: "rename"},
+		{name: "back", keys: []string{"esc", "i"}, help: "Back to the list", short: "back"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit", short: "quit"},
+	}},
+	StateCreateModeSelect: {title: "Create Web Resources", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Move up", short: "navigate"},
+		{name: "down", keys: []string{"down", "j"}, help: "Move down"},
+		{name: "select", keys: []string{"enter"}, help: "Pick files or a folder", short: "select"},
+		{name: "back", keys: []string{"esc"}, help: "Cancel", short: "cancel"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+	StateCreateConfirm: {title: "Create Web Resources", actions: []keyAction{
+		{name: "up", keys: []string{"up", "k"}, help: "Scroll up"},
+		{name: "down", keys: []string{"down", "j"}, help: "Scroll down"},
+		{name: "displayName", keys: []string{"n"}, help: "Edit the display name"},
+		{name: "remove", keys: []string{"d", "delete", "backspace"}, help: "Remove the file from the list"},
+		{name: "reset", keys: []string{"r"}, help: "Put removed files back"},
+		{name: "create", keys: []string{"enter", "y"}, help: "Create the web resources"},
+		{name: "back", keys: []string{"esc"}, help: "Back"},
+		{name: "quit", keys: []string{"q", "ctrl+c"}, help: "Quit"},
+	}},
+}
+
+// keymapScreens names the screens whose keys can be remapped in config.json
+var keymapScreens = map[string]State{
+	"environments": StateEnvironmentSelect,
+	"list":         StateList,
+}
+
+// resolveKeymap applies the keys remapped in config.json to the defaults. A
+// remapped action gets the new key in place of its single-character keys;
+// named keys such as the arrows, enter and esc keep working. Unknown screens
+// and actions, and keys bound to two actions of a screen, are errors.
+func resolveKeymap(overrides map[string]map[string]string) (map[State]keyScreen, error) {
+	resolved := make(map[State]keyScreen, len(keymap))
+	for state, screen := range keymap {
+		resolved[state] = keyScreen{title: screen.title, actions: slices.Clone(screen.actions)}
+	}
+
+	for _, screenName := range slices.Sorted(maps.Keys(overrides)) {
+		state, ok := keymapScreens[screenName]
+		if !ok {
+			return nil, fmt.Errorf("keymap: unknown screen %q, expected environments or list", screenName)
+		}
+		screen := resolved[state]
+		for _, name := range slices.Sorted(maps.Keys(overrides[screenName])) {
+			key := overrides[screenName][name]
+			i := slices.IndexFunc(screen.actions, func(a keyAction) bool { return a.name == name })
+			if i < 0 {
+				return nil, fmt.Errorf("keymap.%s: unknown action %q", screenName, name)
+			}
+			if key == "" || key == "?" {
+				return nil, fmt.Errorf("keymap.%s.%s: %q can't be used as a key", screenName, name, key)
+			}
+			keys := []string{key}
+			for _, k := range screen.actions[i].keys {
+				if len(k) > 1 && k != key {
+					keys = append(keys, k)
+				}
+			}
+			screen.actions[i].keys = keys
+		}
+		if err := screen.checkConflicts(); err != nil {
+			return nil, fmt.Errorf("keymap.%s: %w", screenName, err)
+		}
+	}
+	return resolved, nil
+}
+
+// checkConflicts reports a key bound to two actions that can be used at the
+// same time
+func (s keyScreen) checkConflicts() error {
+	for i, a := range s.actions {
+		for _, b := range s.actions[i+1:] {
+			if a.tab != bothTabs && b.tab != bothTabs && a.tab != b.tab {
+				continue
+			}
+			for _, k := range a.keys {
+				if slices.Contains(b.keys, k) {
+					return fmt.Errorf("%q is bound to both %s and %s", k, a.name, b.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// action returns the name of the action a key triggers in a state, or "" for
+// none. On the list an action of the current tab wins over one of the other
+// tab, whose handler explains where it works.
+func (m Model) action(state State, key string) string {
+	actions := m.keys[state].actions
+	for _, a := range actions {
+		if slices.Contains(a.keys, key) && (state != StateList || a.appliesTo(m.bindingTab)) {
+			return a.name
+		}
+	}
+	for _, a := range actions {
+		if slices.Contains(a.keys, key) {
+			return a.name
+		}
+	}
+	return ""
+}
+
+// keyFor returns the label of the keys an action is bound to in a state, so
+// hints name the key even after it was remapped
+func (m Model) keyFor(state State, action string) string {
+	for _, a := range m.keys[state].actions {
+		if a.name == action {
+			return keyLabel(a.keys)
+		}
+	}
+	return action
+}
+
+// markedActionKeys lists the keys of the list actions that act on every
+// marked resource, e.g. "u, a, p and c"
+func (m Model) markedActionKeys() string {
+	return fmt.Sprintf("%s, %s, %s and %s", m.keyFor(StateList, "unbind"), m.keyFor(StateList, "toggleAuto"),
+		m.keyFor(StateList, "publish"), m.keyFor(StateList, "checkSync"))
+}
+
+// keyLabel renders the keys of an action the way the help lines show them
+func keyLabel(keys []string) string {
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		switch k {
+		case "up":
+			labels[i] = "↑"
+		case "down":
+			labels[i] = "↓"
+		case " ":
+			labels[i] = "space"
+		default:
+			labels[i] = k
+		}
+	}
+	return strings.Join(labels, "/")
+}
+
+// appliesTo reports whether a list action works on a tab
+func (a keyAction) appliesTo(tab BindingTab) bool {
+	switch a.tab {
+	case bindTabOnly:
+		return tab == BindingTabBind
+	case fileListTabOnly:
+		return tab == BindingTabList
+	}
+	return true
+}
+
+// helpLine lists the actions of a state that have a short label. Moving up
+// and down share one "↑/↓" entry.
+func (m Model) helpLine(state State) string {
+	var parts []string
+	for _, a := range m.keys[state].actions {
+		if a.short == "" || (state == StateList && !a.appliesTo(m.bindingTab)) {
+			continue
+		}
+		label := keyLabel(a.keys)
+		if a.name == "up" {
+			label = "↑/↓"
+		}
+		parts = append(parts, label+": "+a.short)
+	}
+	return strings.Join(append(parts, "?: help"), " • ")
+}
+
+// hasHelp reports whether a state has a help overlay
+func hasHelp(state State) bool {
+	_, ok := keymap[state]
+	return ok
+}
+
+// viewHelp renders the help overlay for the current state
+func (m Model) viewHelp() string {
+	availableWidth := m.width - 12
+	screen := m.keys[m.state]
+
+	title := titleStyle.Render("Keys • " + screen.title)
+
+	var lines []string
+	for _, a := range screen.actions {
+		help := a.help
+		switch {
+		case m.state != StateList:
+		case a.tab == bindTabOnly:
+			help += dimStyle.Render(" (Bind Files)")
+		case a.tab == fileListTabOnly:
+			help += dimStyle.Render(" (File List)")
+		}
+		lines = append(lines, fmt.Sprintf("%-16s %s", keyLabel(a.keys), help))
+	}
+	lines = append(lines, fmt.Sprintf("%-16s %s", "?", "Show or hide this help"))
+
+	visibleLines := max(m.height-14, 5)
+	start := min(m.helpScroll, max(len(lines)-visibleLines, 0))
+	end := min(start+visibleLines, len(lines))
+	content := strings.Join(lines[start:end], "\n")
+	if len(lines) > visibleLines {
+		content += dimStyle.Render(fmt.Sprintf("\n\n[%d-%d of %d]", start+1, end, len(lines)))
+	}
+
+	box := contentBoxStyle.Width(availableWidth).Render(content)
+	helpRendered := helpStyle.Width(availableWidth).Render("↑/↓: scroll • esc/?: close")
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, box, helpRendered)
+}
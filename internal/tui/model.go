@@ -1,17 +1,27 @@
 package tui
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/auth"
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/diff"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/httpclient"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/publisher"
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/watcher"
 
 	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
 )
 
 // State represents the application state
@@ -31,6 +41,12 @@ const (
 	StateCreateNameInput
 	StateCreatePrefixInput
 	StateCreateConfirm
+	StateSyncScan
+	StateResourceDetails
+	StateErrorDetails
+	StateSnapshotPicker
+	StateDiff
+	StateHistory
 )
 
 // InputMode represents the current input mode
@@ -42,8 +58,44 @@ const (
 	InputEnvironmentURL
 	InputBindingPath
 	InputDeleteConfirm
+	InputSearch
+	InputEnvironmentPrefix
+	InputPublishAllConfirm
+	InputSnoozeMinutes
+	InputEnvironmentConfirm
+	InputDisplayName
+	InputOrphanTokensConfirm
+	InputPromoteConfirm
+	InputBindingFilter
+	InputBindingLabel
+	InputRestoreConfirm
+	InputOverwriteConfirm
+	InputSignOutConfirm
+	InputExportPath
+	InputImportPath
+	InputImportMode
+	InputImportCommandsConfirm
+	InputResourceName
+	InputDeleteResourceConfirm
+	InputProductionConfirm
 )
 
+// envEdit tracks an environment add or edit across the name, URL and
+// confirmation steps
+type envEdit struct {
+	originalName string // empty when adding a new environment
+	name         string
+	url          string
+}
+
+// promotion tracks a set of files moving along the promotion chain
+type promotion struct {
+	files   []publisher.PromotedFile // content captured when the promotion started
+	target  string                   // environment awaiting confirmation or in flight
+	report  []string                 // per-environment results so far
+	running bool
+}
+
 // BindingTab represents the active tab in the binding view
 type BindingTab int
 
@@ -60,11 +112,79 @@ const (
 	CreateModeFolder
 )
 
+// FlatSort represents the sort column of the flat resource list
+type FlatSort int
+
+const (
+	FlatSortName FlatSort = iota
+	FlatSortType
+	FlatSortVersion
+)
+
+// String returns the display label for the sort column
+func (f FlatSort) String() string {
+	switch f {
+	case FlatSortType:
+		return "type"
+	case FlatSortVersion:
+		return "version"
+	}
+	return "name"
+}
+
 // CreateFileInfo holds info about a file to be created as a web resource
 type CreateFileInfo struct {
 	LocalPath    string
 	WebResName   string
+	DisplayName  string // empty uses the file name
 	ResourceType d365.WebResourceType
+	Warning      string // soft content warning, e.g. likely double encoding
+}
+
+// displayName returns the display name to create the resource with
+func (f CreateFileInfo) displayName() string {
+	if f.DisplayName != "" {
+		return f.DisplayName
+	}
+	return filepath.Base(f.WebResName)
+}
+
+// AuthFlow is how a user signs in on the authentication screen
+type AuthFlow int
+
+const (
+	AuthFlowAuto       AuthFlow = iota // device code when auth.UseDeviceCode says so, else the browser
+	AuthFlowBrowser                    // interactive sign-in in the browser
+	AuthFlowDeviceCode                 // a code entered on another device
+)
+
+// EnvHealth describes whether an environment can be used without signing in
+type EnvHealth int
+
+const (
+	HealthUnknown EnvHealth = iota
+	HealthChecking
+	HealthReady
+	HealthNoToken
+	HealthExpired
+	HealthUnreachable
+)
+
+// String returns the display label for the environment health
+func (h EnvHealth) String() string {
+	switch h {
+	case HealthChecking:
+		return "checking..."
+	case HealthReady:
+		return "ready"
+	case HealthNoToken:
+		return "not signed in"
+	case HealthExpired:
+		return "token expired"
+	case HealthUnreachable:
+		return "unreachable"
+	}
+	return ""
 }
 
 // TreeNode represents a folder or file in the tree
@@ -90,9 +210,14 @@ type Model struct {
 	config           *config.Config
 	token            *auth.Token
 	client           *d365.Client
+	session          context.Context    // open environment; cancelled when leaving the resource list
+	endSession       context.CancelFunc // stops list requests still in flight
 	watcher          *watcher.Watcher
 	fileChangeChan   chan string
+	watchErrChan     chan error // errors of the file watcher, shown in the status bar
 	resources        []d365.WebResource
+	allResources     []d365.WebResource // full list, kept while a search is active
+	searchQuery      string
 	treeRoot         *TreeNode
 	displayItems     []DisplayItem
 	expandedFolders  map[string]bool
@@ -107,19 +232,80 @@ type Model struct {
 	spinner          spinner.Model
 	filepicker       filepicker.Model
 	bindingResource  *d365.WebResource
+	pickerShowAll    bool // show all files in the bind picker instead of matching types
+	repoint          bool // the bind picker moves the binding of bindingResource to a new file
 	tokenExportEnv   string
 	tokenExportState State
 	tokenExportWrite bool
-	editingEnvName   string
-	publishing       map[string]bool // tracks which resource IDs are currently publishing
+	envEdit          *envEdit // environment being added or edited, nil when not editing
+	authErr          error    // last interactive authentication failure
+	authCancelled    bool
+	authFlow         AuthFlow                      // sign-in flow picked on the authentication screen
+	authAttempt      int                           // increases with every sign-in so stale results are ignored
+	authCtx          context.Context               // cancelled when the current sign-in is abandoned
+	authCancel       context.CancelFunc            // cancels authCtx
+	deviceCode       *auth.DeviceCodeResponse      // code to show while a device code sign-in waits, nil otherwise
+	deviceCodeFlow   bool                          // the current sign-in uses a device code
+	publishing       map[string]bool               // tracks which resource IDs are currently publishing
+	dirPublishing    map[string]bool               // paths being published through a directory binding
+	retrying         map[string]int                // resource ID -> retries of a failed auto-publish made so far
+	failed           map[string]bool               // resource ID -> auto-publish failed after every retry
+	marked           map[string]bool               // resource IDs marked with space; unbind, toggle auto, publish and check sync act on all of them
+	publishingAll    bool                          // PublishAllXml is in flight
+	cloneFrom        *config.Binding               // binding whose directory and settings seed the next bind
+	offline          bool                          // last connectivity check or request failed to reach the org
+	lastActivity     time.Time                     // last key press, used to pause connectivity checks when idle
+	watchersArming   bool                          // setupWatchers is running; saves are not yet caught
+	refreshing       bool                          // the cached resource list is shown while the fresh one is fetched
+	listening        bool                          // a waitForFileChange command is pending on fileChangeChan
+	snoozed          map[string]time.Time          // resource ID -> end of auto-publish snooze; zero until unsnoozed
+	snoozeTarget     *config.Binding               // binding the snooze duration prompt applies to
+	envHealth        map[string]EnvHealth          // environment name -> last health check result
+	tokenRefreshing  bool                          // a background silent refresh is in flight
+	tokenRefreshFail bool                          // the silent refresh for the current token failed
+	reauthRunning    bool                          // the reauthenticate-all pass is in flight
+	reauthQueue      []string                      // environments still needing an interactive login
+	readOnly         bool                          // started with --read-only; nothing is written or published
+	bindPathErr      error                         // result of validating the binding path as it is typed
+	orphanTokens     []string                      // token files without an environment, awaiting delete confirmation
+	orphanEntries    []string                      // keychain entries without an environment, likewise
+	importPath       string                        // config export being imported, awaiting merge or replace
+	configImport     *config.ConfigExport          // read from importPath, awaiting confirmation of its commands
+	changed          map[string]bool               // resource IDs published in this session, the default promotion set
+	missingFiles     map[string]bool               // resource IDs whose bound local file no longer exists
+	promotion        *promotion                    // promotion in progress, nil when idle
+	overwrite        *publisher.ServerChangedError // publish held back because the resource changed on the server
+	overwriteID      string                        // web resource ID of the held back publish
+	deleteTarget     *d365.WebResource             // web resource awaiting a typed delete confirmation
+	undoRunning      bool                          // an undo of the last publish is in flight
+	confirmKey       *tea.KeyMsg                   // publish held back until it is confirmed for a production environment
+	armedEnv         string                        // production environment whose auto-publish was armed this session
+	solutionsOf      map[string][]string           // solutions of the bound resources by ID; a failed lookup is missing
+	solutionsOfErr   error                         // why looking up solutionsOf failed, if it did
+	connectedAs      string                        // name of the user signed in to the current environment
+	bindingFilter    string                        // File List filter on name, path and label
 	width            int
 	height           int
-	err              error
+	err              error // last failure, shown in the error details view
+	errorScroll      int
+	errorReturnState State
+	keys             map[State]keyScreen // keymap with the keys remapped in config.json
+	showHelp         bool                // the help overlay covers the current screen
+	helpScroll       int
 	// Solution picker
 	solutions        []d365.Solution
 	solutionSelected int
 	solutionResource *d365.WebResource // the resource to add to a solution
 	loadingSolutions bool
+	pickingFilter    bool           // the picker chooses solutionFilter instead of adding to a solution
+	solutionFilter   *d365.Solution // the resource list only shows this solution's resources; nil shows all
+	// Snapshot restore
+	snapshots        []publisher.Snapshot
+	snapshotSelected int
+	snapshotBinding  *config.Binding // binding whose snapshots are listed
+	// Publish history
+	history         []publisher.HistoryEntry
+	historySelected int
 	// Create web resource
 	createMode          CreateMode
 	createModeSelected  int
@@ -131,6 +317,32 @@ type Model struct {
 	createName          string
 	creatingResources   bool
 	includeManaged      bool
+	flatSort            FlatSort
+	// Batch publish progress
+	batchID         int // incremented for every batch publish
+	batchProgressID int // batch whose progress the status bar shows, 0 when none
+	// Sync status scan
+	syncScanID    int
+	syncScanning  bool
+	syncBindings  []config.Binding
+	syncResults   []publisher.SyncResult
+	syncSelected  int
+	syncCancelled bool
+	// Resource details
+	detailsResource *d365.WebResource
+	detailsAudit    *d365.WebResourceAudit
+	detailsLoading  bool
+	detailsErr      error
+	// Diff preview
+	diffResource *d365.WebResource
+	diffLines    []diff.Line // unified diff of server content against the local file
+	diffBinary   bool        // content is binary; only whether it changed is known
+	diffChanged  bool
+	diffReplaced int    // environment tokens substituted into the local side
+	diffWarning  string // shown above the diff, e.g. content that is already base64
+	diffLoading  bool
+	diffErr      error
+	diffScroll   int
 }
 
 // NewModel creates a new application model
@@ -141,15 +353,37 @@ func NewModel() Model {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 
+	var status string
 	cfg, err := config.Load()
-	if err != nil {
+	if err != nil && cfg == nil {
 		cfg = &config.Config{
-			Environments: []config.Environment{},
-			Bindings:     []config.Binding{},
+			Environments:    []config.Environment{},
+			PublisherPrefix: "new",
+			Bindings:        []config.Binding{},
 		}
 	}
+	if err == nil {
+		err = httpclient.Configure(cfg)
+	}
+	// A broken keymap falls back to the default keys instead of leaving some
+	// actions unreachable
+	keys, keymapErr := resolveKeymap(cfg.Keymap)
+	if keymapErr != nil {
+		keys, _ = resolveKeymap(nil)
+		if err == nil {
+			err = keymapErr
+		}
+	}
+	if err != nil {
+		status = err.Error()
+	} else if warnings := cfg.Warnings(); len(warnings) > 0 {
+		status = strings.Join(warnings, "; ")
+	}
 
 	return Model{
+		status:          status,
+		statusIsError:   status != "",
+		err:             err,
 		state:           StateEnvironmentSelect,
 		config:          cfg,
 		textInput:       ti,
@@ -158,12 +392,61 @@ func NewModel() Model {
 		height:          24,
 		expandedFolders: make(map[string]bool),
 		publishing:      make(map[string]bool),
+		dirPublishing:   make(map[string]bool),
+		retrying:        make(map[string]int),
+		failed:          make(map[string]bool),
+		marked:          make(map[string]bool),
+		snoozed:         make(map[string]time.Time),
+		envHealth:       make(map[string]EnvHealth),
+		changed:         make(map[string]bool),
 		fileChangeChan:  make(chan string, 10), // Buffered channel for file changes
+		watchErrChan:    make(chan error, 1),
+		lastActivity:    time.Now(),
+		readOnly:        config.ReadOnly(),
+		keys:            keys,
+	}
+}
+
+// checkBindingPaths flags the bindings of the current environment whose
+// local file was moved or deleted
+func (m *Model) checkBindingPaths() {
+	m.missingFiles = make(map[string]bool)
+	for _, b := range m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment) {
+		for _, source := range b.Sources() {
+			if _, err := os.Stat(source); os.IsNotExist(err) {
+				m.missingFiles[b.WebResourceID] = true
+			}
+		}
+	}
+}
+
+// fileListBindings returns the bindings shown in the File List: those of the
+// current environment whose name, path or label matches the filter
+func (m *Model) fileListBindings() []config.Binding {
+	bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+	filter := strings.ToLower(m.bindingFilter)
+	if filter == "" {
+		return bindings
+	}
+
+	var matched []config.Binding
+	for _, b := range bindings {
+		if strings.Contains(strings.ToLower(b.WebResourceName), filter) ||
+			strings.Contains(strings.ToLower(b.LocalPath), filter) ||
+			strings.Contains(strings.ToLower(b.Label), filter) {
+			matched = append(matched, b)
+		}
 	}
+	return matched
 }
 
 // buildTree creates a tree structure from flat web resources
 func (m *Model) buildTree() {
+	if m.config.ViewMode == config.ViewModeFlat {
+		m.buildFlatList()
+		return
+	}
+
 	root := &TreeNode{
 		Name:     "root",
 		IsFolder: true,
@@ -171,9 +454,10 @@ func (m *Model) buildTree() {
 		Expanded: true,
 	}
 
+	separators := m.config.NameSeparators()
 	for i := range m.resources {
 		res := &m.resources[i]
-		parts := strings.Split(res.Name, "/")
+		parts := nameParts(res.Name, separators)
 		current := root
 
 		for j, part := range parts {
@@ -220,6 +504,58 @@ func (m *Model) buildTree() {
 	m.flattenTree()
 }
 
+// nameParts splits a resource name into its folders and file name at any of
+// the separators. Leading, trailing and repeated separators don't make empty
+// folders, and the file extension stays with the file name even when "." is
+// a separator. Folders are identified by their parts joined with "/", so
+// names using different separators share folders.
+func nameParts(name, separators string) []string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if ext == "" || strings.ContainsAny(ext[1:], separators) || base == "" || strings.ContainsRune(separators, rune(base[len(base)-1])) {
+		// No extension, or one that is part of the folders or the whole file name
+		base, ext = name, ""
+	}
+	parts := strings.FieldsFunc(base, func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	})
+	if len(parts) == 0 {
+		return []string{name}
+	}
+	parts[len(parts)-1] += ext
+	return parts
+}
+
+// buildFlatList lists every resource by its full name without folder nodes
+func (m *Model) buildFlatList() {
+	m.treeRoot = nil
+	m.displayItems = make([]DisplayItem, 0, len(m.resources))
+	for i := range m.resources {
+		res := &m.resources[i]
+		node := &TreeNode{
+			Name:     res.Name,
+			FullPath: res.Name,
+			Resource: res,
+		}
+		m.displayItems = append(m.displayItems, DisplayItem{Node: node, Resource: res})
+	}
+
+	sort.SliceStable(m.displayItems, func(i, j int) bool {
+		a, b := m.displayItems[i].Resource, m.displayItems[j].Resource
+		switch m.flatSort {
+		case FlatSortType:
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+		case FlatSortVersion:
+			if a.Version != b.Version {
+				return a.Version > b.Version
+			}
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+}
+
 func sortChildren(node *TreeNode) {
 	sort.Slice(node.Children, func(i, j int) bool {
 		// Folders come before files
@@ -238,6 +574,10 @@ func sortChildren(node *TreeNode) {
 
 // flattenTree creates a flat list of visible items for display
 func (m *Model) flattenTree() {
+	if m.config.ViewMode == config.ViewModeFlat {
+		m.buildFlatList()
+		return
+	}
 	m.displayItems = []DisplayItem{}
 	if m.treeRoot == nil {
 		return
@@ -261,4 +601,131 @@ func (m *Model) flattenNode(node *TreeNode) {
 func (m *Model) toggleFolder(path string) {
 	m.expandedFolders[path] = !m.expandedFolders[path]
 	m.buildTree()
+	m.saveExpandedFolders()
+}
+
+// restoreExpandedFolders opens the folders that were open when the
+// environment was last used
+func (m *Model) restoreExpandedFolders(env config.Environment) {
+	m.expandedFolders = make(map[string]bool, len(env.ExpandedFolders))
+	for _, path := range env.ExpandedFolders {
+		m.expandedFolders[path] = true
+	}
+}
+
+// pruneExpandedFolders forgets open folders that no longer exist in the
+// full resource list
+func (m *Model) pruneExpandedFolders() {
+	folders := make(map[string]bool)
+	separators := m.config.NameSeparators()
+	for _, res := range m.allResources {
+		parts := nameParts(res.Name, separators)
+		for j := 1; j < len(parts); j++ {
+			folders[strings.Join(parts[:j], "/")] = true
+		}
+	}
+	stale := false
+	for path, open := range m.expandedFolders {
+		if !folders[path] || !open {
+			delete(m.expandedFolders, path)
+			stale = stale || open
+		}
+	}
+	if stale {
+		m.saveExpandedFolders()
+	}
+}
+
+// saveExpandedFolders persists the open folders of the current environment
+func (m *Model) saveExpandedFolders() {
+	var paths []string
+	for path, open := range m.expandedFolders {
+		if open {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	m.config.SetExpandedFolders(m.config.CurrentEnvironment, paths)
+}
+
+// selectedResource returns the web resource under the cursor in the active tab
+func (m *Model) selectedResource() *d365.WebResource {
+	if m.bindingTab == BindingTabBind {
+		if m.resourceSelected < len(m.displayItems) {
+			item := m.displayItems[m.resourceSelected]
+			if !item.Node.IsFolder && item.Resource != nil {
+				return item.Resource
+			}
+		}
+		return nil
+	}
+
+	bindings := m.fileListBindings()
+	if m.bindingSelected < len(bindings) {
+		binding := bindings[m.bindingSelected]
+		for i := range m.resources {
+			if m.resources[i].ID == binding.WebResourceID {
+				return &m.resources[i]
+			}
+		}
+	}
+	return nil
+}
+
+// snoozeRemaining reports whether auto-publish is snoozed for a resource and
+// how long is left; the duration is zero for an open-ended snooze
+func (m *Model) snoozeRemaining(resourceID string) (time.Duration, bool) {
+	until, ok := m.snoozed[resourceID]
+	if !ok {
+		return 0, false
+	}
+	if until.IsZero() {
+		return 0, true
+	}
+	remaining := time.Until(until)
+	return remaining, remaining > 0
+}
+
+// publisherPrefix returns the customization prefix of the selected solution's
+// publisher, falling back to the environment's configured prefix
+func (m *Model) publisherPrefix() string {
+	if m.createSolution != nil && m.createSolution.Publisher.CustomizationPrefix != "" {
+		return m.createSolution.Publisher.CustomizationPrefix
+	}
+	return m.config.PublisherPrefixFor(m.config.CurrentEnvironment)
+}
+
+// validateResourceName checks that a new web resource name uses the publisher prefix
+func (m *Model) validateResourceName(name string) error {
+	prefix := m.publisherPrefix()
+	if prefix == "" {
+		return nil
+	}
+	if !strings.HasPrefix(name, prefix+"_") {
+		return fmt.Errorf("name must start with the publisher prefix %s_", prefix)
+	}
+	return nil
+}
+
+// collectResources returns all web resources beneath a tree node
+func collectResources(node *TreeNode) []*d365.WebResource {
+	var result []*d365.WebResource
+	for _, child := range node.Children {
+		if child.IsFolder {
+			result = append(result, collectResources(child)...)
+		} else if child.Resource != nil {
+			result = append(result, child.Resource)
+		}
+	}
+	return result
+}
+
+// sortedSyncResults returns the sync results grouped by status
+func (m Model) sortedSyncResults() []publisher.SyncResult {
+	results := make([]publisher.SyncResult, len(m.syncResults))
+	copy(results, m.syncResults)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Status < results[j].Status
+	})
+	return results
 }
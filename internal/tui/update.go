@@ -1,19 +1,30 @@
 package tui
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/auth"
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/diff"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/publisher"
 	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/watcher"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,32 +32,58 @@ import (
 
 // Messages
 type (
-	tokenMsg         *auth.Token
 	statusMsg        string
 	tokenExportedMsg struct {
 		token *auth.Token
 		dir   string
 	}
 	tokenExportAuthRequiredMsg struct{}
-	resourcesMsg               []d365.WebResource
-	publishResultMsg           struct {
+	resourcesMsg               struct {
+		resources []d365.WebResource
+		cacheErr  error // saving the list for the next reconnect failed
+	}
+	publishResultMsg struct {
 		success    bool
 		err        error
 		path       string
 		resourceID string
+		replaced   int
+		warning    string
+		unchanged  bool
+		auto       bool // published because the file was saved
+	}
+	directoryPublishMsg struct {
+		path   string
+		result *publisher.DirectoryResult
+		err    error
+		reAuth bool // the token refresh failed, so nothing was published
+	}
+	errMsg          error
+	statusClearMsg  struct{}
+	fileChangeMsg   string
+	retryPublishMsg struct {
+		path, resourceID string
+		attempt          int // the retry count when scheduled; older ticks are dropped
+	}
+	watchErrorMsg   error
+	watcherReadyMsg struct {
+		watcher *watcher.Watcher
+		missed  []string // auto-publish files modified while the watcher was being set up
+		err     error
+	}
+	tokenRefreshedMsg     *auth.Token
+	tokenRefreshFailedMsg struct {
+		err error
 	}
-	errMsg            error
-	statusClearMsg    struct{}
-	fileChangeMsg     string
-	watcherReadyMsg   *watcher.Watcher
-	tokenRefreshedMsg *auth.Token
 	reAuthRequiredMsg struct{}
 	solutionsMsg      []d365.Solution
 	addToSolutionMsg  struct {
 		success      bool
 		err          error
 		solutionName string
+		solutionID   string // unique name
 		resourceName string
+		resourceID   string
 	}
 	createResourcesMsg struct {
 		success bool
@@ -55,17 +92,149 @@ type (
 		failed  []string
 	}
 	folderFilesMsg []CreateFileInfo
+	syncCheckMsg   struct {
+		scanID int
+		result publisher.SyncResult
+	}
+	searchResultsMsg struct {
+		query     string
+		resources []d365.WebResource
+		local     bool
+		err       error
+	}
+	authFailedMsg struct {
+		attempt   int
+		err       error
+		cancelled bool
+	}
+	deviceCodeMsg struct {
+		attempt int
+		code    *auth.DeviceCodeResponse
+	}
+	bindVerifiedMsg struct {
+		resource d365.WebResource
+		path     string
+		err      error
+	}
+	envHealthMsg map[string]EnvHealth
+	reauthAllMsg struct {
+		refreshed []string
+		needLogin []string
+	}
+	reauthLoginMsg struct {
+		envName string
+		err     error
+	}
+	displayNameMsg struct {
+		resourceID  string
+		displayName string
+		err         error
+	}
+	tokenMsg struct {
+		token   *auth.Token
+		attempt int    // sign-in that acquired the token
+		envName string // environment signed in to
+	}
+	connectedMsg struct {
+		envName string
+		user    *d365.WhoAmIResult
+		err     error
+	}
+	cachedResourcesMsg struct {
+		envName string
+		cache   *d365.ResourceCache
+	}
+	deleteResourceMsg struct {
+		resourceID string
+		name       string
+		err        error
+	}
+	renameMsg struct {
+		resourceID string
+		oldName    string
+		name       string
+		err        error // the rename failed or wasn't confirmed; nothing changed locally
+		publishErr error // renamed, but publishing the new name failed
+	}
+	connectivityTickMsg struct{}
+	connectivityMsg     struct {
+		err error
+	}
+	publishAllMsg struct {
+		err error
+	}
+	diffMsg struct {
+		resourceID string
+		lines      []diff.Line
+		binary     bool
+		changed    bool
+		replaced   int    // environment tokens substituted into the local content
+		warning    string // a problem the publish would have, such as double encoding
+		err        error
+	}
+	batchPublishMsg struct {
+		batchID int
+		ids     []string
+		result  *publisher.BatchResult
+		err     error
+		reAuth  bool // the token refresh failed, so nothing was published
+	}
+	batchProgressMsg struct {
+		batchID  int
+		done     int
+		total    int
+		progress chan batchProgressMsg
+	}
+	restoreMsg struct {
+		resourceID string
+		name       string
+		err        error
+	}
+	undoMsg struct {
+		name string
+		err  error
+	}
+	resourceSolutionsMsg struct {
+		envName   string
+		solutions map[string][]string
+		err       error
+	}
+	promoteMsg struct {
+		envName   string
+		published int
+		errs      []error
+	}
+	resourceAuditMsg struct {
+		resourceID string
+		audit      *d365.WebResourceAudit
+		err        error
+	}
 )
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(m.spinner.Tick)
+	return tea.Batch(m.spinner.Tick, m.scheduleConnectivityCheck(), m.checkEnvironments(m.config.PingEnvironmentsOnStartup))
 }
 
 // Update handles messages
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	// Timers run in every state, so handle them before the file pickers
+	// swallow the messages
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		m.lastActivity = time.Now()
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, tea.Batch(cmd, m.refreshTokenBeforeExpiry())
+	case connectivityTickMsg:
+		return m, m.checkConnectivity()
+	case connectivityMsg:
+		return m.handleConnectivity(msg)
+	}
+
 	// Handle file picker states - they need to receive all messages
 	if m.state == StateFilePicker {
 		return m.handleFilePicker(msg)
@@ -86,29 +255,55 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		return m.handleKey(msg)
 
-	case spinner.TickMsg:
-		var cmd tea.Cmd
-		m.spinner, cmd = m.spinner.Update(msg)
-		cmds = append(cmds, cmd)
+	case authFailedMsg:
+		if msg.attempt != m.authAttempt {
+			return m, nil
+		}
+		m.deviceCode = nil
+		m.authErr = msg.err
+		m.err = msg.err
+		m.authCancelled = msg.cancelled
+		if msg.cancelled {
+			m.status = "Sign-in was cancelled"
+		} else {
+			m.status = fmt.Sprintf("Authentication failed: %v", msg.err)
+		}
+		m.statusIsError = true
 
 	case tokenMsg:
-		m.token = msg
-		if env := m.config.GetEnvironment(m.config.CurrentEnvironment); env != nil {
-			auth.SaveToken(env.Name, msg)
-			if err := m.exportTokenForEnvironment(env, msg); err != nil {
+		// A sign-in that was abandoned or replaced may still finish
+		if msg.attempt != m.authAttempt || msg.envName != m.config.CurrentEnvironment {
+			return m, nil
+		}
+		m.token = msg.token
+		m.authErr = nil
+		m.deviceCode = nil
+		m.tokenRefreshing = false
+		m.tokenRefreshFail = false
+		if env := m.config.GetEnvironment(msg.envName); env != nil {
+			auth.SaveToken(env.Name, msg.token)
+			m.envHealth[env.Name] = HealthReady
+			if err := m.exportTokenForEnvironment(env, msg.token); err != nil {
 				m.status = fmt.Sprintf("Token export failed: %v", err)
 				m.statusIsError = true
 			}
-			m.client = d365.NewClient(env.URL, msg.AccessToken)
+			m.client = d365.NewClient(env.URL, msg.token.AccessToken, clientOptions(m.config))
+			m.client.SetMaxRetries(m.config.RetryLimit())
+			m.startSession()
 			// Set up token refresh callback
 			m.setupTokenRefresh()
 			m.state = StateList
-			return m, m.fetchResources()
+			return m, tea.Batch(m.checkConnection(), m.loadCachedResources())
 		}
 
 	case tokenRefreshedMsg:
 		// Token was refreshed automatically
 		m.token = msg
+		m.tokenRefreshing = false
+		m.tokenRefreshFail = false
+		if m.client != nil {
+			m.client.UpdateToken(msg.AccessToken)
+		}
 		if env := m.config.GetEnvironment(m.config.CurrentEnvironment); env != nil {
 			auth.SaveToken(env.Name, msg)
 			if err := m.exportTokenForEnvironment(env, msg); err != nil {
@@ -120,52 +315,531 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case tokenRefreshFailedMsg:
+		m.tokenRefreshing = false
+		m.tokenRefreshFail = true
+		m.status = fmt.Sprintf("Token expires soon and could not be refreshed; press %s to sign in again", m.keyFor(StateList, "login"))
+		m.statusIsError = true
+		m.err = msg.err
+
+	case deviceCodeMsg:
+		if msg.attempt != m.authAttempt {
+			return m, nil
+		}
+		m.deviceCode = msg.code
+		m.status = fmt.Sprintf("Open %s and enter the code %s", msg.code.VerificationURI, msg.code.UserCode)
+		m.statusIsError = false
+		return m, m.pollDeviceCode(msg.attempt, msg.code)
+
 	case reAuthRequiredMsg:
 		// Token refresh failed, need to re-authenticate
-		m.status = "Session expired, re-authenticating..."
-		m.statusIsError = false
+		m.status = "Your session expired and could not be refreshed; sign in again to continue"
+		m.statusIsError = true
 		m.state = StateAuth
-		return m, m.authenticateInteractive()
+		m.authErr = nil
+		cmd := m.authenticate()
+		return m, cmd
+
+	case cachedResourcesMsg:
+		// Too late once the fresh list is in, or if it would show other resources
+		if msg.envName != m.config.CurrentEnvironment || m.allResources != nil || m.solutionFilter != nil || msg.cache.IncludeManaged != m.includeManaged {
+			return m, nil
+		}
+		m.resources = msg.cache.Resources
+		m.allResources = msg.cache.Resources
+		m.refreshing = true
+		m.buildTree()
+		m.status = fmt.Sprintf("Showing %d web resources cached %s; refreshing...", len(m.resources), formatAge(msg.cache.Fetched))
+		m.statusIsError = false
+		return m, nil
 
 	case resourcesMsg:
-		m.resources = msg
+		m.refreshing = false
+		m.resources = msg.resources
+		m.allResources = msg.resources
+		// Forget marks of resources that are no longer listed
+		maps.DeleteFunc(m.marked, func(id string, _ bool) bool {
+			return !slices.ContainsFunc(msg.resources, func(res d365.WebResource) bool { return res.ID == id })
+		})
+		m.searchQuery = ""
+		// A solution's resources are only part of the list, so the folders
+		// of the others would be pruned too
+		if m.solutionFilter == nil {
+			m.pruneExpandedFolders()
+		}
+		m.checkBindingPaths()
 		m.buildTree()
-		m.status = fmt.Sprintf("Loaded %d web resources", len(msg))
+		m.resourceSelected = min(m.resourceSelected, max(len(m.displayItems)-1, 0))
+		m.status = fmt.Sprintf("Loaded %d web resources, arming watchers...", len(msg.resources))
 		m.statusIsError = false
-		return m, m.setupWatchers()
+		if msg.cacheErr != nil {
+			// Only costs the next reconnect its head start, so not an error
+			m.status = fmt.Sprintf("Loaded %d web resources but could not cache them: %v", len(msg.resources), msg.cacheErr)
+			m.err = msg.cacheErr
+		}
+		solutions := m.fetchResourceSolutions()
+		if m.readOnly {
+			m.status = fmt.Sprintf("Loaded %d web resources (read-only)", len(msg.resources))
+			return m, solutions
+		}
+		m.watchersArming = true
+		return m, tea.Batch(m.setupWatchers(), solutions)
 
-	case watcherReadyMsg:
-		m.watcher = msg
-		// Start listening for file changes
-		if m.fileChangeChan != nil {
-			return m, waitForFileChange(m.fileChangeChan)
+	case resourceSolutionsMsg:
+		if msg.envName != m.config.CurrentEnvironment {
+			return m, nil
 		}
+		m.solutionsOf = msg.solutions
+		m.solutionsOfErr = msg.err
 		return m, nil
 
+	case watcherReadyMsg:
+		m.watchersArming = false
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Auto-publish is not active: %v", msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		if m.watcher != nil {
+			m.watcher.Close()
+		}
+		m.watcher = msg.watcher
+		m.status = fmt.Sprintf("Loaded %d web resources, auto-publish is live", len(m.allResources))
+		if m.autoPublishHeld() {
+			m.status = fmt.Sprintf("Loaded %d web resources; %s requires confirmation, press %s to arm auto-publish", len(m.allResources), m.config.CurrentEnvironment, m.keyFor(StateList, "arm"))
+			msg.missed = nil
+		}
+		m.statusIsError = false
+		if len(m.missingFiles) > 0 {
+			m.status += fmt.Sprintf("; %d bound files are missing (%s re-points a binding)", len(m.missingFiles), m.keyFor(StateList, "repoint"))
+			m.statusIsError = true
+		}
+
+		// Start listening for file changes and watch errors once; the
+		// channels outlive each watcher
+		if m.fileChangeChan != nil && !m.listening {
+			m.listening = true
+			cmds = append(cmds, waitForFileChange(m.fileChangeChan), waitForWatchError(m.watchErrChan))
+		}
+		// Publish saves that happened before the watcher was listening. Like
+		// a save, each file publishes its first auto-publish binding only.
+		for _, path := range msg.missed {
+			for _, b := range m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment) {
+				if !b.Watches(path) || !b.AutoPublish {
+					continue
+				}
+				if _, ok := m.snoozeRemaining(b.WebResourceID); !ok {
+					m.publishing[b.WebResourceID] = true
+					cmds = append(cmds, m.handleFileChange(path))
+				}
+				break
+			}
+		}
+		return m, tea.Batch(cmds...)
+
 	case publishResultMsg:
 		// Remove from publishing map
 		if msg.resourceID != "" {
 			delete(m.publishing, msg.resourceID)
 		}
 		if msg.success {
+			delete(m.retrying, msg.resourceID)
+			delete(m.failed, msg.resourceID)
+		} else if msg.auto {
+			if cmd := m.retryAutoPublish(msg); cmd != nil {
+				return m, cmd
+			}
+		}
+		if !msg.success && m.reportOffline(msg.err) {
+			return m, nil
+		}
+		var changed *publisher.ServerChangedError
+		if msg.success && msg.unchanged {
+			m.status = fmt.Sprintf("No changes in %s; not published", filepath.Base(msg.path))
+			m.statusIsError = false
+		} else if msg.success {
+			m.changed[msg.resourceID] = true
 			m.status = fmt.Sprintf("Published: %s", filepath.Base(msg.path))
+			if msg.replaced > 0 {
+				m.status += fmt.Sprintf(" (%d tokens replaced)", msg.replaced)
+			}
 			m.statusIsError = false
+			if msg.warning != "" {
+				m.status += "; warning: " + msg.warning
+				m.statusIsError = true
+			}
+		} else if errors.As(msg.err, &changed) && m.state == StateList && m.inputMode == InputNone {
+			// Ask before clobbering a teammate's edit
+			m.overwrite = changed
+			m.overwriteID = msg.resourceID
+			m.inputMode = InputOverwriteConfirm
+			m.textInput.Placeholder = "Overwrite? (y/n)"
+			m.textInput.SetValue("")
+			m.textInput.Focus()
 		} else {
 			m.status = fmt.Sprintf("Publish failed: %v", msg.err)
+			if m.failed[msg.resourceID] {
+				m.status = fmt.Sprintf("Auto-publish of %s failed (%s: publish again): %v", filepath.Base(msg.path), m.keyFor(StateList, "publish"), msg.err)
+			}
+			m.statusIsError = true
+			m.err = msg.err
+		}
+
+	case retryPublishMsg:
+		// Skip the retry if a later publish succeeded, failed again and
+		// scheduled its own retry, or is still running, or if the
+		// environment was left
+		if attempt, ok := m.retrying[msg.resourceID]; !ok || attempt != msg.attempt || m.publishing[msg.resourceID] {
+			return m, nil
+		}
+		b := m.config.GetBinding(m.config.CurrentEnvironment, msg.resourceID)
+		if b == nil || !b.AutoPublish || m.autoPublishHeld() {
+			delete(m.retrying, msg.resourceID)
+			return m, nil
+		}
+		m.publishing[msg.resourceID] = true
+		return m, m.retryBinding(*b)
+
+	case directoryPublishMsg:
+		delete(m.dirPublishing, msg.path)
+		if msg.reAuth {
+			return m.Update(reAuthRequiredMsg{})
+		}
+		if msg.err != nil && m.reportOffline(msg.err) {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Publish of %s failed: %v", filepath.Base(msg.path), msg.err)
 			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		r := msg.result
+		res := r.Resource
+		m.changed[res.ID] = true
+		switch {
+		case r.Created:
+			m.allResources = append(m.allResources, res)
+			if m.searchQuery == "" {
+				m.resources = m.allResources
+				m.buildTree()
+			}
+			m.status = fmt.Sprintf("Created and published %s from %s", res.Name, filepath.Base(msg.path))
+		case r.Result.Unchanged:
+			m.status = fmt.Sprintf("Bound %s to %s; no changes to publish", filepath.Base(msg.path), res.Name)
+		default:
+			m.status = fmt.Sprintf("Bound and published %s to %s", filepath.Base(msg.path), res.Name)
+		}
+		m.statusIsError = false
+		if r.Result.Warning != "" {
+			m.status += "; warning: " + r.Result.Warning
+			m.statusIsError = true
+		}
+
+	case promoteMsg:
+		return m.handlePromoteResult(msg)
+
+	case diffMsg:
+		if m.state != StateDiff || m.diffResource == nil || m.diffResource.ID != msg.resourceID {
+			return m, nil
+		}
+		m.diffLoading = false
+		m.diffLines = msg.lines
+		m.diffBinary = msg.binary
+		m.diffChanged = msg.changed
+		m.diffReplaced = msg.replaced
+		m.diffWarning = msg.warning
+		m.diffErr = msg.err
+		return m, nil
+
+	case batchProgressMsg:
+		if msg.batchID == m.batchProgressID {
+			m.status = fmt.Sprintf("Publishing %d/%d...", msg.done, msg.total)
+			m.statusIsError = false
+		}
+		return m, waitForBatchProgress(msg.progress)
+
+	case batchPublishMsg:
+		if msg.batchID == m.batchProgressID {
+			m.batchProgressID = 0
+		}
+		for _, id := range msg.ids {
+			delete(m.publishing, id)
+		}
+		if msg.reAuth {
+			return m.Update(reAuthRequiredMsg{})
+		}
+		if msg.err != nil && m.reportOffline(msg.err) {
+			return m, nil
+		}
+		r := msg.result
+		if r == nil {
+			r = &publisher.BatchResult{}
+		}
+		errs := r.Failed
+		if msg.err != nil {
+			errs = append(errs, fmt.Errorf("publish: %w", msg.err))
+			m.status = fmt.Sprintf("Uploaded %d files but the publish failed: %v", len(msg.ids)-r.Unchanged-len(r.Failed), msg.err)
+		} else {
+			for _, b := range r.Published {
+				m.changed[b.WebResourceID] = true
+			}
+			m.status = fmt.Sprintf("Published %d in one batch, %d unchanged", len(r.Published), r.Unchanged)
+			if len(r.Failed) > 0 {
+				m.status += fmt.Sprintf(", %d failed (press %s for details)", len(r.Failed), m.keyFor(StateList, "errorDetails"))
+			}
+		}
+		m.statusIsError = len(errs) > 0
+		if len(errs) > 0 {
+			m.err = errors.Join(errs...)
+		}
+		return m, nil
+
+	case restoreMsg:
+		delete(m.publishing, msg.resourceID)
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Restore of %s failed: %v", msg.name, msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Restored and published %s", msg.name)
+		m.statusIsError = false
+		return m, nil
+
+	case undoMsg:
+		m.undoRunning = false
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Undo failed: %v", msg.err)
+			var changed *publisher.ServerChangedError
+			if errors.As(msg.err, &changed) {
+				m.status += "; it was changed on the server since, so it was left as is"
+			}
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Reverted %s to the content before its last publish", msg.name)
+		m.statusIsError = false
+		return m, nil
+
+	case reauthAllMsg:
+		m.reauthRunning = false
+		m.reauthQueue = msg.needLogin
+		for _, name := range msg.refreshed {
+			m.envHealth[name] = HealthReady
+		}
+		m.status = fmt.Sprintf("Refreshed %d, %d need login", len(msg.refreshed), len(msg.needLogin))
+		if len(msg.needLogin) > 0 {
+			m.status += fmt.Sprintf("; press %s to sign in to %s", m.keyFor(StateEnvironmentSelect, "nextLogin"), msg.needLogin[0])
+		}
+		m.statusIsError = false
+		return m, nil
+
+	case reauthLoginMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Sign-in to %s failed: %v", msg.envName, msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		m.envHealth[msg.envName] = HealthReady
+		if len(m.reauthQueue) > 0 && m.reauthQueue[0] == msg.envName {
+			m.reauthQueue = m.reauthQueue[1:]
+		}
+		m.status = fmt.Sprintf("Signed in to %s", msg.envName)
+		if len(m.reauthQueue) > 0 {
+			m.status += fmt.Sprintf("; press %s to sign in to %s (%d left)", m.keyFor(StateEnvironmentSelect, "nextLogin"), m.reauthQueue[0], len(m.reauthQueue))
+		}
+		m.statusIsError = false
+		return m, nil
+
+	case displayNameMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Failed to update display name: %v", msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		// Keep the loaded lists and the details view in step with the server
+		for _, list := range [][]d365.WebResource{m.resources, m.allResources} {
+			for i := range list {
+				if list[i].ID == msg.resourceID {
+					list[i].DisplayName = msg.displayName
+				}
+			}
+		}
+		if m.detailsResource != nil && m.detailsResource.ID == msg.resourceID {
+			m.detailsResource.DisplayName = msg.displayName
+		}
+		if binding := m.config.GetBinding(m.config.CurrentEnvironment, msg.resourceID); binding != nil {
+			updated := *binding
+			updated.DisplayName = msg.displayName
+			m.config.AddBinding(updated)
+		}
+		m.status = fmt.Sprintf("Display name set to %q", msg.displayName)
+		m.statusIsError = false
+		return m, nil
+
+	case connectedMsg:
+		if msg.envName != m.config.CurrentEnvironment {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.connectedAs = ""
+			m.refreshing = false
+			m.status = m.connectionProblem(m.config.GetEnvironment(msg.envName), msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		m.connectedAs = msg.user.UserName
+		if m.connectedAs == "" {
+			m.connectedAs = msg.user.UserID
+		}
+		m.status = fmt.Sprintf("Connected to %s as %s; loading web resources...", msg.envName, m.connectedAs)
+		m.statusIsError = false
+		return m, m.fetchResources()
+
+	case deleteResourceMsg:
+		delete(m.publishing, msg.resourceID)
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Failed to delete %s: %v", msg.name, msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		m.resources = slices.DeleteFunc(m.resources, func(r d365.WebResource) bool { return r.ID == msg.resourceID })
+		m.allResources = slices.DeleteFunc(m.allResources, func(r d365.WebResource) bool { return r.ID == msg.resourceID })
+		m.status = fmt.Sprintf("Deleted %s", msg.name)
+		m.statusIsError = false
+		if b := m.config.GetBinding(m.config.CurrentEnvironment, msg.resourceID); b != nil {
+			if m.watcher != nil && b.AutoPublish {
+				m.unwatchBinding(*b)
+			}
+			if err := m.config.DeleteBinding(m.config.CurrentEnvironment, msg.resourceID); err != nil {
+				m.status = fmt.Sprintf("Deleted %s, but failed to remove its binding: %v", msg.name, err)
+				m.statusIsError = true
+			} else {
+				m.status += " and removed its binding"
+			}
 		}
+		m.buildTree()
+		m.resourceSelected = min(m.resourceSelected, max(len(m.displayItems)-1, 0))
+		m.bindingSelected = min(m.bindingSelected, max(len(m.fileListBindings())-1, 0))
+		return m, nil
+
+	case renameMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Failed to rename %s: %v", msg.oldName, msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		for _, list := range [][]d365.WebResource{m.resources, m.allResources} {
+			for i := range list {
+				if list[i].ID == msg.resourceID {
+					list[i].Name = msg.name
+				}
+			}
+		}
+		if m.detailsResource != nil && m.detailsResource.ID == msg.resourceID {
+			m.detailsResource.Name = msg.name
+		}
+		if binding := m.config.GetBinding(m.config.CurrentEnvironment, msg.resourceID); binding != nil {
+			updated := *binding
+			updated.WebResourceName = msg.name
+			m.config.AddBinding(updated)
+		}
+		m.buildTree()
+		if msg.publishErr != nil {
+			m.status = fmt.Sprintf("Renamed %s to %s, but publishing failed: %v", msg.oldName, msg.name, msg.publishErr)
+			m.statusIsError = true
+			m.err = msg.publishErr
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Renamed %s to %s", msg.oldName, msg.name)
+		m.statusIsError = false
+		return m, nil
+
+	case envHealthMsg:
+		for name, health := range msg {
+			m.envHealth[name] = health
+		}
+		return m, nil
+
+	case bindVerifiedMsg:
+		switch {
+		case msg.err == nil:
+			m.bindFile(msg.resource, msg.path)
+		case errors.Is(msg.err, d365.ErrNotFound):
+			m.cloneFrom = nil
+			m.status = fmt.Sprintf("%s no longer exists on the server; press %s to refresh the list", msg.resource.Name, m.keyFor(StateList, "refresh"))
+			m.statusIsError = true
+		case m.reportOffline(msg.err):
+			// The offline indicator already explains the failure
+		default:
+			m.status = fmt.Sprintf("Cannot verify %s: %v", msg.resource.Name, msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+		}
+
+	case publishAllMsg:
+		m.publishingAll = false
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Publish all failed: %v", msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+		} else {
+			m.status = "Published all customizations"
+			m.statusIsError = false
+		}
+
+	case watchErrorMsg:
+		m.status = fmt.Sprintf("Auto-publish: %v", error(msg))
+		m.statusIsError = true
+		m.err = msg
+		return m, waitForWatchError(m.watchErrChan)
 
 	case fileChangeMsg:
 		// Mark resources as publishing if they have auto-publish enabled
 		path := string(msg)
+		if m.autoPublishHeld() {
+			m.status = fmt.Sprintf("Not published %s: auto-publish in %s is not armed (%s: arm)", filepath.Base(path), m.config.CurrentEnvironment, m.keyFor(StateList, "arm"))
+			m.statusIsError = true
+			return m, waitForFileChange(m.fileChangeChan)
+		}
 		bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+		bound := false
 		for _, b := range bindings {
-			absPath, _ := filepath.Abs(b.LocalPath)
-			if absPath == path && b.AutoPublish {
+			bound = bound || b.Watches(path)
+			if b.Watches(path) && b.AutoPublish {
+				if _, ok := m.snoozeRemaining(b.WebResourceID); ok {
+					m.status = fmt.Sprintf("Skipped auto-publish for %s (snoozed)", b.WebResourceName)
+					m.statusIsError = false
+					return m, waitForFileChange(m.fileChangeChan)
+				}
+				delete(m.snoozed, b.WebResourceID)
 				m.publishing[b.WebResourceID] = true
 				break
 			}
 		}
+		// A new file in a bound folder is published through its directory binding
+		if dir, ok := m.config.DirectoryBindingFor(m.config.CurrentEnvironment, path); ok && !bound {
+			// A second save before the first publish is done would create
+			// the web resource twice
+			if m.dirPublishing[path] {
+				return m, waitForFileChange(m.fileChangeChan)
+			}
+			m.dirPublishing[path] = true
+			m.status = fmt.Sprintf("Publishing %s...", filepath.Base(path))
+			m.statusIsError = false
+			return m, tea.Batch(
+				m.publishDirectoryFile(dir, path),
+				waitForFileChange(m.fileChangeChan),
+			)
+		}
 		// Continue listening for more file changes
 		return m, tea.Batch(
 			m.handleFileChange(path),
@@ -173,6 +847,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		)
 
 	case errMsg:
+		m.refreshing = false
+		if m.reportOffline(msg) {
+			return m, nil
+		}
 		m.status = fmt.Sprintf("Error: %v", msg)
 		m.statusIsError = true
 		m.err = msg
@@ -193,25 +871,43 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.status = "Authentication required to write token.json"
 		m.statusIsError = false
 		m.state = StateAuth
-		return m, m.authenticateInteractive()
+		m.authErr = nil
+		cmd := m.authenticate()
+		return m, cmd
 
 	case solutionsMsg:
 		m.solutions = msg
 		m.loadingSolutions = false
+		// Start on the solution picked last time
+		if env := m.config.GetEnvironment(m.config.CurrentEnvironment); env != nil {
+			for i, s := range msg {
+				if s.UniqueName == env.DefaultSolution {
+					m.solutionSelected = i
+				}
+			}
+		}
 		if len(msg) == 0 {
 			m.status = "No solutions found"
 			m.statusIsError = true
 			m.state = StateList
 			m.solutionResource = nil
+			m.pickingFilter = false
 		}
 
 	case addToSolutionMsg:
 		if msg.success {
+			if m.config.GetBinding(m.config.CurrentEnvironment, msg.resourceID) != nil {
+				m.config.SetBindingSolution(m.config.CurrentEnvironment, msg.resourceID, msg.solutionID)
+			}
+			if names, ok := m.solutionsOf[msg.resourceID]; ok && !slices.Contains(names, msg.solutionID) {
+				m.solutionsOf[msg.resourceID] = append(names, msg.solutionID)
+			}
 			m.status = fmt.Sprintf("Added %s to %s", msg.resourceName, msg.solutionName)
 			m.statusIsError = false
 		} else {
 			m.status = fmt.Sprintf("Failed to add to solution: %v", msg.err)
 			m.statusIsError = true
+			m.err = msg.err
 		}
 		m.state = StateList
 		m.solutionResource = nil
@@ -225,13 +921,54 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.state = StateCreatePrefixInput
 			m.textInput.SetValue(m.createPrefix)
-			m.textInput.Placeholder = "e.g., publisher_/AppName/"
+			m.textInput.Placeholder = fmt.Sprintf("e.g., %s_/AppName/", m.publisherPrefix())
+		}
+
+	case syncCheckMsg:
+		// Ignore results from a cancelled or superseded scan
+		if msg.scanID != m.syncScanID || !m.syncScanning {
+			return m, nil
+		}
+		m.syncResults = append(m.syncResults, msg.result)
+		if len(m.syncResults) < len(m.syncBindings) {
+			return m, m.checkBindingSync(m.syncScanID, m.syncBindings[len(m.syncResults)])
+		}
+		m.syncScanning = false
+		m.status = fmt.Sprintf("Checked %d bindings", len(m.syncResults))
+		m.statusIsError = false
+
+	case searchResultsMsg:
+		if msg.query != m.searchQuery {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Search failed: %v", msg.err)
+			m.statusIsError = true
+			m.err = msg.err
+			return m, nil
+		}
+		m.resources = msg.resources
+		m.resourceSelected = 0
+		m.buildTree()
+		if msg.local {
+			m.status = fmt.Sprintf("%d matches for %q (filtered locally)", len(msg.resources), msg.query)
+		} else {
+			m.status = fmt.Sprintf("%d matches for %q", len(msg.resources), msg.query)
+		}
+		m.statusIsError = false
+
+	case resourceAuditMsg:
+		if m.detailsResource == nil || m.detailsResource.ID != msg.resourceID {
+			return m, nil
 		}
+		m.detailsLoading = false
+		m.detailsAudit = msg.audit
+		m.detailsErr = msg.err
 
 	case createResourcesMsg:
 		m.creatingResources = false
 		if msg.success {
-			m.status = fmt.Sprintf("Created %d web resources", len(msg.created))
+			m.status = fmt.Sprintf("Created and bound %d web resources", len(msg.created))
 			m.statusIsError = false
 		} else {
 			if len(msg.created) > 0 {
@@ -240,6 +977,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.status = fmt.Sprintf("Failed to create resources: %v", msg.err)
 			}
 			m.statusIsError = true
+			m.err = msg.err
 		}
 		m.state = StateList
 		m.createSolution = nil
@@ -257,6 +995,30 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleInputMode(msg)
 	}
 
+	if m.showHelp {
+		return m.handleHelpKey(msg)
+	}
+	if msg.String() == "?" && hasHelp(m.state) {
+		m.showHelp = true
+		m.helpScroll = 0
+		return m, nil
+	}
+
+	if m.readOnly && readOnlyActions[m.state][m.action(m.state, msg.String())] {
+		m.status = "Disabled in read-only mode"
+		m.statusIsError = true
+		return m, nil
+	}
+
+	if confirmActions[m.state][m.action(m.state, msg.String())] && m.requiresConfirm() {
+		// Replayed through the state's key handler once confirmed
+		m.confirmKey = &msg
+		m.inputMode = InputProductionConfirm
+		m.textInput.SetValue("")
+		m.textInput.Focus()
+		return m, nil
+	}
+
 	switch m.state {
 	case StateEnvironmentSelect:
 		return m.handleEnvSelectKey(msg)
@@ -270,6 +1032,12 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleFilePickerKey(msg)
 	case StateSolutionPicker:
 		return m.handleSolutionPickerKey(msg)
+	case StateSnapshotPicker:
+		return m.handleSnapshotPickerKey(msg)
+	case StateHistory:
+		return m.handleHistoryKey(msg)
+	case StateDiff:
+		return m.handleDiffKey(msg)
 	case StateCreateModeSelect:
 		return m.handleCreateModeSelectKey(msg)
 	case StateCreateFilePicker, StateCreateFolderPicker:
@@ -280,17 +1048,57 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleCreatePrefixInputKey(msg)
 	case StateCreateConfirm:
 		return m.handleCreateConfirmKey(msg)
+	case StateSyncScan:
+		return m.handleSyncScanKey(msg)
+	case StateResourceDetails:
+		return m.handleResourceDetailsKey(msg)
+	case StateErrorDetails:
+		return m.handleErrorDetailsKey(msg)
 	}
 
 	return m, nil
 }
 
+// handleHelpKey scrolls and closes the help overlay
+func (m Model) handleHelpKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "?", "q":
+		m.showHelp = false
+	case "up", "k":
+		if m.helpScroll > 0 {
+			m.helpScroll--
+		}
+	case "down", "j":
+		m.helpScroll++
+	}
+	return m, nil
+}
+
 func (m Model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "esc":
 		m.inputMode = InputNone
 		m.textInput.SetValue("")
-		m.editingEnvName = ""
+		if m.promotion != nil && !m.promotion.running {
+			m.status = strings.Join(append(m.promotion.report, "promotion stopped"), "; ")
+			m.statusIsError = false
+			m.promotion = nil
+		}
+		if m.envEdit != nil {
+			m.envEdit = nil
+			m.status = "Environment changes discarded"
+			m.statusIsError = false
+		}
+		m.importPath = ""
+		m.configImport = nil
+		m.deleteTarget = nil
+		m.confirmKey = nil
+		if m.state == StateBinding {
+			m.state = StateList
+			m.bindingResource = nil
+		}
 		return m, nil
 
 	case "enter":
@@ -299,80 +1107,391 @@ func (m Model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 		switch m.inputMode {
 		case InputEnvironmentName:
-			if value != "" {
-				if m.editingEnvName != "" {
-					// Editing existing - check if this env exists
-					existing := m.config.GetEnvironment(m.editingEnvName)
-					if existing != nil {
-						// Store new name temporarily, move to URL input
-						m.inputMode = InputEnvironmentURL
-						m.textInput.SetValue(existing.URL)
-						m.textInput.Placeholder = "Environment URL"
-						// Store new name in editingEnvName for later use
-						m.editingEnvName = m.editingEnvName + "|" + value
-					}
-				} else {
-					// Adding new
-					m.editingEnvName = value
-					m.inputMode = InputEnvironmentURL
-					m.textInput.Placeholder = "https://org.crm.dynamics.com"
-				}
-			} else {
+			if m.envEdit == nil || value == "" {
 				m.inputMode = InputNone
+				m.envEdit = nil
+				return m, nil
 			}
+			m.envEdit.name = value
+			m.inputMode = InputEnvironmentURL
+			m.textInput.Placeholder = "https://org.crm.dynamics.com"
+			m.textInput.SetValue(m.envEdit.url)
 			return m, nil
 
 		case InputEnvironmentURL:
-			if value != "" {
-				var err error
-				if strings.Contains(m.editingEnvName, "|") {
-					// Editing existing environment
-					parts := strings.SplitN(m.editingEnvName, "|", 2)
-					oldName := parts[0]
-					newName := parts[1]
-					err = m.config.UpdateEnvironment(oldName, newName, value)
-				} else if m.editingEnvName != "" {
-					// Adding new environment
-					err = m.config.AddEnvironment(m.editingEnvName, value)
-				}
-				if err != nil {
-					m.status = err.Error()
+			if m.envEdit == nil || value == "" {
+				m.inputMode = InputNone
+				m.envEdit = nil
+				return m, nil
+			}
+			if err := config.ValidateEnvironmentURL(value); err != nil {
+				// Stay on the URL step so the value can be corrected
+				m.status = err.Error()
+				m.statusIsError = true
+				m.textInput.SetValue(value)
+				return m, nil
+			}
+			m.envEdit.url = value
+			m.inputMode = InputEnvironmentConfirm
+			m.textInput.Placeholder = "Save? (y/n)"
+			return m, nil
+
+		case InputEnvironmentConfirm:
+			edit := m.envEdit
+			m.inputMode = InputNone
+			m.envEdit = nil
+			if edit == nil || strings.ToLower(value) != "y" {
+				m.status = "Environment changes discarded"
+				m.statusIsError = false
+				return m, nil
+			}
+			var err error
+			if edit.originalName != "" {
+				err = m.config.UpdateEnvironment(edit.originalName, edit.name, edit.url)
+			} else {
+				err = m.config.AddEnvironment(edit.name, edit.url)
+			}
+			if err != nil {
+				m.status = err.Error()
+				m.statusIsError = true
+			} else {
+				m.status = "Environment saved"
+				m.statusIsError = false
+			}
+			return m, nil
+
+		case InputBindingPath:
+			path := expandHome(value)
+			if _, err := config.ValidateBindingPath(path); err != nil {
+				// Stay on the path so it can be corrected
+				m.bindPathErr = err
+				m.textInput.SetValue(value)
+				return m, nil
+			}
+			m.inputMode = InputNone
+			m.state = StateList
+			res := m.bindingResource
+			m.bindingResource = nil
+			if res == nil {
+				return m, nil
+			}
+			bindCmd := m.verifyAndBind(*res, path)
+			return m, bindCmd
+
+		case InputEnvironmentPrefix:
+			if m.envSelected < len(m.config.Environments) {
+				env := m.config.Environments[m.envSelected]
+				if err := m.config.UpdateEnvironmentPublisherPrefix(env.Name, value); err != nil {
+					m.status = fmt.Sprintf("Failed to save prefix: %v", err)
 					m.statusIsError = true
 				} else {
-					m.status = "Environment saved"
+					m.status = fmt.Sprintf("Publisher prefix for %s set to %q", env.Name, m.config.PublisherPrefixFor(env.Name))
 					m.statusIsError = false
 				}
 			}
 			m.inputMode = InputNone
-			m.editingEnvName = ""
 			return m, nil
 
-		case InputBindingPath:
-			if value != "" && m.resourceSelected < len(m.resources) {
-				res := m.resources[m.resourceSelected]
-				binding := config.Binding{
-					Environment:      m.config.CurrentEnvironment,
-					LocalPath:        value,
-					WebResourceName:  res.Name,
-					WebResourceID:    res.ID,
-					LastKnownVersion: "1.0.0",
-					AutoPublish:      true,
+		case InputBindingFilter:
+			m.inputMode = InputNone
+			m.bindingFilter = value
+			m.bindingSelected = 0
+			return m, nil
+
+		case InputBindingLabel:
+			m.inputMode = InputNone
+			bindings := m.fileListBindings()
+			if m.bindingSelected >= len(bindings) {
+				return m, nil
+			}
+			b := bindings[m.bindingSelected]
+			if err := m.config.UpdateBindingLabel(b.Environment, b.WebResourceID, value); err != nil {
+				m.status = fmt.Sprintf("Failed to save label: %v", err)
+				m.statusIsError = true
+			} else if value == "" {
+				m.status = fmt.Sprintf("Cleared label of %s", b.WebResourceName)
+				m.statusIsError = false
+			} else {
+				m.status = fmt.Sprintf("Labelled %s", b.WebResourceName)
+				m.statusIsError = false
+			}
+			return m, nil
+
+		case InputSearch:
+			m.inputMode = InputNone
+			if value == "" {
+				return m, m.clearSearch()
+			}
+			m.searchQuery = value
+			m.status = fmt.Sprintf("Searching for %q...", value)
+			m.statusIsError = false
+			return m, m.searchResources(value)
+
+		case InputDisplayName:
+			m.inputMode = InputNone
+			switch m.state {
+			case StateCreateConfirm:
+				if m.createFileSelected < len(m.createFiles) {
+					m.createFiles[m.createFileSelected].DisplayName = value
 				}
-				if err := m.config.AddBinding(binding); err != nil {
-					m.status = fmt.Sprintf("Failed to save binding: %v", err)
-					m.statusIsError = true
-				} else {
-					m.status = fmt.Sprintf("Bound %s to %s", res.Name, value)
+			case StateResourceDetails:
+				if m.detailsResource != nil && value != "" && value != m.detailsResource.DisplayName {
+					m.status = "Updating display name..."
 					m.statusIsError = false
-					// Add to watcher
-					if m.watcher != nil {
-						absPath, _ := filepath.Abs(value)
-						m.watcher.AddFile(absPath)
-					}
+					return m, m.updateDisplayName(*m.detailsResource, value)
 				}
 			}
+			return m, nil
+
+		case InputDeleteResourceConfirm:
 			m.inputMode = InputNone
+			res := m.deleteTarget
+			m.deleteTarget = nil
+			if res == nil {
+				return m, nil
+			}
+			if value != path.Base(res.Name) {
+				m.status = fmt.Sprintf("Delete of %s cancelled", res.Name)
+				m.statusIsError = false
+				return m, nil
+			}
+			m.publishing[res.ID] = true
+			m.status = fmt.Sprintf("Deleting %s...", res.Name)
+			m.statusIsError = false
+			return m, m.deleteResource(*res)
+
+		case InputResourceName:
+			m.inputMode = InputNone
+			res := m.detailsResource
+			if res == nil || value == "" || value == res.Name {
+				return m, nil
+			}
+			if err := m.validateResourceName(value); err != nil {
+				m.status = err.Error()
+				m.statusIsError = true
+				return m, nil
+			}
+			m.status = fmt.Sprintf("Renaming %s...", res.Name)
+			m.statusIsError = false
+			return m, m.renameResource(*res, value)
+
+		case InputSnoozeMinutes:
+			m.inputMode = InputNone
+			target := m.snoozeTarget
+			m.snoozeTarget = nil
+			if target == nil {
+				return m, nil
+			}
+			if value == "" {
+				m.snoozed[target.WebResourceID] = time.Time{}
+				m.status = fmt.Sprintf("Auto-publish snoozed for %s until you press %s again", target.WebResourceName, m.keyFor(StateList, "snooze"))
+				m.statusIsError = false
+				return m, nil
+			}
+			minutes, err := strconv.Atoi(value)
+			if err != nil || minutes <= 0 {
+				m.status = "Snooze duration must be a positive number of minutes"
+				m.statusIsError = true
+				return m, nil
+			}
+			m.snoozed[target.WebResourceID] = time.Now().Add(time.Duration(minutes) * time.Minute)
+			m.status = fmt.Sprintf("Auto-publish snoozed for %s for %d minutes", target.WebResourceName, minutes)
+			m.statusIsError = false
+			return m, nil
+
+		case InputPublishAllConfirm:
+			m.inputMode = InputNone
+			if strings.ToLower(value) != "y" {
+				m.status = "Publish all cancelled"
+				m.statusIsError = false
+				return m, nil
+			}
+			m.publishingAll = true
+			m.status = "Publishing all customizations (this can take several minutes)..."
+			m.statusIsError = false
+			return m, m.publishAllCustomizations()
+
+		case InputPromoteConfirm:
+			m.inputMode = InputNone
+			p := m.promotion
+			if p == nil {
+				return m, nil
+			}
+			if strings.ToLower(value) != "y" {
+				m.promotion = nil
+				m.status = strings.Join(append(p.report, "promotion stopped"), "; ")
+				m.statusIsError = false
+				return m, nil
+			}
+			p.running = true
+			m.status = fmt.Sprintf("Promoting %d resources to %s...", len(p.files), p.target)
+			m.statusIsError = false
+			return m, m.promote(p.target, p.files)
+
+		case InputProductionConfirm:
+			m.inputMode = InputNone
+			key := m.confirmKey
+			m.confirmKey = nil
+			if key == nil {
+				return m, nil
+			}
+			if strings.ToLower(value) != "y" {
+				m.status = fmt.Sprintf("Publish to %s cancelled", m.config.CurrentEnvironment)
+				m.statusIsError = false
+				return m, nil
+			}
+			if m.state == StateResourceDetails {
+				return m.handleResourceDetailsKey(*key)
+			}
+			return m.handleListKey(*key)
+
+		case InputOverwriteConfirm:
+			m.inputMode = InputNone
+			changed, id := m.overwrite, m.overwriteID
+			m.overwrite, m.overwriteID = nil, ""
+			if changed == nil {
+				return m, nil
+			}
+			if strings.ToLower(value) != "y" {
+				m.status = fmt.Sprintf("Publish of %s cancelled; the server version was kept", changed.Name)
+				m.statusIsError = false
+				return m, nil
+			}
+			m.publishing[id] = true
+			m.status = fmt.Sprintf("Overwriting %s...", changed.Name)
+			m.statusIsError = false
+			return m, m.publishResource(d365.WebResource{ID: id, Name: changed.Name}, true, true)
+
+		case InputRestoreConfirm:
+			m.inputMode = InputNone
+			if strings.ToLower(value) != "y" || m.snapshotBinding == nil || m.snapshotSelected >= len(m.snapshots) {
+				return m, nil
+			}
+			binding := *m.snapshotBinding
+			snapshot := m.snapshots[m.snapshotSelected]
 			m.state = StateList
+			m.snapshots = nil
+			m.snapshotBinding = nil
+			m.publishing[binding.WebResourceID] = true
+			m.status = fmt.Sprintf("Restoring %s from %s...", binding.WebResourceName, snapshot.Time.Format("2006-01-02 15:04:05"))
+			m.statusIsError = false
+			return m, m.restoreSnapshot(binding, snapshot)
+
+		case InputOrphanTokensConfirm:
+			files, entries := m.orphanTokens, m.orphanEntries
+			m.orphanTokens = nil
+			m.orphanEntries = nil
+			m.inputMode = InputNone
+			if strings.ToLower(value) != "y" {
+				return m, nil
+			}
+			removed, err := config.RemoveOrphanedTokens(files, entries)
+			if err != nil {
+				m.status = fmt.Sprintf("Removed %d of %d tokens: %v", removed, len(files)+len(entries), err)
+				m.statusIsError = true
+			} else {
+				m.status = fmt.Sprintf("Removed %d orphaned tokens", removed)
+				m.statusIsError = false
+			}
+			return m, nil
+
+		case InputSignOutConfirm:
+			m.inputMode = InputNone
+			if strings.ToLower(value) != "y" || m.envSelected >= len(m.config.Environments) {
+				return m, nil
+			}
+			env := m.config.Environments[m.envSelected]
+			if err := auth.SignOut(env, m.config.Environments); err != nil {
+				m.status = fmt.Sprintf("Failed to sign out: %v", err)
+				m.statusIsError = true
+				m.err = err
+				return m, nil
+			}
+			if env.Name == m.config.CurrentEnvironment {
+				m.token = nil
+				m.client = nil
+			}
+			m.envHealth[env.Name] = HealthNoToken
+			m.status = fmt.Sprintf("Signed out of %s", env.Name)
+			m.statusIsError = false
+			return m, nil
+
+		case InputExportPath:
+			m.inputMode = InputNone
+			if value == "" {
+				return m, nil
+			}
+			if err := exportConfig(m.config, value); err != nil {
+				m.status = fmt.Sprintf("Export failed: %v", err)
+				m.statusIsError = true
+				m.err = err
+				return m, nil
+			}
+			m.status = fmt.Sprintf("Exported %d environments and their bindings to %s (no tokens)", len(m.config.Environments), value)
+			m.statusIsError = false
+			return m, nil
+
+		case InputImportPath:
+			if value == "" {
+				m.inputMode = InputNone
+				return m, nil
+			}
+			export, err := readConfigExport(value)
+			if err != nil {
+				m.inputMode = InputNone
+				m.status = fmt.Sprintf("Import failed: %v", err)
+				m.statusIsError = true
+				m.err = err
+				return m, nil
+			}
+			m.importPath = value
+			m.configImport = export
+			// Hooks and build commands run on the next publish, so they
+			// are shown and confirmed before anything else
+			if len(export.Commands()) > 0 {
+				m.inputMode = InputImportCommandsConfirm
+				m.textInput.Placeholder = "Import these commands? (y/n)"
+				return m, nil
+			}
+			m.inputMode = InputImportMode
+			m.textInput.Placeholder = "m: merge • r: replace"
+			return m, nil
+
+		case InputImportCommandsConfirm:
+			if strings.ToLower(value) != "y" {
+				m.inputMode = InputNone
+				m.importPath = ""
+				m.configImport = nil
+				m.status = "Import cancelled"
+				m.statusIsError = false
+				return m, nil
+			}
+			m.inputMode = InputImportMode
+			m.textInput.Placeholder = "m: merge • r: replace"
+			return m, nil
+
+		case InputImportMode:
+			m.inputMode = InputNone
+			path, export := m.importPath, m.configImport
+			m.importPath = ""
+			m.configImport = nil
+			mode := strings.ToLower(value)
+			if mode != "m" && mode != "r" || export == nil {
+				m.status = "Import cancelled"
+				m.statusIsError = false
+				return m, nil
+			}
+			result, err := m.config.ImportConfig(export, mode == "m")
+			if err != nil {
+				m.status = fmt.Sprintf("Import failed: %v", err)
+				m.statusIsError = true
+				m.err = err
+				return m, nil
+			}
+			m.envSelected = min(m.envSelected, max(len(m.config.Environments)-1, 0))
+			m.status = fmt.Sprintf("Imported %d environments, %d bindings and %d directory bindings from %s",
+				result.Environments, result.Bindings, result.DirectoryBindings, path)
+			m.statusIsError = false
 			return m, nil
 
 		case InputDeleteConfirm:
@@ -394,44 +1513,92 @@ func (m Model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	var cmd tea.Cmd
-	m.textInput, cmd = m.textInput.Update(msg)
-	return m, cmd
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.Update(msg)
+	if m.inputMode == InputBindingPath {
+		// Validate as the path is typed so typos show before enter
+		_, m.bindPathErr = config.ValidateBindingPath(expandHome(m.textInput.Value()))
+	}
+	if m.inputMode == InputBindingFilter {
+		// Filter the File List as the query is typed
+		m.bindingFilter = strings.TrimSpace(m.textInput.Value())
+		m.bindingSelected = 0
+	}
+	return m, cmd
+}
+
+// readOnlyActions lists the actions of each state that write files, change
+// bindings or modify the environment, all of which are blocked in read-only mode
+var readOnlyActions = map[State]map[string]bool{
+	StateEnvironmentSelect: {
+		"delete": true, "signOut": true, "cleanTokens": true, "tokenRoot": true,
+		"clearTokenRoot": true, "exportConfig": true, "importConfig": true, "refreshExpired": true,
+		"nextLogin": true,
+	},
+	StateList: {
+		"bind": true, "bindPath": true, "unbind": true, "toggleAuto": true, "publish": true, "addToSolution": true,
+		"refreshToken": true, "publishBound": true, "restore": true, "clone": true, "promote": true,
+		"create": true, "publishAll": true, "repoint": true, "republishLast": true, "label": true, "delete": true,
+		"undo": true,
+	},
+	StateResourceDetails: {"displayName": true, "rename": true},
+	StateDiff:            {"publish": true},
+}
+
+// confirmActions lists the actions of each state that publish without asking
+// first, so environments with requireConfirm set ask for them. Creating web
+// resources publishes them and so does renaming one. Publishing from the
+// diff, publish all, restore and promotion already ask.
+var confirmActions = map[State]map[string]bool{
+	StateList:            {"publish": true, "publishBound": true, "republishLast": true, "undo": true, "create": true},
+	StateResourceDetails: {"rename": true},
+}
+
+// requiresConfirm reports whether the current environment asks before publishing
+func (m Model) requiresConfirm() bool {
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+	return env != nil && env.RequireConfirm
+}
+
+// autoPublishHeld reports whether saves wait for auto-publish to be armed in
+// the current environment
+func (m Model) autoPublishHeld() bool {
+	return m.requiresConfirm() && m.armedEnv != m.config.CurrentEnvironment
 }
 
 func (m Model) handleEnvSelectKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "q", "ctrl+c":
+	switch m.action(StateEnvironmentSelect, msg.String()) {
+	case "quit":
 		return m, tea.Quit
 
-	case "up", "k":
+	case "up":
 		if m.envSelected > 0 {
 			m.envSelected--
 		}
 
-	case "down", "j":
+	case "down":
 		if m.envSelected < len(m.config.Environments)-1 {
 			m.envSelected++
 		}
 
-	case "a":
+	case "add":
 		m.inputMode = InputEnvironmentName
 		m.textInput.Placeholder = "Environment name"
 		m.textInput.SetValue("")
-		m.editingEnvName = ""
+		m.envEdit = &envEdit{}
 		return m, nil
 
-	case "e":
+	case "edit":
 		if m.envSelected < len(m.config.Environments) {
 			env := m.config.Environments[m.envSelected]
-			m.editingEnvName = env.Name
+			m.envEdit = &envEdit{originalName: env.Name, name: env.Name, url: env.URL}
 			m.inputMode = InputEnvironmentName
 			m.textInput.Placeholder = "Environment name"
 			m.textInput.SetValue(env.Name)
 		}
 		return m, nil
 
-	case "d":
+	case "delete":
 		if m.envSelected < len(m.config.Environments) {
 			m.inputMode = InputDeleteConfirm
 			m.textInput.Placeholder = "Delete? (y/n)"
@@ -439,26 +1606,83 @@ func (m Model) handleEnvSelectKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "c":
+	case "signOut":
+		if m.envSelected < len(m.config.Environments) {
+			m.inputMode = InputSignOutConfirm
+			m.textInput.Placeholder = "Sign out? (y/n)"
+			m.textInput.SetValue("")
+		}
+		return m, nil
+
+	case "refreshExpired":
+		// Refresh every expired token silently, queueing the ones that need a login
+		if m.reauthRunning {
+			return m, nil
+		}
+		m.reauthRunning = true
+		m.reauthQueue = nil
+		m.status = "Refreshing expired tokens..."
+		m.statusIsError = false
+		return m, m.reauthenticateAll()
+
+	case "nextLogin":
+		// Step through the environments the last reauthenticate-all pass couldn't refresh
+		if len(m.reauthQueue) == 0 {
+			m.status = fmt.Sprintf("No environments waiting for login; press %s to check", m.keyFor(StateEnvironmentSelect, "refreshExpired"))
+			m.statusIsError = false
+			return m, nil
+		}
+		env := m.config.GetEnvironment(m.reauthQueue[0])
+		if env == nil {
+			m.reauthQueue = m.reauthQueue[1:]
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Signing in to %s in the browser...", env.Name)
+		m.statusIsError = false
+		return m, m.signIn(*env)
+
+	case "cleanTokens":
+		files, entries, err := m.config.OrphanedTokens()
+		if err != nil {
+			m.status = fmt.Sprintf("Failed to list token files: %v", err)
+			m.statusIsError = true
+			return m, nil
+		}
+		if len(files)+len(entries) == 0 {
+			m.status = "No orphaned tokens"
+			m.statusIsError = false
+			return m, nil
+		}
+		m.orphanTokens = files
+		m.orphanEntries = entries
+		m.inputMode = InputOrphanTokensConfirm
+		m.textInput.Placeholder = "Delete? (y/n)"
+		m.textInput.SetValue("")
+		return m, nil
+
+	case "health":
+		// Check every environment's token and ping the ones that have a valid token
+		for _, env := range m.config.Environments {
+			m.envHealth[env.Name] = HealthChecking
+		}
+		return m, m.checkEnvironments(true)
+
+	case "prefix":
 		if m.envSelected < len(m.config.Environments) {
 			env := m.config.Environments[m.envSelected]
-			if err := auth.DeleteToken(env.Name); err != nil {
-				m.status = fmt.Sprintf("Failed to clear auth: %v", err)
-				m.statusIsError = true
-			} else {
-				m.status = fmt.Sprintf("Cleared auth for %s", env.Name)
-				m.statusIsError = false
-			}
+			m.inputMode = InputEnvironmentPrefix
+			m.textInput.Placeholder = "Publisher prefix (e.g., contoso)"
+			m.textInput.SetValue(env.PublisherPrefix)
 		}
 		return m, nil
 
-	case "t":
+	case "tokenRoot":
 		if m.envSelected < len(m.config.Environments) {
 			return m.openTokenExportPicker(m.config.Environments[m.envSelected], StateEnvironmentSelect, false)
 		}
 		return m, nil
 
-	case "x":
+	case "clearTokenRoot":
 		if m.envSelected < len(m.config.Environments) {
 			env := m.config.Environments[m.envSelected]
 			if env.TokenOutputDir == "" {
@@ -476,11 +1700,24 @@ func (m Model) handleEnvSelectKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "enter":
+	case "exportConfig":
+		m.inputMode = InputExportPath
+		m.textInput.Placeholder = "File to export to"
+		m.textInput.SetValue("d365tui-config.json")
+		return m, nil
+
+	case "importConfig":
+		m.inputMode = InputImportPath
+		m.textInput.Placeholder = "Config export to import"
+		m.textInput.SetValue("")
+		return m, nil
+
+	case "select":
 		if m.envSelected < len(m.config.Environments) {
 			env := m.config.Environments[m.envSelected]
 			m.config.CurrentEnvironment = env.Name
 			m.config.Save()
+			m.restoreExpandedFolders(env)
 
 			// Try to load existing token
 			if token, err := auth.LoadToken(env.Name); err == nil && !token.IsExpired() {
@@ -489,14 +1726,19 @@ func (m Model) handleEnvSelectKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					m.status = fmt.Sprintf("Token export failed: %v", err)
 					m.statusIsError = true
 				}
-				m.client = d365.NewClient(env.URL, token.AccessToken)
+				m.client = d365.NewClient(env.URL, token.AccessToken, clientOptions(m.config))
+				m.client.SetMaxRetries(m.config.RetryLimit())
+				m.startSession()
+				m.setupTokenRefresh()
 				m.state = StateList
-				return m, m.fetchResources()
+				return m, tea.Batch(m.checkConnection(), m.loadCachedResources())
 			}
 
 			// Need to authenticate
 			m.state = StateAuth
-			return m, m.authenticateInteractive()
+			m.authErr = nil
+			cmd := m.authenticate()
+			return m, cmd
 		}
 	}
 
@@ -508,27 +1750,87 @@ func (m Model) handleAuthKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "q", "ctrl+c":
 		return m, tea.Quit
 	case "esc":
+		m.authErr = nil
+		m.deviceCode = nil
+		m.authAttempt++ // ignore the result of the abandoned sign-in
+		if m.authCancel != nil {
+			m.authCancel()
+		}
 		m.state = StateEnvironmentSelect
+	case "E":
+		return m.openErrorDetails()
+	case "enter", "r":
+		// Retry only once the previous attempt has failed
+		if m.authErr != nil {
+			m.authErr = nil
+			m.authCancelled = false
+			m.status = "Retrying authentication..."
+			m.statusIsError = false
+			cmd := m.authenticate()
+			return m, cmd
+		}
+	case "d", "b":
+		// Switch between a device code and the browser, restarting the sign-in
+		flow := AuthFlowDeviceCode
+		if msg.String() == "b" {
+			flow = AuthFlowBrowser
+		}
+		if env := m.config.GetEnvironment(m.config.CurrentEnvironment); env == nil || env.UsesClientCredentials() ||
+			(flow == AuthFlowDeviceCode) == m.deviceCodeFlow {
+			return m, nil
+		}
+		m.authFlow = flow
+		m.authErr = nil
+		m.authCancelled = false
+		cmd := m.authenticate()
+		return m, cmd
 	}
 	return m, nil
 }
 
 func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "q", "ctrl+c":
+	switch m.action(StateList, msg.String()) {
+	case "quit":
+		m.stopSession()
 		return m, tea.Quit
 
-	case "esc":
+	case "back":
+		if m.cloneFrom != nil {
+			m.cloneFrom = nil
+			m.status = "Clone cancelled"
+			m.statusIsError = false
+			return m, nil
+		}
+		if len(m.marked) > 0 {
+			clear(m.marked)
+			m.status = "Marks cleared"
+			m.statusIsError = false
+			return m, nil
+		}
+		if m.searchQuery != "" {
+			return m, m.clearSearch()
+		}
 		if m.watcher != nil {
 			m.watcher.Clear()
 		}
+		m.stopSession()
+		m.armedEnv = ""
+		m.solutionFilter = nil
+		clear(m.retrying)
+		clear(m.failed)
 		m.state = StateEnvironmentSelect
+		m.refreshing = false
 		m.resources = nil
+		m.allResources = nil
 		m.displayItems = nil
 		m.treeRoot = nil
+		return m, m.checkEnvironments(false)
+
+	case "mark":
+		m.toggleMark()
 		return m, nil
 
-	case "tab":
+	case "switchTab":
 		// Switch between tabs
 		if m.bindingTab == BindingTabBind {
 			m.bindingTab = BindingTabList
@@ -539,7 +1841,7 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "up", "k":
+	case "up":
 		if m.bindingTab == BindingTabBind {
 			if m.resourceSelected > 0 {
 				m.resourceSelected--
@@ -550,19 +1852,19 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-	case "down", "j":
+	case "down":
 		if m.bindingTab == BindingTabBind {
 			if m.resourceSelected < len(m.displayItems)-1 {
 				m.resourceSelected++
 			}
 		} else {
-			bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+			bindings := m.fileListBindings()
 			if m.bindingSelected < len(bindings)-1 {
 				m.bindingSelected++
 			}
 		}
 
-	case "enter":
+	case "expand":
 		if m.bindingTab == BindingTabBind {
 			if m.resourceSelected < len(m.displayItems) {
 				item := m.displayItems[m.resourceSelected]
@@ -573,21 +1875,23 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "b":
+	case "bind":
 		// Only available in Bind Files tab
 		if m.bindingTab == BindingTabBind {
 			if m.resourceSelected < len(m.displayItems) {
 				item := m.displayItems[m.resourceSelected]
 				if !item.Node.IsFolder && item.Resource != nil {
-					// Initialize file picker
-					fp := filepicker.New()
-					// fp.DirAllowed = false
-					fp.CurrentDirectory, _ = os.UserHomeDir()
-					fp.Height = m.height - 6
-					m.filepicker = fp
+					startDir, _ := os.UserHomeDir()
+					if m.cloneFrom != nil {
+						// Start next to the file of the binding being cloned
+						dir := filepath.Dir(m.cloneFrom.LocalPath)
+						if info, err := os.Stat(dir); err == nil && info.IsDir() {
+							startDir = dir
+						}
+					}
 					m.bindingResource = item.Resource
 					m.state = StateFilePicker
-					return m, m.filepicker.Init()
+					return m, m.openBindPicker(startDir)
 				} else {
 					m.status = "Select a file to bind"
 					m.statusIsError = true
@@ -596,14 +1900,52 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "p":
+	case "bindPath":
+		// Type the path instead, for files the picker is slow to reach
+		if m.bindingTab != BindingTabBind || m.resourceSelected >= len(m.displayItems) {
+			return m, nil
+		}
+		item := m.displayItems[m.resourceSelected]
+		if item.Node.IsFolder || item.Resource == nil {
+			m.status = "Select a file to bind"
+			m.statusIsError = true
+			return m, nil
+		}
+		m.bindingResource = item.Resource
+		m.state = StateBinding
+		m.inputMode = InputBindingPath
+		m.bindPathErr = nil
+		m.textInput.SetValue("")
+		if binding := m.config.GetBinding(m.config.CurrentEnvironment, item.Resource.ID); binding != nil {
+			m.textInput.SetValue(binding.LocalPath)
+			// The bound file may be gone; show it before anything is typed
+			_, m.bindPathErr = config.ValidateBindingPath(binding.LocalPath)
+		}
+		m.textInput.CursorEnd()
+		return m, nil
+
+	case "publish":
+		if len(m.marked) > 0 {
+			bindings := m.markedBindings()
+			if len(bindings) == 0 {
+				m.status = "None of the marked files are bound"
+				m.statusIsError = true
+				return m, nil
+			}
+			m.status = fmt.Sprintf("Publishing %d marked files...", len(bindings))
+			m.statusIsError = false
+			cmd := m.publishBatch(bindings)
+			return m, cmd
+		}
 		if m.bindingTab == BindingTabBind {
 			if m.resourceSelected < len(m.displayItems) {
 				item := m.displayItems[m.resourceSelected]
-				if !item.Node.IsFolder && item.Resource != nil {
+				if item.Node.IsFolder {
+					return m.publishFolder(item.Node)
+				} else if item.Resource != nil {
 					// Mark as publishing
 					m.publishing[item.Resource.ID] = true
-					return m, m.publishResource(*item.Resource)
+					return m, m.publishResource(*item.Resource, false, false)
 				} else {
 					m.status = "Select a file to publish"
 					m.statusIsError = true
@@ -611,60 +1953,62 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		} else {
 			// In File List tab
-			bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+			bindings := m.fileListBindings()
 			if m.bindingSelected < len(bindings) {
 				binding := bindings[m.bindingSelected]
 				// Find the resource
 				for _, res := range m.resources {
 					if res.ID == binding.WebResourceID {
 						m.publishing[res.ID] = true
-						return m, m.publishResource(res)
+						return m, m.publishResource(res, false, false)
 					}
 				}
 			}
 		}
 
-	case "a":
+	case "toggleAuto":
+		if len(m.marked) > 0 {
+			if bindings := m.markedBindings(); len(bindings) > 0 {
+				m.toggleAutoPublishAll(bindings, "marked files")
+			} else {
+				m.status = "None of the marked files are bound"
+				m.statusIsError = true
+			}
+			return m, nil
+		}
 		if m.bindingTab == BindingTabBind {
 			if m.resourceSelected < len(m.displayItems) {
 				item := m.displayItems[m.resourceSelected]
-				if !item.Node.IsFolder && item.Resource != nil {
+				if item.Node.IsFolder {
+					m.toggleFolderAutoPublish(item.Node)
+				} else if item.Resource != nil {
 					res := item.Resource
 					if b := m.config.GetBinding(m.config.CurrentEnvironment, res.ID); b != nil {
-						b.AutoPublish = !b.AutoPublish
-						m.config.AddBinding(*b)
-						if b.AutoPublish {
-							m.status = "Auto-publish enabled"
-						} else {
-							m.status = "Auto-publish disabled"
-						}
-						m.statusIsError = false
+						m.toggleAutoPublish(*b)
 					} else {
 						m.status = "Bind a file first"
 						m.statusIsError = true
 					}
-				} else {
-					m.status = "Select a file to toggle auto-publish"
-					m.statusIsError = true
 				}
 			}
 		} else {
 			// In File List tab
-			bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+			bindings := m.fileListBindings()
 			if m.bindingSelected < len(bindings) {
-				binding := bindings[m.bindingSelected]
-				binding.AutoPublish = !binding.AutoPublish
-				m.config.AddBinding(binding)
-				if binding.AutoPublish {
-					m.status = "Auto-publish enabled"
-				} else {
-					m.status = "Auto-publish disabled"
-				}
-				m.statusIsError = false
+				m.toggleAutoPublish(bindings[m.bindingSelected])
 			}
 		}
 
-	case "u":
+	case "unbind":
+		if len(m.marked) > 0 {
+			if bindings := m.markedBindings(); len(bindings) > 0 {
+				m.unbindMarked(bindings)
+			} else {
+				m.status = "None of the marked files are bound"
+				m.statusIsError = true
+			}
+			return m, nil
+		}
 		if m.bindingTab == BindingTabBind {
 			if m.resourceSelected < len(m.displayItems) {
 				item := m.displayItems[m.resourceSelected]
@@ -673,8 +2017,7 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					if b := m.config.GetBinding(m.config.CurrentEnvironment, res.ID); b != nil {
 						// Remove from watcher if it was being watched
 						if m.watcher != nil && b.AutoPublish {
-							absPath, _ := filepath.Abs(b.LocalPath)
-							m.watcher.RemoveFile(absPath)
+							m.unwatchBinding(*b)
 						}
 						// Delete the binding
 						if err := m.config.DeleteBinding(m.config.CurrentEnvironment, res.ID); err != nil {
@@ -695,13 +2038,12 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		} else {
 			// In File List tab
-			bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+			bindings := m.fileListBindings()
 			if m.bindingSelected < len(bindings) {
 				binding := bindings[m.bindingSelected]
 				// Remove from watcher if it was being watched
 				if m.watcher != nil && binding.AutoPublish {
-					absPath, _ := filepath.Abs(binding.LocalPath)
-					m.watcher.RemoveFile(absPath)
+					m.unwatchBinding(binding)
 				}
 				// Delete the binding
 				if err := m.config.DeleteBinding(m.config.CurrentEnvironment, binding.WebResourceID); err != nil {
@@ -718,10 +2060,24 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-	case "r":
+	case "refresh":
 		return m, m.fetchResources()
 
-	case "m":
+	case "solutionFilter":
+		if m.solutionFilter != nil {
+			m.status = fmt.Sprintf("Showing the web resources of every solution, not just %s", m.solutionFilter.FriendlyName)
+			m.statusIsError = false
+			m.solutionFilter = nil
+			m.resourceSelected = 0
+			return m, m.fetchResources()
+		}
+		m.pickingFilter = true
+		m.solutionSelected = 0
+		m.loadingSolutions = true
+		m.state = StateSolutionPicker
+		return m, m.fetchSolutions()
+
+	case "managed":
 		m.includeManaged = !m.includeManaged
 		if m.includeManaged {
 			m.status = "Showing managed + unmanaged web resources"
@@ -732,7 +2088,7 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.resourceSelected = 0
 		return m, m.fetchResources()
 
-	case "t":
+	case "refreshToken":
 		if m.bindingTab == BindingTabList {
 			env := m.config.GetEnvironment(m.config.CurrentEnvironment)
 			if env == nil {
@@ -747,50 +2103,357 @@ func (m Model) handleListKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "l":
+	case "login":
 		// Manual re-authentication
 		m.status = "Re-authenticating..."
 		m.statusIsError = false
 		m.state = StateAuth
-		return m, m.authenticateInteractive()
+		m.authErr = nil
+		cmd := m.authenticate()
+		return m, cmd
 
-	case "s":
+	case "addToSolution":
 		// Add to solution - get the selected resource
-		var selectedResource *d365.WebResource
-		if m.bindingTab == BindingTabBind {
-			if m.resourceSelected < len(m.displayItems) {
-				item := m.displayItems[m.resourceSelected]
-				if !item.Node.IsFolder && item.Resource != nil {
-					selectedResource = item.Resource
-				}
-			}
+		selectedResource := m.selectedResource()
+		if selectedResource != nil {
+			m.solutionResource = selectedResource
+			m.solutionSelected = 0
+			m.loadingSolutions = true
+			m.state = StateSolutionPicker
+			return m, m.fetchSolutions()
 		} else {
-			// In File List tab
-			bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
-			if m.bindingSelected < len(bindings) {
-				binding := bindings[m.bindingSelected]
-				// Find the resource
-				for i := range m.resources {
-					if m.resources[i].ID == binding.WebResourceID {
-						selectedResource = &m.resources[i]
-						break
-					}
-				}
-			}
+			m.status = "Select a file first"
+			m.statusIsError = true
+		}
+
+	case "checkSync":
+		// Compare the marked bindings, or all of them, against the deployed content
+		bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+		if len(m.marked) > 0 {
+			bindings = m.markedBindings()
+		}
+		if len(bindings) == 0 {
+			m.status = "No bound files to check"
+			m.statusIsError = true
+			return m, nil
+		}
+		m.syncScanID++
+		m.syncScanning = true
+		m.syncCancelled = false
+		m.syncBindings = bindings
+		m.syncResults = nil
+		m.syncSelected = 0
+		m.state = StateSyncScan
+		return m, m.checkBindingSync(m.syncScanID, bindings[0])
+
+	case "errorDetails":
+		return m.openErrorDetails()
+
+	case "clone":
+		// Clone the selected binding's directory and settings onto another resource
+		if m.bindingTab != BindingTabList {
+			m.status = "Select a binding in the File List tab to clone"
+			m.statusIsError = true
+			return m, nil
+		}
+		bindings := m.fileListBindings()
+		if m.bindingSelected >= len(bindings) {
+			return m, nil
+		}
+		source := bindings[m.bindingSelected]
+		m.cloneFrom = &source
+		m.bindingTab = BindingTabBind
+		m.resourceSelected = 0
+		m.status = fmt.Sprintf("Cloning %s: select a resource and press %s (%s cancels)", source.WebResourceName, m.keyFor(StateList, "bind"), m.keyFor(StateList, "back"))
+		m.statusIsError = false
+		return m, nil
+
+	case "snooze":
+		// Temporarily suspend auto-publish without changing the saved setting
+		res := m.selectedResource()
+		if res == nil {
+			m.status = "Select a bound file to snooze"
+			m.statusIsError = true
+			return m, nil
+		}
+		binding := m.config.GetBinding(m.config.CurrentEnvironment, res.ID)
+		if binding == nil {
+			m.status = "File is not bound"
+			m.statusIsError = true
+			return m, nil
+		}
+		if _, ok := m.snoozeRemaining(res.ID); ok {
+			delete(m.snoozed, res.ID)
+			m.status = fmt.Sprintf("Auto-publish resumed for %s", res.Name)
+			m.statusIsError = false
+			return m, nil
+		}
+		target := *binding
+		m.snoozeTarget = &target
+		m.inputMode = InputSnoozeMinutes
+		m.textInput.Placeholder = "e.g. 30"
+		m.textInput.SetValue("")
+		m.textInput.Focus()
+		return m, nil
+
+	case "republishLast":
+		// Re-publish the most recently published resource without selecting it
+		binding := m.config.LastPublishedBinding(m.config.CurrentEnvironment)
+		if binding == nil {
+			m.status = "Nothing published yet in this environment"
+			m.statusIsError = true
+			return m, nil
+		}
+		if m.publishing[binding.WebResourceID] {
+			return m, nil
+		}
+		m.publishing[binding.WebResourceID] = true
+		return m, m.publishResource(d365.WebResource{ID: binding.WebResourceID, Name: binding.WebResourceName}, true, false)
+
+	case "publishAll":
+		if m.publishingAll {
+			m.status = "Publish all is already running"
+			m.statusIsError = true
+			return m, nil
+		}
+		m.inputMode = InputPublishAllConfirm
+		m.textInput.Placeholder = "Publish all? (y/n)"
+		m.textInput.SetValue("")
+		m.textInput.Focus()
+		return m, nil
+
+	case "promote":
+		return m.startPromotion()
+
+	case "diffPublish":
+		// Preview what a publish would change on the server
+		res := m.selectedResource()
+		if res == nil {
+			m.status = "Select a file first"
+			m.statusIsError = true
+			return m, nil
+		}
+		binding := m.config.GetBinding(m.config.CurrentEnvironment, res.ID)
+		if binding == nil {
+			m.status = "Bind the resource to a file to compare it"
+			m.statusIsError = true
+			return m, nil
+		}
+		m.diffResource = res
+		m.diffLines = nil
+		m.diffReplaced = 0
+		m.diffWarning = ""
+		m.diffErr = nil
+		m.diffScroll = 0
+		m.diffLoading = true
+		m.state = StateDiff
+		return m, m.fetchDiff(*binding)
+
+	case "publishBound":
+		// Publish every bound file in the environment with a single PublishXml call
+		bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+		if len(bindings) == 0 {
+			m.status = "No bound files to publish"
+			m.statusIsError = true
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Publishing %d bound files...", len(bindings))
+		m.statusIsError = false
+		cmd := m.publishBatch(bindings)
+		return m, cmd
+
+	case "label":
+		if m.bindingTab != BindingTabList {
+			return m, nil
+		}
+		bindings := m.fileListBindings()
+		if m.bindingSelected >= len(bindings) {
+			return m, nil
+		}
+		m.inputMode = InputBindingLabel
+		m.textInput.Placeholder = "Label, e.g. ribbon loader for Account"
+		m.textInput.SetValue(bindings[m.bindingSelected].Label)
+		m.textInput.Focus()
+		return m, nil
+
+	case "search":
+		if m.bindingTab == BindingTabList {
+			m.inputMode = InputBindingFilter
+			m.textInput.Placeholder = "Filter by name, path or label"
+			m.textInput.SetValue(m.bindingFilter)
+			m.textInput.Focus()
+			return m, nil
+		}
+		m.inputMode = InputSearch
+		m.textInput.Placeholder = "Search web resources by name"
+		m.textInput.SetValue(m.searchQuery)
+		m.textInput.Focus()
+		return m, nil
+
+	case "treeView":
+		// Switch between the folder tree and a flat list
+		mode := config.ViewModeFlat
+		if m.config.ViewMode == config.ViewModeFlat {
+			mode = config.ViewModeTree
+		}
+		if err := m.config.SetViewMode(mode); err != nil {
+			m.status = fmt.Sprintf("Failed to save view mode: %v", err)
+			m.statusIsError = true
+		} else {
+			m.status = fmt.Sprintf("Switched to %s view", mode)
+			m.statusIsError = false
+		}
+		m.resourceSelected = 0
+		m.buildTree()
+		return m, nil
+
+	case "sort":
+		// Cycle the sort column of the flat list
+		if m.config.ViewMode == config.ViewModeFlat {
+			m.flatSort = (m.flatSort + 1) % 3
+			m.resourceSelected = 0
+			m.buildFlatList()
+			m.status = fmt.Sprintf("Sorted by %s", m.flatSort)
+			m.statusIsError = false
+		}
+		return m, nil
+
+	case "delete":
+		// Delete the selected web resource from the server after a typed confirmation
+		res := m.selectedResource()
+		if res == nil {
+			m.status = "Select a file first"
+			m.statusIsError = true
+			return m, nil
+		}
+		if res.IsManaged {
+			m.status = "Managed web resources cannot be deleted"
+			m.statusIsError = true
+			return m, nil
+		}
+		m.deleteTarget = res
+		m.inputMode = InputDeleteResourceConfirm
+		m.textInput.Placeholder = path.Base(res.Name)
+		m.textInput.SetValue("")
+		return m, nil
+
+	case "details":
+		// Show details for the selected resource
+		res := m.selectedResource()
+		if res == nil {
+			m.status = "Select a file first"
+			m.statusIsError = true
+			return m, nil
+		}
+		m.detailsResource = res
+		m.detailsAudit = nil
+		m.detailsErr = nil
+		m.detailsLoading = true
+		m.state = StateResourceDetails
+		return m, m.fetchResourceAudit(*res)
+
+	case "restore":
+		// Restore the selected binding from a snapshot
+		res := m.selectedResource()
+		if res == nil {
+			m.status = "Select a file first"
+			m.statusIsError = true
+			return m, nil
+		}
+		binding := m.config.GetBinding(m.config.CurrentEnvironment, res.ID)
+		if binding == nil {
+			m.status = "Only bound files have snapshots"
+			m.statusIsError = true
+			return m, nil
+		}
+		snapshots, err := publisher.Snapshots(m.config, binding.Environment, binding.WebResourceName)
+		if err != nil {
+			m.status = fmt.Sprintf("Failed to list snapshots: %v", err)
+			m.statusIsError = true
+			return m, nil
+		}
+		if len(snapshots) == 0 {
+			m.status = fmt.Sprintf("No snapshots of %s; mark the environment protected to take them", binding.WebResourceName)
+			m.statusIsError = true
+			return m, nil
+		}
+		b := *binding
+		m.snapshotBinding = &b
+		m.snapshots = snapshots
+		m.snapshotSelected = 0
+		m.state = StateSnapshotPicker
+		return m, nil
+
+	case "arm":
+		envName := m.config.CurrentEnvironment
+		switch {
+		case !m.requiresConfirm():
+			m.status = fmt.Sprintf("%s doesn't require confirmation, so auto-publish is always armed", envName)
+		case m.armedEnv == envName:
+			m.armedEnv = ""
+			m.status = fmt.Sprintf("Auto-publish disarmed for %s", envName)
+		default:
+			m.armedEnv = envName
+			m.status = fmt.Sprintf("Auto-publish armed for %s until you leave it: saves publish to production", envName)
+		}
+		m.statusIsError = false
+		return m, nil
+
+	case "undo":
+		if m.undoRunning {
+			return m, nil
 		}
+		m.undoRunning = true
+		m.status = "Undoing the last publish..."
+		m.statusIsError = false
+		return m, m.undoPublish()
 
-		if selectedResource != nil {
-			m.solutionResource = selectedResource
-			m.solutionSelected = 0
-			m.loadingSolutions = true
-			m.state = StateSolutionPicker
-			return m, m.fetchSolutions()
-		} else {
-			m.status = "Select a file first"
+	case "history":
+		// Browse the publish log
+		history, err := publisher.History(historyLimit)
+		if err != nil {
+			m.status = fmt.Sprintf("Failed to read the publish log: %v", err)
+			m.statusIsError = true
+			return m, nil
+		}
+		if len(history) == 0 {
+			m.status = "Nothing published yet"
+			m.statusIsError = false
+			return m, nil
+		}
+		m.history = history
+		m.historySelected = 0
+		m.state = StateHistory
+		return m, nil
+
+	case "repoint":
+		// Point a binding at a moved or renamed file
+		res := m.selectedResource()
+		if res == nil {
+			m.status = "Select a bound file first"
+			m.statusIsError = true
+			return m, nil
+		}
+		binding := m.config.GetBinding(m.config.CurrentEnvironment, res.ID)
+		if binding == nil {
+			m.status = "Only bound files can be re-pointed"
 			m.statusIsError = true
+			return m, nil
+		}
+		// Start in the closest folder of the old path that still exists
+		startDir, _ := os.UserHomeDir()
+		for dir := filepath.Dir(binding.LocalPath); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				startDir = dir
+				break
+			}
 		}
+		m.bindingResource = res
+		m.repoint = true
+		m.state = StateFilePicker
+		return m, m.openBindPicker(startDir)
 
-	case "N":
+	case "create":
 		// Create new web resource - first select solution
 		m.solutionSelected = 0
 		m.loadingSolutions = true
@@ -809,6 +2472,7 @@ func (m Model) handleBindingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if msg.String() == "esc" {
 		m.inputMode = InputNone
 		m.state = StateList
+		m.bindingResource = nil
 		return m, nil
 	}
 	return m.handleInputMode(msg)
@@ -818,6 +2482,7 @@ func (m Model) handleFilePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if msg.String() == "esc" {
 		m.state = StateList
 		m.bindingResource = nil
+		m.repoint = false
 		return m, nil
 	}
 
@@ -826,30 +2491,16 @@ func (m Model) handleFilePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	// Check if a file was selected
 	if didSelect, path := m.filepicker.DidSelectFile(msg); didSelect {
-		if m.bindingResource != nil {
-			binding := config.Binding{
-				Environment:      m.config.CurrentEnvironment,
-				LocalPath:        path,
-				WebResourceName:  m.bindingResource.Name,
-				WebResourceID:    m.bindingResource.ID,
-				LastKnownVersion: "1.0.0",
-				AutoPublish:      true,
-			}
-			if err := m.config.AddBinding(binding); err != nil {
-				m.status = fmt.Sprintf("Failed to save binding: %v", err)
-				m.statusIsError = true
-			} else {
-				m.status = fmt.Sprintf("Bound %s to %s", m.bindingResource.Name, filepath.Base(path))
-				m.statusIsError = false
-				// Add to watcher
-				if m.watcher != nil {
-					m.watcher.AddFile(path)
-				}
-			}
+		var bindCmd tea.Cmd
+		if m.bindingResource != nil && m.repoint {
+			m.repointBinding(*m.bindingResource, path)
+		} else if m.bindingResource != nil {
+			bindCmd = m.verifyAndBind(*m.bindingResource, path)
 		}
 		m.state = StateList
 		m.bindingResource = nil
-		return m, nil
+		m.repoint = false
+		return m, bindCmd
 	}
 
 	return m, cmd
@@ -861,8 +2512,14 @@ func (m Model) handleFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if keyMsg.String() == "esc" || keyMsg.String() == "q" || keyMsg.String() == "ctrl+c" {
 			m.state = StateList
 			m.bindingResource = nil
+			m.repoint = false
 			return m, nil
 		}
+		if keyMsg.String() == "f" {
+			// Toggle between matching file types and all files
+			m.pickerShowAll = !m.pickerShowAll
+			return m, m.openBindPicker(m.filepicker.CurrentDirectory)
+		}
 	}
 
 	// Handle window resize
@@ -878,35 +2535,325 @@ func (m Model) handleFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Check if a file was selected
 	if didSelect, path := m.filepicker.DidSelectFile(msg); didSelect {
-		if m.bindingResource != nil {
-			binding := config.Binding{
-				Environment:      m.config.CurrentEnvironment,
-				LocalPath:        path,
-				WebResourceName:  m.bindingResource.Name,
-				WebResourceID:    m.bindingResource.ID,
-				LastKnownVersion: "1.0.0",
-				AutoPublish:      true,
-			}
-			if err := m.config.AddBinding(binding); err != nil {
-				m.status = fmt.Sprintf("Failed to save binding: %v", err)
-				m.statusIsError = true
-			} else {
-				m.status = fmt.Sprintf("Bound %s to %s", m.bindingResource.Name, filepath.Base(path))
-				m.statusIsError = false
-				// Add to watcher
-				if m.watcher != nil {
-					m.watcher.AddFile(path)
-				}
-			}
+		var bindCmd tea.Cmd
+		if m.bindingResource != nil && m.repoint {
+			m.repointBinding(*m.bindingResource, path)
+		} else if m.bindingResource != nil {
+			bindCmd = m.verifyAndBind(*m.bindingResource, path)
 		}
 		m.state = StateList
 		m.bindingResource = nil
-		return m, nil
+		m.repoint = false
+		return m, bindCmd
 	}
 
 	return m, cmd
 }
 
+// watchBinding watches every file that auto-publishes a binding
+func (m *Model) watchBinding(b config.Binding) {
+	for _, file := range b.WatchedFiles() {
+		if absPath, err := filepath.Abs(file); err == nil {
+			m.watcher.AddFile(absPath)
+		}
+	}
+}
+
+// unwatchBinding stops watching the files of a binding
+func (m *Model) unwatchBinding(b config.Binding) {
+	for _, file := range b.WatchedFiles() {
+		if absPath, err := filepath.Abs(file); err == nil {
+			m.watcher.RemoveFile(absPath)
+		}
+	}
+}
+
+// setAutoPublish updates the auto-publish flag for bindings and keeps the watcher in step
+func (m *Model) setAutoPublish(bindings []config.Binding, autoPublish bool) (int, error) {
+	ids := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		ids = append(ids, b.WebResourceID)
+	}
+
+	changed, err := m.config.SetAutoPublish(m.config.CurrentEnvironment, ids, autoPublish)
+	if err != nil {
+		return changed, err
+	}
+
+	if m.watcher != nil {
+		for _, b := range bindings {
+			if autoPublish {
+				m.watchBinding(b)
+			} else {
+				m.unwatchBinding(b)
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// toggleAutoPublish flips auto-publish for a single binding
+func (m *Model) toggleAutoPublish(binding config.Binding) {
+	enable := !binding.AutoPublish
+	if _, err := m.setAutoPublish([]config.Binding{binding}, enable); err != nil {
+		m.status = fmt.Sprintf("Failed to update auto-publish: %v", err)
+		m.statusIsError = true
+		return
+	}
+	if enable {
+		m.status = "Auto-publish enabled"
+	} else {
+		m.status = "Auto-publish disabled"
+	}
+	m.statusIsError = false
+}
+
+// toggleFolderAutoPublish enables auto-publish for every bound resource under a
+// folder, or disables it if all of them already auto-publish
+func (m *Model) toggleFolderAutoPublish(node *TreeNode) {
+	var bindings []config.Binding
+	for _, res := range collectResources(node) {
+		if b := m.config.GetBinding(m.config.CurrentEnvironment, res.ID); b != nil {
+			bindings = append(bindings, *b)
+		}
+	}
+
+	if len(bindings) == 0 {
+		m.status = fmt.Sprintf("No bound files in %s/", node.FullPath)
+		m.statusIsError = true
+		return
+	}
+	m.toggleAutoPublishAll(bindings, fmt.Sprintf("files in %s/", node.FullPath))
+}
+
+// toggleAutoPublishAll enables auto-publish for every binding, or disables it
+// if all of them already auto-publish. what describes the bindings in the status.
+func (m *Model) toggleAutoPublishAll(bindings []config.Binding, what string) {
+	allEnabled := true
+	for _, b := range bindings {
+		allEnabled = allEnabled && b.AutoPublish
+	}
+
+	changed, err := m.setAutoPublish(bindings, !allEnabled)
+	if err != nil {
+		m.status = fmt.Sprintf("Failed to update auto-publish: %v", err)
+		m.statusIsError = true
+		return
+	}
+
+	if allEnabled {
+		m.status = fmt.Sprintf("Auto-publish disabled for %d %s", changed, what)
+	} else {
+		m.status = fmt.Sprintf("Auto-publish enabled for %d %s", changed, what)
+	}
+	m.statusIsError = false
+}
+
+// toggleMark marks the selected resource, or every resource in the selected
+// folder, for the bulk actions, or unmarks them if all are marked already
+func (m *Model) toggleMark() {
+	var ids []string
+	if m.bindingTab == BindingTabBind {
+		if m.resourceSelected >= len(m.displayItems) {
+			return
+		}
+		item := m.displayItems[m.resourceSelected]
+		if item.Node.IsFolder {
+			for _, res := range collectResources(item.Node) {
+				ids = append(ids, res.ID)
+			}
+		} else if item.Resource != nil {
+			ids = append(ids, item.Resource.ID)
+		}
+	} else if bindings := m.fileListBindings(); m.bindingSelected < len(bindings) {
+		ids = append(ids, bindings[m.bindingSelected].WebResourceID)
+	}
+
+	allMarked := true
+	for _, id := range ids {
+		allMarked = allMarked && m.marked[id]
+	}
+	for _, id := range ids {
+		if allMarked {
+			delete(m.marked, id)
+		} else {
+			m.marked[id] = true
+		}
+	}
+
+	if len(m.marked) == 0 {
+		m.status = "Nothing marked"
+	} else {
+		m.status = fmt.Sprintf("%d marked: %s act on all of them (%s: clear)", len(m.marked), m.markedActionKeys(), m.keyFor(StateList, "back"))
+	}
+	m.statusIsError = false
+}
+
+// markedBindings returns the bindings of the marked resources; marked
+// resources that aren't bound are left out
+func (m Model) markedBindings() []config.Binding {
+	var bindings []config.Binding
+	for _, b := range m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment) {
+		if m.marked[b.WebResourceID] {
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings
+}
+
+// unbindMarked removes the bindings of every marked resource and clears the marks
+func (m *Model) unbindMarked(bindings []config.Binding) {
+	for i, b := range bindings {
+		if m.watcher != nil && b.AutoPublish {
+			m.unwatchBinding(b)
+		}
+		if err := m.config.DeleteBinding(m.config.CurrentEnvironment, b.WebResourceID); err != nil {
+			m.status = fmt.Sprintf("Unbound %d of %d marked files; failed to unbind %s: %v", i, len(bindings), b.WebResourceName, err)
+			m.statusIsError = true
+			return
+		}
+	}
+	clear(m.marked)
+	m.status = fmt.Sprintf("Unbound %d marked files", len(bindings))
+	m.statusIsError = false
+	if n := len(m.fileListBindings()); m.bindingSelected >= n {
+		m.bindingSelected = max(n-1, 0)
+	}
+}
+
+// openBindPicker initialises the file picker used to bind a resource, limiting
+// selectable files to the resource's type unless all files were requested
+func (m *Model) openBindPicker(dir string) tea.Cmd {
+	fp := filepicker.New()
+	fp.CurrentDirectory = dir
+	fp.Height = m.height - 6
+	if !m.pickerShowAll && m.bindingResource != nil {
+		fp.AllowedTypes = m.bindingResource.Type.Extensions()
+	}
+	m.filepicker = fp
+	return m.filepicker.Init()
+}
+
+// verifyAndBind checks the path locally and that the resource still exists on
+// the server before the binding is saved, since the loaded list may be stale
+func (m *Model) verifyAndBind(res d365.WebResource, path string) tea.Cmd {
+	absPath, err := config.ValidateBindingPath(path)
+	if err != nil {
+		m.status = fmt.Sprintf("Cannot bind: %v", err)
+		m.statusIsError = true
+		return nil
+	}
+	if m.client == nil {
+		m.bindFile(res, absPath)
+		return nil
+	}
+
+	m.status = fmt.Sprintf("Checking %s on the server...", res.Name)
+	m.statusIsError = false
+	client := m.client
+	return func() tea.Msg {
+		client, cancel := withTimeout(client, quickTimeout)
+		defer cancel()
+		_, err := client.GetWebResource(res.ID)
+		return bindVerifiedMsg{resource: res, path: absPath, err: err}
+	}
+}
+
+// expandHome replaces a leading ~ with the home directory, as a shell would
+func expandHome(path string) string {
+	path = strings.TrimSpace(path)
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// repointBinding moves the binding of a resource to another local file,
+// keeping its settings, e.g. after the file was moved or renamed
+func (m *Model) repointBinding(res d365.WebResource, path string) {
+	existing := m.config.GetBinding(m.config.CurrentEnvironment, res.ID)
+	if existing == nil {
+		m.status = fmt.Sprintf("%s is no longer bound", res.Name)
+		m.statusIsError = true
+		return
+	}
+	absPath, err := config.ValidateBindingPath(path)
+	if err != nil {
+		m.status = fmt.Sprintf("Cannot bind: %v", err)
+		m.statusIsError = true
+		return
+	}
+
+	old := *existing
+	binding := old
+	binding.LocalPath = absPath
+	if len(binding.LocalPaths) > 0 {
+		// The first of several sources is the one shown and re-pointed
+		binding.LocalPaths = append([]string{absPath}, binding.LocalPaths[1:]...)
+	}
+	if err := m.config.AddBinding(binding); err != nil {
+		m.status = fmt.Sprintf("Failed to save binding: %v", err)
+		m.statusIsError = true
+		return
+	}
+	if m.watcher != nil {
+		m.unwatchBinding(old)
+		if binding.AutoPublish {
+			m.watchBinding(binding)
+		}
+	}
+	m.checkBindingPaths()
+	m.status = fmt.Sprintf("%s now bound to %s", res.Name, filepath.Base(absPath))
+	m.statusIsError = false
+}
+
+// bindFile validates a local path and binds it to a web resource
+func (m *Model) bindFile(res d365.WebResource, path string) {
+	absPath, err := config.ValidateBindingPath(path)
+	if err != nil {
+		m.status = fmt.Sprintf("Cannot bind: %v", err)
+		m.statusIsError = true
+		return
+	}
+
+	binding := config.Binding{
+		Environment:      m.config.CurrentEnvironment,
+		LocalPath:        absPath,
+		WebResourceName:  res.Name,
+		WebResourceID:    res.ID,
+		LastKnownVersion: "1.0.0",
+		AutoPublish:      true,
+		ServerVersion:    res.Version,
+	}
+	source := m.cloneFrom
+	if source != nil {
+		binding.AutoPublish = source.AutoPublish
+		binding.Solution = source.Solution
+	}
+	if err := m.config.AddBinding(binding); err != nil {
+		m.status = fmt.Sprintf("Failed to save binding: %v", err)
+		m.statusIsError = true
+		return
+	}
+	m.cloneFrom = nil
+	delete(m.missingFiles, res.ID)
+
+	m.status = fmt.Sprintf("Bound %s to %s", res.Name, filepath.Base(absPath))
+	if source != nil {
+		m.status += fmt.Sprintf(" (settings from %s)", source.WebResourceName)
+	}
+	m.statusIsError = false
+	// Add to watcher
+	if m.watcher != nil && binding.AutoPublish {
+		m.watcher.AddFile(absPath)
+	}
+}
+
 func (m *Model) openTokenExportPicker(env config.Environment, returnState State, writeToken bool) (tea.Model, tea.Cmd) {
 	fp := filepicker.New()
 	startDir := env.TokenOutputDir
@@ -971,7 +2918,7 @@ func (m Model) handleCreateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.state = StateCreatePrefixInput
 			m.textInput.SetValue(m.createPrefix)
-			m.textInput.Placeholder = "e.g., publisher_/AppName/"
+			m.textInput.Placeholder = fmt.Sprintf("e.g., %s_/AppName/", m.publisherPrefix())
 			m.textInput.Focus()
 		}
 		return m, nil
@@ -1019,10 +2966,11 @@ func (m Model) handleCreateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.createFiles = []CreateFileInfo{{
 				LocalPath:    path,
 				ResourceType: resourceType,
+				Warning:      publisher.EncodingWarning(path),
 			}}
 			m.state = StateCreateNameInput
-			m.textInput.SetValue("")
-			m.textInput.Placeholder = "e.g., publisher_/folder/filename.js"
+			m.textInput.SetValue(m.publisherPrefix() + "_/")
+			m.textInput.Placeholder = fmt.Sprintf("e.g., %s_/folder/filename.js", m.publisherPrefix())
 			return m, nil
 		}
 	}
@@ -1031,31 +2979,260 @@ func (m Model) handleCreateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 // Commands
-func (m Model) authenticateInteractive() tea.Cmd {
+// authenticate starts signing in to the current environment with the flow
+// picked on the authentication screen, or the one that suits the session
+func (m *Model) authenticate() tea.Cmd {
+	m.authAttempt++
+	if m.authCancel != nil {
+		m.authCancel()
+	}
+	m.authCtx, m.authCancel = context.WithCancel(context.Background())
+	m.deviceCode = nil
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+	switch m.authFlow {
+	case AuthFlowDeviceCode:
+		m.deviceCodeFlow = env != nil && !env.UsesClientCredentials()
+	case AuthFlowBrowser:
+		m.deviceCodeFlow = false
+	default:
+		m.deviceCodeFlow = env != nil && auth.UseDeviceCode(*env)
+	}
+	if m.deviceCodeFlow {
+		return m.requestDeviceCode(m.authAttempt)
+	}
+	return m.authenticateInteractive(m.authAttempt)
+}
+
+func (m Model) authenticateInteractive(attempt int) tea.Cmd {
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+	return func() tea.Msg {
+		if env == nil {
+			return errMsg(fmt.Errorf("environment not found"))
+		}
+		token, err := auth.AcquireToken(*env)
+		if err != nil {
+			return authFailedMsg{attempt: attempt, err: err, cancelled: auth.IsCancelled(err)}
+		}
+		return tokenMsg{token: token, attempt: attempt, envName: env.Name}
+	}
+}
+
+// requestDeviceCode asks for the code the user enters on another device
+func (m Model) requestDeviceCode(attempt int) tea.Cmd {
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+	return func() tea.Msg {
+		if env == nil {
+			return errMsg(fmt.Errorf("environment not found"))
+		}
+		code, err := auth.RequestDeviceCode(env.URL, auth.RegistrationFor(*env))
+		if err != nil {
+			return authFailedMsg{attempt: attempt, err: err}
+		}
+		return deviceCodeMsg{attempt: attempt, code: code}
+	}
+}
+
+// pollDeviceCode waits for the user to enter a device code and sign in
+func (m Model) pollDeviceCode(attempt int, code *auth.DeviceCodeResponse) tea.Cmd {
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+	ctx := m.authCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	return func() tea.Msg {
-		env := m.config.GetEnvironment(m.config.CurrentEnvironment)
 		if env == nil {
 			return errMsg(fmt.Errorf("environment not found"))
 		}
-		token, err := auth.AcquireTokenInteractive(env.URL)
+		token, err := auth.PollForToken(ctx, code.DeviceCode, env.URL, code.Interval, auth.RegistrationFor(*env))
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			return authFailedMsg{attempt: attempt, err: err}
+		}
+		return tokenMsg{token: token, attempt: attempt, envName: env.Name}
+	}
+}
+
+func (m Model) fetchResources() tea.Cmd {
+	envName := m.config.CurrentEnvironment
+	return m.withFreshToken(func() tea.Msg {
+		if m.client == nil {
+			return errMsg(fmt.Errorf("not connected"))
+		}
+		client, cancel := m.sessionTimeout(m.client, m.config.RequestTimeout())
+		defer cancel()
+		var resources []d365.WebResource
+		var err error
+		if m.solutionFilter != nil {
+			resources, err = client.ListSolutionWebResources(m.solutionFilter.ID, m.includeManaged)
+		} else {
+			resources, err = client.ListWebResources(m.includeManaged)
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			return errMsg(err)
+		}
+		msg := resourcesMsg{resources: resources}
+		if m.solutionFilter == nil && !config.ReadOnly() {
+			msg.cacheErr = d365.SaveResourceCache(config.ResourceCachePath(envName), d365.ResourceCache{
+				Fetched:        time.Now(),
+				IncludeManaged: m.includeManaged,
+				Resources:      resources,
+			})
+		}
+		return msg
+	})
+}
+
+// loadCachedResources reads the resource list saved by the last fetch in the
+// current environment, to show until the fresh one arrives
+func (m Model) loadCachedResources() tea.Cmd {
+	if m.solutionFilter != nil {
+		return nil
+	}
+	envName := m.config.CurrentEnvironment
+	return func() tea.Msg {
+		cache, err := d365.LoadResourceCache(config.ResourceCachePath(envName))
+		if err != nil {
+			return nil
+		}
+		return cachedResourcesMsg{envName: envName, cache: cache}
+	}
+}
+
+// checkConnection asks the current environment who the token signs in as,
+// so a wrong URL or tenant shows up before the resource list is fetched
+func (m Model) checkConnection() tea.Cmd {
+	envName := m.config.CurrentEnvironment
+	return m.withFreshToken(func() tea.Msg {
+		if m.client == nil {
+			return connectedMsg{envName: envName, err: fmt.Errorf("not connected")}
+		}
+		client, cancel := m.sessionTimeout(m.client, quickTimeout)
+		defer cancel()
+		user, err := client.WhoAmI()
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return connectedMsg{envName: envName, user: user, err: err}
+	})
+}
+
+// connectionProblem explains why an environment could not be connected to
+func (m Model) connectionProblem(env *config.Environment, err error) string {
+	if env == nil {
+		return fmt.Sprintf("Can't connect: %v", err)
+	}
+	var apiErr *d365.APIError
+	switch {
+	case d365.IsNetworkError(err) || errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("Can't reach %s: check the environment URL and your network or proxy (%s: retry)", env.URL, m.keyFor(StateList, "refresh"))
+	case errors.Is(err, d365.ErrUnauthorized):
+		return fmt.Sprintf("%s rejected the token: the account may belong to another tenant; sign out with %s on the environment screen and sign in again", env.URL, m.keyFor(StateEnvironmentSelect, "signOut"))
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden:
+		return fmt.Sprintf("Signed in, but the account has no access to %s: ask for a user in that environment", env.URL)
+	case errors.Is(err, d365.ErrThrottled):
+		return fmt.Sprintf("%s is throttling requests: wait a minute before retrying (%s: retry)", env.URL, m.keyFor(StateList, "refresh"))
+	case errors.Is(err, d365.ErrNotFound):
+		return fmt.Sprintf("%s has no Dataverse Web API: check the environment URL", env.URL)
+	}
+	return fmt.Sprintf("Can't connect to %s: %v", env.Name, err)
+}
+
+// withFreshToken wraps a command that calls the API so that an expired token
+// is refreshed first. The client is updated before the command runs; the model
+// picks up and persists the new token through tokenRefreshedMsg. If the
+// refresh fails the command is skipped and the user is sent to sign in again.
+func (m Model) withFreshToken(cmd tea.Cmd) tea.Cmd {
+	refresh := m.refreshExpiredToken()
+	if refresh == nil {
+		return cmd
+	}
+	return func() tea.Msg {
+		fresh, ok := refresh()
+		if !ok {
+			return reAuthRequiredMsg{}
+		}
+		return tea.Batch(func() tea.Msg { return tokenRefreshedMsg(fresh) }, cmd)()
+	}
+}
+
+// refreshExpiredToken returns a function that refreshes the expired token and
+// updates the client with it, reporting whether that worked, or nil when the
+// token has not expired. It is for commands that have to clean up themselves
+// when the refresh fails, which withFreshToken would skip.
+func (m Model) refreshExpiredToken() func() (*auth.Token, bool) {
+	token := m.token
+	client := m.client
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+	if token == nil || client == nil || env == nil || !token.IsExpired() {
+		return nil
+	}
+
+	target := *env
+	return func() (*auth.Token, bool) {
+		fresh, err := auth.RefreshToken(target, token.RefreshToken)
 		if err != nil {
-			return errMsg(err)
+			return nil, false
 		}
-		return tokenMsg(token)
+		client.UpdateToken(fresh.AccessToken)
+		return fresh, true
 	}
 }
 
-func (m Model) fetchResources() tea.Cmd {
+// searchResources queries the API for resources matching the name, falling
+// back to filtering the loaded list if the API search fails. Within a
+// solution the loaded list is always filtered, as it holds all its resources.
+func (m Model) searchResources(query string) tea.Cmd {
+	client := m.client
+	if m.solutionFilter != nil {
+		client = nil
+	}
+	includeManaged := m.includeManaged
+	loaded := m.allResources
+
 	return func() tea.Msg {
-		if m.client == nil {
-			return errMsg(fmt.Errorf("not connected"))
+		if client != nil {
+			client, cancel := m.sessionTimeout(client, m.config.RequestTimeout())
+			defer cancel()
+			resources, err := client.SearchWebResources(query, includeManaged)
+			if err == nil {
+				return searchResultsMsg{query: query, resources: resources}
+			}
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			if loaded == nil {
+				return searchResultsMsg{query: query, err: err}
+			}
 		}
-		resources, err := m.client.ListWebResources(m.includeManaged)
-		if err != nil {
-			return errMsg(err)
+
+		var matches []d365.WebResource
+		lowerQuery := strings.ToLower(query)
+		for _, res := range loaded {
+			if strings.Contains(strings.ToLower(res.Name), lowerQuery) {
+				matches = append(matches, res)
+			}
 		}
-		return resourcesMsg(resources)
+		return searchResultsMsg{query: query, resources: matches, local: true}
+	}
+}
+
+// clearSearch restores the full resource list, fetching it if it was never loaded
+func (m *Model) clearSearch() tea.Cmd {
+	m.searchQuery = ""
+	m.resourceSelected = 0
+	if m.allResources == nil {
+		return m.fetchResources()
 	}
+	m.resources = m.allResources
+	m.buildTree()
+	m.status = "Search cleared"
+	m.statusIsError = false
+	return nil
 }
 
 func (m Model) saveTokenExportDirectory(dir string, writeToken bool) tea.Cmd {
@@ -1128,20 +3305,10 @@ func (m Model) refreshAndExportToken(dir string) tea.Cmd {
 			return tokenExportedMsg{token: currentToken, dir: dir}
 		}
 
-		if storedToken, err := auth.LoadToken(env.Name); err == nil && !storedToken.IsExpired() {
-			if err := auth.ExportAccessToken(dir, storedToken); err != nil {
-				return errMsg(fmt.Errorf("write token.json: %w", err))
-			}
-			return tokenExportedMsg{token: storedToken, dir: dir}
-		}
-
-		token, err := auth.RefreshAccessToken("", env.URL)
+		token, err := environmentToken(*env)
 		if err != nil {
 			return tokenExportAuthRequiredMsg{}
 		}
-		if err := auth.SaveToken(env.Name, token); err != nil {
-			return errMsg(fmt.Errorf("save token: %w", err))
-		}
 		if err := auth.ExportAccessToken(dir, token); err != nil {
 			return errMsg(fmt.Errorf("write token.json: %w", err))
 		}
@@ -1150,9 +3317,44 @@ func (m Model) refreshAndExportToken(dir string) tea.Cmd {
 	}
 }
 
+// exportConfig writes the shareable part of the config to a file
+func exportConfig(cfg *config.Config, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ExportConfig(f, exportDir(path)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readConfigExport reads a config export, resolving its paths against the
+// folder it is in
+func readConfigExport(path string) (*config.ConfigExport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return config.ReadConfigExport(f, exportDir(path))
+}
+
+// exportDir returns the absolute folder of a config export, which its local
+// paths are relative to
+func exportDir(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Dir(path)
+}
+
 func (m Model) setupWatchers() tea.Cmd {
 	cfg := m.config
 	fileChangeChan := m.fileChangeChan
+	watchErrChan := m.watchErrChan
+	since := time.Now()
 
 	return func() tea.Msg {
 		w, err := watcher.New(func(path string) {
@@ -1166,21 +3368,60 @@ func (m Model) setupWatchers() tea.Cmd {
 			}
 		})
 		if err != nil {
-			return errMsg(err)
+			return watcherReadyMsg{err: err}
+		}
+		w.SetErrorHandler(func(err error) {
+			select {
+			case watchErrChan <- err:
+			default:
+				// An error is already waiting to be shown
+			}
+		})
+		w.SetWriteOnly(cfg.WatchWriteOnly())
+		if env := cfg.GetEnvironment(cfg.CurrentEnvironment); env != nil {
+			w.SetMode(watchMode(env.WatchMode))
+		}
+
+		for _, dir := range cfg.GetDirectoryBindingsForEnvironment(cfg.CurrentEnvironment) {
+			if err := w.AddDir(dir.Root(), dir.Matches, dir.SkipsDir); err != nil {
+				w.Close()
+				return watcherReadyMsg{err: fmt.Errorf("watch %s: %w", dir.LocalDir, err)}
+			}
 		}
 
+		var missed []string
+		seen := make(map[string]bool)
 		bindings := cfg.GetBindingsForEnvironment(cfg.CurrentEnvironment)
 		for _, b := range bindings {
-			if b.AutoPublish {
-				absPath, err := filepath.Abs(b.LocalPath)
-				if err == nil {
-					w.AddFile(absPath)
+			if !b.AutoPublish {
+				continue
+			}
+			for _, file := range b.WatchedFiles() {
+				absPath, err := filepath.Abs(file)
+				if err != nil || seen[absPath] {
+					continue
+				}
+				seen[absPath] = true
+				w.AddFile(absPath)
+				if info, err := os.Stat(absPath); err == nil && info.ModTime().After(since) {
+					missed = append(missed, absPath)
 				}
 			}
 		}
 
-		return watcherReadyMsg(w)
+		return watcherReadyMsg{watcher: w, missed: missed}
+	}
+}
+
+// watchMode maps an environment's watchMode setting to the watcher's mode
+func watchMode(mode string) watcher.Mode {
+	switch mode {
+	case config.WatchModeEvents:
+		return watcher.ModeEvents
+	case config.WatchModePoll:
+		return watcher.ModePoll
 	}
+	return watcher.ModeAuto
 }
 
 // waitForFileChange is a subscription that waits for file changes
@@ -1191,37 +3432,516 @@ func waitForFileChange(fileChangeChan chan string) tea.Cmd {
 	}
 }
 
-func (m Model) publishResource(res d365.WebResource) tea.Cmd {
-	cfg := m.config
+// waitForWatchError is a subscription that waits for watcher errors
+func waitForWatchError(watchErrChan chan error) tea.Cmd {
+	return func() tea.Msg {
+		return watchErrorMsg(<-watchErrChan)
+	}
+}
+
+// Request deadlines by operation, so cheap calls fail fast on a dead
+// connection while uploads and publishes get time to finish. Lists and
+// publishes use the request and publish timeouts from the config.
+const (
+	quickTimeout      = 10 * time.Second // pings and single-record lookups
+	publishAllTimeout = 10 * time.Minute // PublishAllXml
+)
+
+// clientOptions returns the client timeouts configured in cfg
+func clientOptions(cfg *config.Config) d365.ClientOptions {
+	return d365.ClientOptions{
+		ConnectTimeout: cfg.ConnectTimeout(),
+		RequestTimeout: cfg.RequestTimeout(),
+		PublishTimeout: cfg.PublishTimeout(),
+	}
+}
+
+// withTimeout scopes a client's requests to a deadline
+func withTimeout(client *d365.Client, timeout time.Duration) (*d365.Client, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return client.WithContext(ctx), cancel
+}
+
+// fetchResourceSolutions looks up the solutions of the bound resources in the
+// background, several at a time, so the list stays usable meanwhile
+func (m Model) fetchResourceSolutions() tea.Cmd {
+	bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+	if m.client == nil || len(bindings) == 0 {
+		return nil
+	}
+	ids := make([]string, len(bindings))
+	for i, b := range bindings {
+		ids[i] = b.WebResourceID
+	}
+	envName := m.config.CurrentEnvironment
 	client := m.client
+	if m.session != nil {
+		// Each lookup is limited by the request timeout; the session ends
+		// them all when another environment is opened
+		client = client.WithContext(m.session)
+	}
 
 	return func() tea.Msg {
-		binding := cfg.GetBinding(cfg.CurrentEnvironment, res.ID)
-		if binding == nil {
+		solutions, err := client.GetWebResourceSolutions(ids)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return resourceSolutionsMsg{envName: envName, solutions: solutions, err: err}
+	}
+}
+
+// startSession begins the context of a newly opened environment, ending any
+// previous one
+func (m *Model) startSession() {
+	m.stopSession()
+	m.session, m.endSession = context.WithCancel(context.Background())
+	m.connectedAs = ""
+}
+
+// stopSession cancels the list and search requests of the open environment
+func (m *Model) stopSession() {
+	if m.endSession != nil {
+		m.endSession()
+		m.session, m.endSession = nil, nil
+	}
+}
+
+// sessionTimeout scopes a client's requests to a deadline and to the open
+// environment, so leaving the resource list aborts them
+func (m Model) sessionTimeout(client *d365.Client, timeout time.Duration) (*d365.Client, context.CancelFunc) {
+	parent := m.session
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	return client.WithContext(ctx), cancel
+}
+
+// checkEnvironments reports the health of every environment from its cached
+// token, optionally pinging the environments whose token is still valid
+func (m Model) checkEnvironments(ping bool) tea.Cmd {
+	envs := append([]config.Environment(nil), m.config.Environments...)
+
+	return func() tea.Msg {
+		health := make(map[string]EnvHealth, len(envs))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, env := range envs {
+			token, err := auth.LoadToken(env.Name)
+			switch {
+			case err != nil || token == nil:
+				health[env.Name] = HealthNoToken
+				continue
+			case token.IsExpired():
+				health[env.Name] = HealthExpired
+				continue
+			case !ping:
+				health[env.Name] = HealthReady
+				continue
+			}
+
+			wg.Add(1)
+			go func(env config.Environment, accessToken string) {
+				defer wg.Done()
+				client, cancel := withTimeout(d365.NewClient(env.URL, accessToken, clientOptions(m.config)), quickTimeout)
+				defer cancel()
+
+				result := HealthReady
+				if err := client.Ping(); errors.Is(err, d365.ErrUnauthorized) {
+					result = HealthExpired
+				} else if err != nil {
+					result = HealthUnreachable
+				}
+
+				mu.Lock()
+				health[env.Name] = result
+				mu.Unlock()
+			}(env, token.AccessToken)
+		}
+		wg.Wait()
+		return envHealthMsg(health)
+	}
+}
+
+// reauthenticateAll silently refreshes every missing or expired token and
+// reports which environments still need an interactive login
+func (m Model) reauthenticateAll() tea.Cmd {
+	envs := append([]config.Environment(nil), m.config.Environments...)
+
+	return func() tea.Msg {
+		var result reauthAllMsg
+		for _, env := range envs {
+			token, err := auth.LoadToken(env.Name)
+			if err == nil && token != nil && !token.IsExpired() {
+				continue
+			}
+			refreshToken := ""
+			if token != nil {
+				refreshToken = token.RefreshToken
+			}
+			fresh, err := auth.RefreshToken(env, refreshToken)
+			if err != nil {
+				result.needLogin = append(result.needLogin, env.Name)
+				continue
+			}
+			auth.SaveToken(env.Name, fresh)
+			m.exportTokenForEnvironment(&env, fresh)
+			result.refreshed = append(result.refreshed, env.Name)
+		}
+		return result
+	}
+}
+
+// signIn runs an interactive login for an environment without opening it
+func (m Model) signIn(env config.Environment) tea.Cmd {
+	return func() tea.Msg {
+		token, err := auth.AcquireToken(env)
+		if err != nil {
+			return reauthLoginMsg{envName: env.Name, err: err}
+		}
+		if err := auth.SaveToken(env.Name, token); err != nil {
+			return reauthLoginMsg{envName: env.Name, err: err}
+		}
+		m.exportTokenForEnvironment(&env, token)
+		return reauthLoginMsg{envName: env.Name}
+	}
+}
+
+// refreshTokenBeforeExpiry starts a silent refresh once the token enters the
+// expiry buffer used by Token.IsExpired, so it doesn't die mid-publish
+func (m *Model) refreshTokenBeforeExpiry() tea.Cmd {
+	if m.token == nil || m.client == nil || m.tokenRefreshing || m.tokenRefreshFail || !m.token.IsExpired() {
+		return nil
+	}
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+	if env == nil {
+		return nil
+	}
+
+	m.tokenRefreshing = true
+	target := *env
+	refreshToken := m.token.RefreshToken
+	return func() tea.Msg {
+		token, err := auth.RefreshToken(target, refreshToken)
+		if err != nil {
+			return tokenRefreshFailedMsg{err: err}
+		}
+		return tokenRefreshedMsg(token)
+	}
+}
+
+// idleAfter is how long without a key press before connectivity checks pause
+const idleAfter = 5 * time.Minute
+
+// scheduleConnectivityCheck waits for the configured interval before the next check
+func (m Model) scheduleConnectivityCheck() tea.Cmd {
+	interval := m.config.ConnectivityCheckInterval()
+	if interval <= 0 {
+		return nil
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return connectivityTickMsg{}
+	})
+}
+
+// checkConnectivity pings the org unless there is no session or the user is idle
+func (m Model) checkConnectivity() tea.Cmd {
+	client := m.client
+	if client == nil || m.state == StateEnvironmentSelect || m.state == StateAuth ||
+		time.Since(m.lastActivity) > idleAfter {
+		return m.scheduleConnectivityCheck()
+	}
+	return func() tea.Msg {
+		client, cancel := withTimeout(client, quickTimeout)
+		defer cancel()
+		return connectivityMsg{err: client.Ping()}
+	}
+}
+
+// handleConnectivity updates the online indicator from a connectivity check
+func (m Model) handleConnectivity(msg connectivityMsg) (tea.Model, tea.Cmd) {
+	wasOffline := m.offline
+	// Only transport failures mean offline; an API error still proves the org answered
+	m.offline = msg.err != nil && d365.IsNetworkError(msg.err)
+	if wasOffline && !m.offline {
+		m.status = "Back online"
+		m.statusIsError = false
+	}
+	return m, m.scheduleConnectivityCheck()
+}
+
+// reportOffline switches to the offline indicator for network failures, so a
+// dropped connection shows one clear state instead of an error per request
+func (m *Model) reportOffline(err error) bool {
+	if err == nil || !d365.IsNetworkError(err) {
+		return false
+	}
+	m.offline = true
+	m.err = err
+	m.status = "Offline: waiting for the connection to return"
+	m.statusIsError = true
+	return true
+}
+
+// publishAllCustomizations runs PublishAllXml for the current environment
+func (m Model) publishAllCustomizations() tea.Cmd {
+	return func() tea.Msg {
+		if m.client == nil {
+			return publishAllMsg{err: fmt.Errorf("not connected")}
+		}
+		client, cancel := withTimeout(m.client, publishAllTimeout)
+		defer cancel()
+		return publishAllMsg{err: client.PublishAllCustomizations()}
+	}
+}
+
+// publishResource publishes the bound file of a resource. Unless republish is
+// set, content matching the last publish is skipped. Unless overwrite is set,
+// a resource changed on the server is not overwritten; only the overwrite
+// prompt sets it.
+func (m Model) publishResource(res d365.WebResource, republish, overwrite bool) tea.Cmd {
+	cfg := m.config
+	client := m.client
+
+	return m.withFreshToken(func() tea.Msg {
+		bound := cfg.GetBinding(cfg.CurrentEnvironment, res.ID)
+		if bound == nil {
 			return errMsg(fmt.Errorf("no binding for this resource"))
 		}
+		binding := *bound
+		publish := publisher.Publish
+		switch {
+		case overwrite:
+			publish = publisher.Overwrite
+		case republish:
+			publish = publisher.Republish
+		}
 
-		content, err := os.ReadFile(binding.LocalPath)
+		client, cancel := withTimeout(client, m.config.PublishTimeout())
+		defer cancel()
+		result, err := publish(client, cfg, binding)
 		if err != nil {
 			return publishResultMsg{success: false, err: err, path: binding.LocalPath, resourceID: res.ID}
 		}
 
-		encoded := base64.StdEncoding.EncodeToString(content)
+		return publishResultMsg{success: true, path: binding.LocalPath, resourceID: res.ID, replaced: result.Replaced, warning: result.Warning, unchanged: result.Unchanged}
+	})
+}
+
+// startPromotion captures the files changed in this session, or the selected
+// file if none were, and asks to publish them to the next environment in the
+// promotion chain
+func (m Model) startPromotion() (tea.Model, tea.Cmd) {
+	if m.promotion != nil && m.promotion.running {
+		m.status = "A promotion is already running"
+		m.statusIsError = true
+		return m, nil
+	}
+	target := m.config.NextEnvironment(m.config.CurrentEnvironment)
+	if target == "" {
+		m.status = fmt.Sprintf("Nothing to promote %s to; add it to promotionChain in config.json", m.config.CurrentEnvironment)
+		m.statusIsError = true
+		return m, nil
+	}
 
-		if err := client.UpdateWebResourceContent(res.ID, encoded); err != nil {
-			return publishResultMsg{success: false, err: err, path: binding.LocalPath, resourceID: res.ID}
+	var bindings []config.Binding
+	for _, b := range m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment) {
+		if m.changed[b.WebResourceID] {
+			bindings = append(bindings, b)
 		}
+	}
+	if len(bindings) == 0 {
+		if res := m.selectedResource(); res != nil {
+			if b := m.config.GetBinding(m.config.CurrentEnvironment, res.ID); b != nil {
+				bindings = append(bindings, *b)
+			}
+		}
+	}
+	if len(bindings) == 0 {
+		m.status = "Nothing to promote: publish a file first or select a bound file"
+		m.statusIsError = true
+		return m, nil
+	}
 
-		if err := client.PublishWebResource(res.ID); err != nil {
-			return publishResultMsg{success: false, err: err, path: binding.LocalPath, resourceID: res.ID}
+	files := make([]publisher.PromotedFile, 0, len(bindings))
+	for _, b := range bindings {
+		file, err := publisher.CaptureFile(b)
+		if err != nil {
+			m.status = fmt.Sprintf("Cannot promote: %v", err)
+			m.statusIsError = true
+			return m, nil
+		}
+		files = append(files, file)
+	}
+
+	m.promotion = &promotion{files: files, target: target}
+	m.inputMode = InputPromoteConfirm
+	m.textInput.Placeholder = "Promote? (y/n)"
+	m.textInput.SetValue("")
+	m.textInput.Focus()
+	return m, nil
+}
+
+// promote publishes captured files to the resources of the same name in another environment
+func (m Model) promote(envName string, files []publisher.PromotedFile) tea.Cmd {
+	cfg := m.config
+	env := m.config.GetEnvironment(envName)
+
+	return func() tea.Msg {
+		if env == nil {
+			return promoteMsg{envName: envName, errs: []error{fmt.Errorf("environment %s not found", envName)}}
+		}
+		token, err := environmentToken(*env)
+		if err != nil {
+			return promoteMsg{envName: envName, errs: []error{err}}
+		}
+
+		client := d365.NewClient(env.URL, token.AccessToken, clientOptions(cfg))
+		client.SetMaxRetries(cfg.RetryLimit())
+		result := promoteMsg{envName: envName}
+		for _, file := range files {
+			client, cancel := withTimeout(client, m.config.PublishTimeout())
+			err := publisher.Promote(client, cfg, env, file)
+			cancel()
+			if err != nil {
+				result.errs = append(result.errs, fmt.Errorf("%s: %w", file.Name, err))
+				continue
+			}
+			result.published++
+		}
+		return result
+	}
+}
+
+// environmentToken returns a valid token for an environment other than the
+// current one, refreshing it silently if it has expired
+func environmentToken(env config.Environment) (*auth.Token, error) {
+	token, err := auth.LoadToken(env.Name)
+	if err == nil && token != nil && !token.IsExpired() {
+		return token, nil
+	}
+	refreshToken := ""
+	if token != nil {
+		refreshToken = token.RefreshToken
+	}
+	fresh, err := auth.RefreshToken(env, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("no valid token for %s; sign in from the environment screen", env.Name)
+	}
+	auth.SaveToken(env.Name, fresh)
+	return fresh, nil
+}
+
+// handlePromoteResult records one environment's results and offers the next
+// environment in the chain when every file was published
+func (m Model) handlePromoteResult(msg promoteMsg) (tea.Model, tea.Cmd) {
+	p := m.promotion
+	if p == nil {
+		return m, nil
+	}
+	p.running = false
+
+	line := fmt.Sprintf("%s: %d/%d published", msg.envName, msg.published, len(p.files))
+	if len(msg.errs) > 0 {
+		line += fmt.Sprintf(", %d failed", len(msg.errs))
+		m.err = errors.Join(msg.errs...)
+	}
+	p.report = append(p.report, line)
+
+	next := m.config.NextEnvironment(msg.envName)
+	if len(msg.errs) > 0 || next == "" {
+		m.promotion = nil
+		m.status = strings.Join(p.report, "; ")
+		if len(msg.errs) > 0 {
+			m.status += fmt.Sprintf(" (press %s for details)", m.keyFor(StateList, "errorDetails"))
+		}
+		m.statusIsError = len(msg.errs) > 0
+		return m, nil
+	}
+
+	p.target = next
+	m.status = strings.Join(p.report, "; ")
+	m.statusIsError = false
+	m.inputMode = InputPromoteConfirm
+	m.textInput.Placeholder = "Promote? (y/n)"
+	m.textInput.SetValue("")
+	m.textInput.Focus()
+	return m, nil
+}
+
+// publishBatch marks the bindings as publishing and publishes them in one
+// batch, reporting upload progress in the status bar
+func (m *Model) publishBatch(bindings []config.Binding) tea.Cmd {
+	cfg := m.config
+	client := m.client
+	ids := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		ids = append(ids, b.WebResourceID)
+		m.publishing[b.WebResourceID] = true
+	}
+	m.batchID++
+	batchID := m.batchID
+	m.batchProgressID = batchID
+	progress := make(chan batchProgressMsg, len(bindings)+1)
+
+	// The token is refreshed in here rather than through withFreshToken, so
+	// that a failed refresh still closes progress and clears publishing
+	refresh := m.refreshExpiredToken()
+	publish := func() tea.Msg {
+		defer close(progress)
+		if client == nil {
+			return batchPublishMsg{batchID: batchID, ids: ids, err: fmt.Errorf("not connected")}
+		}
+		var refreshed tea.Cmd
+		if refresh != nil {
+			fresh, ok := refresh()
+			if !ok {
+				return batchPublishMsg{batchID: batchID, ids: ids, reAuth: true}
+			}
+			refreshed = func() tea.Msg { return tokenRefreshedMsg(fresh) }
 		}
+		client, cancel := withTimeout(client, publishAllTimeout)
+		defer cancel()
+		result, err := publisher.PublishBatch(client, cfg, bindings, func(done, total int) {
+			progress <- batchProgressMsg{batchID: batchID, done: done, total: total, progress: progress}
+		})
+		msg := batchPublishMsg{batchID: batchID, ids: ids, result: result, err: err}
+		if refreshed != nil {
+			return tea.Batch(refreshed, func() tea.Msg { return msg })()
+		}
+		return msg
+	}
+	return tea.Batch(publish, waitForBatchProgress(progress))
+}
 
-		// Increment version
-		newVersion := incrementVersion(binding.LastKnownVersion)
-		cfg.UpdateBindingVersion(cfg.CurrentEnvironment, res.ID, newVersion)
+// waitForBatchProgress delivers the next progress update of a batch publish
+func waitForBatchProgress(progress chan batchProgressMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-progress
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
 
-		return publishResultMsg{success: true, path: binding.LocalPath, resourceID: res.ID}
+// publishFolder publishes every bound file below a folder in one batch
+func (m Model) publishFolder(node *TreeNode) (tea.Model, tea.Cmd) {
+	var bindings []config.Binding
+	for _, res := range collectResources(node) {
+		if b := m.config.GetBinding(m.config.CurrentEnvironment, res.ID); b != nil {
+			bindings = append(bindings, *b)
+		}
+	}
+	if len(bindings) == 0 {
+		m.status = fmt.Sprintf("No bound files in %s/", node.FullPath)
+		m.statusIsError = true
+		return m, nil
 	}
+	m.status = fmt.Sprintf("Publishing %d bound files in %s/...", len(bindings), node.FullPath)
+	m.statusIsError = false
+	cmd := m.publishBatch(bindings)
+	return m, cmd
 }
 
 func (m Model) handleFileChange(path string) tea.Cmd {
@@ -1229,71 +3949,116 @@ func (m Model) handleFileChange(path string) tea.Cmd {
 	client := m.client
 	resources := m.resources
 
-	return func() tea.Msg {
+	return m.withFreshToken(func() tea.Msg {
 		bindings := cfg.GetBindingsForEnvironment(cfg.CurrentEnvironment)
 		for _, b := range bindings {
-			absPath, _ := filepath.Abs(b.LocalPath)
-			if absPath == path && b.AutoPublish {
+			if b.Watches(path) && b.AutoPublish {
 				// Find the resource and publish
 				for _, res := range resources {
 					if res.ID == b.WebResourceID {
-						// Retry reading file to handle atomic saves
-						// Editors like Neovim delete the original and rename a temp file
-						var content []byte
-						var err error
-						for attempt := 0; attempt < 5; attempt++ {
-							if attempt > 0 {
-								time.Sleep(50 * time.Millisecond)
-							}
-							content, err = os.ReadFile(b.LocalPath)
-							if err == nil {
-								break
-							}
-						}
-						if err != nil {
-							return publishResultMsg{
-								success:    false,
-								err:        fmt.Errorf("reading %s: %w", b.LocalPath, err),
-								path:       b.LocalPath,
-								resourceID: res.ID,
-							}
-						}
-
-						encoded := base64.StdEncoding.EncodeToString(content)
+						return autoPublish(client, cfg, b)
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
 
-						if err := client.UpdateWebResourceContent(res.ID, encoded); err != nil {
-							return publishResultMsg{success: false, err: err, path: b.LocalPath, resourceID: res.ID}
-						}
+// retryBinding publishes the binding of a failed auto-publish again, and no
+// other binding of the same file
+func (m Model) retryBinding(b config.Binding) tea.Cmd {
+	cfg := m.config
+	client := m.client
+	return m.withFreshToken(func() tea.Msg {
+		return autoPublish(client, cfg, b)
+	})
+}
 
-						if err := client.PublishWebResource(res.ID); err != nil {
-							return publishResultMsg{success: false, err: err, path: b.LocalPath, resourceID: res.ID}
-						}
+// autoPublish publishes a binding because its file was saved
+func autoPublish(client *d365.Client, cfg *config.Config, b config.Binding) tea.Msg {
+	client, cancel := withTimeout(client, cfg.PublishTimeout())
+	defer cancel()
+	result, err := publisher.Publish(client, cfg, b)
+	if err != nil {
+		return publishResultMsg{success: false, err: err, path: b.LocalPath, resourceID: b.WebResourceID, auto: true}
+	}
+	return publishResultMsg{success: true, path: b.LocalPath, resourceID: b.WebResourceID, replaced: result.Replaced, warning: result.Warning, unchanged: result.Unchanged, auto: true}
+}
 
-						newVersion := incrementVersion(b.LastKnownVersion)
-						cfg.UpdateBindingVersion(cfg.CurrentEnvironment, res.ID, newVersion)
+// autoPublishRetries and autoPublishBackoff bound the retries of a failed
+// auto-publish: after 2, 4 and 8 seconds
+const (
+	autoPublishRetries = 3
+	autoPublishBackoff = 2 * time.Second
+)
 
-						return publishResultMsg{success: true, path: b.LocalPath, resourceID: res.ID}
-					}
-				}
-			}
-		}
+// retryAutoPublish schedules another attempt of an auto-publish that failed
+// for a reason that may pass, such as a dropped connection, throttling or a
+// server error. Once the retries are used up, or the failure won't pass by
+// itself, the resource is marked failed until a publish succeeds. It returns
+// nil when no retry was scheduled.
+func (m *Model) retryAutoPublish(msg publishResultMsg) tea.Cmd {
+	var changed *publisher.ServerChangedError
+	if errors.As(msg.err, &changed) {
+		// The overwrite prompt asks what to do instead
+		return nil
+	}
+	attempts := m.retrying[msg.resourceID]
+	if !transientError(msg.err) || attempts >= autoPublishRetries {
+		delete(m.retrying, msg.resourceID)
+		m.failed[msg.resourceID] = true
 		return nil
 	}
+
+	m.retrying[msg.resourceID] = attempts + 1
+	delay := autoPublishBackoff << attempts
+	m.status = fmt.Sprintf("Auto-publish of %s failed, retrying in %s (%d/%d): %v", filepath.Base(msg.path), delay, attempts+1, autoPublishRetries, msg.err)
+	m.statusIsError = true
+	m.err = msg.err
+	retry := retryPublishMsg{path: msg.path, resourceID: msg.resourceID, attempt: attempts + 1}
+	return tea.Tick(delay, func(time.Time) tea.Msg { return retry })
 }
 
-func incrementVersion(version string) string {
-	parts := strings.Split(version, ".")
-	if len(parts) != 3 {
-		return "1.0.1"
+// transientError reports whether a publish may succeed when simply tried again
+func transientError(err error) bool {
+	var apiErr *d365.APIError
+	switch {
+	case d365.IsNetworkError(err), errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &apiErr):
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
 	}
+	return false
+}
 
-	minor, err := strconv.Atoi(parts[2])
-	if err != nil {
-		return "1.0.1"
-	}
+// publishDirectoryFile publishes a file of a bound folder that isn't bound
+// itself yet, creating its web resource if needed. It always ends with a
+// directoryPublishMsg, even if the token can't be refreshed, so that the path
+// is no longer marked as in flight.
+func (m Model) publishDirectoryFile(dir config.DirectoryBinding, path string) tea.Cmd {
+	cfg := m.config
+	client := m.client
+	refresh := m.refreshExpiredToken()
 
-	parts[2] = strconv.Itoa(minor + 1)
-	return strings.Join(parts, ".")
+	return func() tea.Msg {
+		var refreshed tea.Cmd
+		if refresh != nil {
+			fresh, ok := refresh()
+			if !ok {
+				return directoryPublishMsg{path: path, reAuth: true}
+			}
+			refreshed = func() tea.Msg { return tokenRefreshedMsg(fresh) }
+		}
+		client, cancel := withTimeout(client, cfg.PublishTimeout())
+		defer cancel()
+		result, err := publisher.PublishDirectoryFile(client, cfg, dir, path)
+		msg := directoryPublishMsg{path: path, result: result, err: err}
+		if refreshed != nil {
+			return tea.Batch(refreshed, func() tea.Msg { return msg })()
+		}
+		return msg
+	}
 }
 
 // setupTokenRefresh configures the client's token refresh callback
@@ -1307,12 +4072,16 @@ func (m *Model) setupTokenRefresh() {
 		return
 	}
 
-	orgURL := env.URL
+	target := *env
 	envName := env.Name
 
 	m.client.SetTokenRefreshFunc(func() (string, error) {
-		// Try to refresh the token silently
-		newToken, err := auth.RefreshAccessToken("", orgURL)
+		// Try to refresh the token silently with the latest stored refresh token
+		refreshToken := ""
+		if stored, err := auth.LoadToken(envName); err == nil {
+			refreshToken = stored.RefreshToken
+		}
+		newToken, err := auth.RefreshToken(target, refreshToken)
 		if err != nil {
 			return "", err
 		}
@@ -1326,7 +4095,7 @@ func (m *Model) setupTokenRefresh() {
 }
 
 func (m Model) exportTokenForEnvironment(env *config.Environment, token *auth.Token) error {
-	if env == nil || token == nil || strings.TrimSpace(env.TokenOutputDir) == "" {
+	if m.readOnly || env == nil || token == nil || strings.TrimSpace(env.TokenOutputDir) == "" {
 		return nil
 	}
 
@@ -1343,6 +4112,7 @@ func (m Model) handleSolutionPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.solutionResource = nil
 		m.createSolution = nil
 		m.solutions = nil
+		m.pickingFilter = false
 		return m, nil
 
 	case "up", "k":
@@ -1358,6 +4128,17 @@ func (m Model) handleSolutionPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "enter":
 		if m.solutionSelected < len(m.solutions) {
 			solution := m.solutions[m.solutionSelected]
+			if m.pickingFilter {
+				m.pickingFilter = false
+				m.solutionFilter = &solution
+				m.solutions = nil
+				m.state = StateList
+				m.resourceSelected = 0
+				m.status = fmt.Sprintf("Loading the web resources of %s...", solution.FriendlyName)
+				m.statusIsError = false
+				return m, m.fetchResources()
+			}
+			m.config.SetDefaultSolution(m.config.CurrentEnvironment, solution.UniqueName)
 
 			// Check if this is for adding existing resource or creating new
 			if m.solutionResource != nil {
@@ -1369,6 +4150,9 @@ func (m Model) handleSolutionPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			} else {
 				// Creating new web resource - move to mode selection
 				m.createSolution = &solution
+				if prefix := m.publisherPrefix(); prefix != "" && !strings.HasPrefix(m.createPrefix, prefix+"_") {
+					m.createPrefix = prefix + "_/"
+				}
 				m.state = StateCreateModeSelect
 				m.createModeSelected = 0
 				return m, nil
@@ -1379,6 +4163,395 @@ func (m Model) handleSolutionPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m Model) handleDiffKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "esc", "n":
+		m.state = StateList
+		m.diffResource = nil
+		m.diffLines = nil
+		m.status = "Publish cancelled"
+		m.statusIsError = false
+
+	case "up", "k":
+		if m.diffScroll > 0 {
+			m.diffScroll--
+		}
+
+	case "down", "j":
+		if m.diffScroll < len(m.diffLines)-1 {
+			m.diffScroll++
+		}
+
+	case "pgup":
+		m.diffScroll = max(0, m.diffScroll-m.diffPageSize())
+
+	case "pgdown", " ":
+		m.diffScroll = max(0, min(len(m.diffLines)-1, m.diffScroll+m.diffPageSize()))
+
+	case "y", "enter":
+		if m.diffLoading || m.diffResource == nil {
+			return m, nil
+		}
+		res := *m.diffResource
+		m.state = StateList
+		m.diffResource = nil
+		m.diffLines = nil
+		m.publishing[res.ID] = true
+		m.status = fmt.Sprintf("Publishing %s...", res.Name)
+		m.statusIsError = false
+		return m, m.publishResource(res, true, false)
+	}
+
+	return m, nil
+}
+
+// diffPageSize is the number of diff lines shown at once
+func (m Model) diffPageSize() int {
+	return max(5, m.height-16)
+}
+
+// fetchDiff downloads the server content of a binding's resource and diffs it
+// against the content a publish would upload
+func (m Model) fetchDiff(binding config.Binding) tea.Cmd {
+	client := m.client
+	env := m.config.GetEnvironment(binding.Environment)
+
+	return m.withFreshToken(func() tea.Msg {
+		result := diffMsg{resourceID: binding.WebResourceID}
+		if client == nil {
+			result.err = fmt.Errorf("not connected")
+			return result
+		}
+
+		if err := publisher.RunBuild(binding, m.config.PublishTimeout()); err != nil {
+			result.err = err
+			return result
+		}
+		content, err := publisher.ReadBinding(binding)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		result.warning = publisher.ContentWarning(content)
+		encoded, replaced := publisher.PrepareContent(env, binding.LocalPath, content)
+		result.replaced = replaced
+		local, _ := base64.StdEncoding.DecodeString(encoded)
+
+		client, cancel := withTimeout(client, quickTimeout)
+		defer cancel()
+		serverEncoded, err := client.GetWebResourceContent(binding.WebResourceID)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		server, err := base64.StdEncoding.DecodeString(serverEncoded)
+		if err != nil {
+			result.err = fmt.Errorf("decode server content: %w", err)
+			return result
+		}
+
+		resourceType, err := d365.GetWebResourceTypeFromExtension(binding.LocalPath)
+		if err != nil || !resourceType.IsText() {
+			result.binary = true
+			result.changed = !bytes.Equal(local, server)
+			return result
+		}
+
+		lines := diff.Lines(string(server), string(local))
+		result.changed = diff.Changed(lines)
+		result.lines = diff.Unified(lines, 3)
+		return result
+	})
+}
+
+func (m Model) handleSnapshotPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "esc":
+		m.state = StateList
+		m.snapshots = nil
+		m.snapshotBinding = nil
+		return m, nil
+
+	case "up", "k":
+		if m.snapshotSelected > 0 {
+			m.snapshotSelected--
+		}
+
+	case "down", "j":
+		if m.snapshotSelected < len(m.snapshots)-1 {
+			m.snapshotSelected++
+		}
+
+	case "enter":
+		if m.snapshotSelected < len(m.snapshots) {
+			m.inputMode = InputRestoreConfirm
+			m.textInput.Placeholder = "Restore? (y/n)"
+			m.textInput.SetValue("")
+			m.textInput.Focus()
+		}
+	}
+
+	return m, nil
+}
+
+// historyLimit is how many of the most recent publish log entries StateHistory shows
+const historyLimit = 500
+
+func (m Model) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "esc":
+		m.state = StateList
+		m.history = nil
+		return m, nil
+
+	case "up", "k":
+		if m.historySelected > 0 {
+			m.historySelected--
+		}
+
+	case "down", "j":
+		if m.historySelected < len(m.history)-1 {
+			m.historySelected++
+		}
+
+	case "pgup":
+		m.historySelected = max(0, m.historySelected-10)
+
+	case "pgdown", " ":
+		m.historySelected = max(0, min(len(m.history)-1, m.historySelected+10))
+	}
+
+	return m, nil
+}
+
+// restoreSnapshot uploads a snapshot back to the server
+func (m Model) restoreSnapshot(binding config.Binding, snapshot publisher.Snapshot) tea.Cmd {
+	cfg := m.config
+	client := m.client
+
+	return func() tea.Msg {
+		if client == nil {
+			return restoreMsg{resourceID: binding.WebResourceID, name: binding.WebResourceName, err: fmt.Errorf("not connected")}
+		}
+		client, cancel := withTimeout(client, m.config.PublishTimeout())
+		defer cancel()
+		err := publisher.Restore(client, cfg, binding, snapshot)
+		return restoreMsg{resourceID: binding.WebResourceID, name: binding.WebResourceName, err: err}
+	}
+}
+
+// undoPublish reverts the most recent publish in the current environment
+func (m Model) undoPublish() tea.Cmd {
+	cfg := m.config
+	client := m.client
+	envName := m.config.CurrentEnvironment
+
+	return func() tea.Msg {
+		if client == nil {
+			return undoMsg{err: fmt.Errorf("not connected")}
+		}
+		client, cancel := withTimeout(client, cfg.PublishTimeout())
+		defer cancel()
+		binding, err := publisher.UndoLast(client, cfg, envName)
+		return undoMsg{name: binding.WebResourceName, err: err}
+	}
+}
+
+func (m Model) handleSyncScanKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "esc":
+		if m.syncScanning {
+			// Stop the scan but keep the partial results on screen
+			m.syncScanning = false
+			m.syncCancelled = true
+			m.status = fmt.Sprintf("Sync scan cancelled after %d/%d", len(m.syncResults), len(m.syncBindings))
+			m.statusIsError = false
+			return m, nil
+		}
+		m.state = StateList
+		m.syncBindings = nil
+		m.syncResults = nil
+		return m, nil
+
+	case "up", "k":
+		if m.syncSelected > 0 {
+			m.syncSelected--
+		}
+
+	case "down", "j":
+		if m.syncSelected < len(m.syncResults)-1 {
+			m.syncSelected++
+		}
+	}
+
+	return m, nil
+}
+
+// checkBindingSync compares a bound file with the content deployed on the server
+func (m Model) checkBindingSync(scanID int, binding config.Binding) tea.Cmd {
+	client := m.client
+	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
+
+	return func() tea.Msg {
+		return syncCheckMsg{scanID: scanID, result: publisher.CheckSync(client, env, binding)}
+	}
+}
+
+// openErrorDetails shows the full details of the last failure
+func (m Model) openErrorDetails() (tea.Model, tea.Cmd) {
+	if m.err == nil || !m.statusIsError {
+		m.status = "No error to show"
+		m.statusIsError = false
+		return m, nil
+	}
+	m.errorReturnState = m.state
+	m.errorScroll = 0
+	m.state = StateErrorDetails
+	return m, nil
+}
+
+func (m Model) handleErrorDetailsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "esc", "E":
+		m.state = m.errorReturnState
+
+	case "up", "k":
+		if m.errorScroll > 0 {
+			m.errorScroll--
+		}
+
+	case "down", "j":
+		m.errorScroll++
+
+	case "y":
+		if err := clipboard.WriteAll(errorDetailsText(m.err)); err != nil {
+			m.status = fmt.Sprintf("Copy failed: %v", err)
+		} else {
+			m.status = "Error details copied to clipboard"
+		}
+		// Keep the error state so the details stay reachable
+		m.statusIsError = true
+	}
+
+	return m, nil
+}
+
+func (m Model) handleResourceDetailsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "esc", "i":
+		m.state = StateList
+		m.detailsResource = nil
+		m.detailsAudit = nil
+		m.detailsErr = nil
+
+	case "n":
+		if res := m.detailsResource; res != nil {
+			if res.IsManaged {
+				m.status = "Managed web resources cannot be renamed"
+				m.statusIsError = true
+				return m, nil
+			}
+			m.inputMode = InputDisplayName
+			m.textInput.Placeholder = "Display name"
+			m.textInput.SetValue(res.DisplayName)
+			m.textInput.Focus()
+		}
+
+	case "N":
+		if res := m.detailsResource; res != nil {
+			if res.IsManaged {
+				m.status = "Managed web resources cannot be renamed"
+				m.statusIsError = true
+				return m, nil
+			}
+			m.inputMode = InputResourceName
+			m.textInput.Placeholder = "Unique name"
+			m.textInput.SetValue(res.Name)
+			m.textInput.Focus()
+		}
+	}
+
+	return m, nil
+}
+
+// deleteResource deletes a web resource from the server
+func (m Model) deleteResource(res d365.WebResource) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		if client == nil {
+			return deleteResourceMsg{resourceID: res.ID, name: res.Name, err: fmt.Errorf("not connected")}
+		}
+		err := client.DeleteWebResource(res.ID)
+		return deleteResourceMsg{resourceID: res.ID, name: res.Name, err: err}
+	}
+}
+
+// renameResource changes a web resource's unique name on the server and
+// publishes it so the new name takes effect
+func (m Model) renameResource(res d365.WebResource, name string) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		msg := renameMsg{resourceID: res.ID, oldName: res.Name, name: name}
+		if client == nil {
+			msg.err = fmt.Errorf("not connected")
+			return msg
+		}
+		quick, cancel := withTimeout(client, quickTimeout)
+		defer cancel()
+		if msg.err = quick.RenameWebResource(res.ID, name); msg.err != nil {
+			return msg
+		}
+		msg.publishErr = client.PublishWebResource(res.ID)
+		return msg
+	}
+}
+
+// updateDisplayName renames a web resource's display name on the server
+func (m Model) updateDisplayName(res d365.WebResource, displayName string) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		if client == nil {
+			return displayNameMsg{resourceID: res.ID, err: fmt.Errorf("not connected")}
+		}
+		client, cancel := withTimeout(client, quickTimeout)
+		defer cancel()
+		err := client.UpdateWebResourceDisplayName(res.ID, displayName)
+		return displayNameMsg{resourceID: res.ID, displayName: displayName, err: err}
+	}
+}
+
+func (m Model) fetchResourceAudit(res d365.WebResource) tea.Cmd {
+	client := m.client
+
+	return func() tea.Msg {
+		if client == nil {
+			return resourceAuditMsg{resourceID: res.ID, err: fmt.Errorf("not connected")}
+		}
+		client, cancel := withTimeout(client, quickTimeout)
+		defer cancel()
+		audit, err := client.GetWebResourceAudit(res.ID)
+		return resourceAuditMsg{resourceID: res.ID, audit: audit, err: err}
+	}
+}
+
 func (m Model) fetchSolutions() tea.Cmd {
 	client := m.client
 
@@ -1402,18 +4575,13 @@ func (m Model) addToSolution(solution d365.Solution, resource d365.WebResource)
 			return errMsg(fmt.Errorf("not connected"))
 		}
 		err := client.AddWebResourceToSolution(solution.UniqueName, resource.ID)
-		if err != nil {
-			return addToSolutionMsg{
-				success:      false,
-				err:          err,
-				solutionName: solution.FriendlyName,
-				resourceName: resource.Name,
-			}
-		}
 		return addToSolutionMsg{
-			success:      true,
+			success:      err == nil,
+			err:          err,
 			solutionName: solution.FriendlyName,
+			solutionID:   solution.UniqueName,
 			resourceName: resource.Name,
+			resourceID:   resource.ID,
 		}
 	}
 }
@@ -1488,10 +4656,11 @@ func (m Model) handleCreateFilePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.createFiles = []CreateFileInfo{{
 				LocalPath:    path,
 				ResourceType: resourceType,
+				Warning:      publisher.EncodingWarning(path),
 			}}
 			m.state = StateCreateNameInput
-			m.textInput.SetValue("")
-			m.textInput.Placeholder = "e.g., publisher_/folder/filename.js"
+			m.textInput.SetValue(m.publisherPrefix() + "_/")
+			m.textInput.Placeholder = fmt.Sprintf("e.g., %s_/folder/filename.js", m.publisherPrefix())
 			return m, nil
 		}
 	} else {
@@ -1520,6 +4689,11 @@ func (m Model) handleCreateNameInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.statusIsError = true
 			return m, nil
 		}
+		if err := m.validateResourceName(name); err != nil {
+			m.status = err.Error()
+			m.statusIsError = true
+			return m, nil
+		}
 
 		m.textInput.Blur()
 		// Update the file info with the name
@@ -1546,6 +4720,11 @@ func (m Model) handleCreatePrefixInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "enter":
 		prefix := strings.TrimSpace(m.textInput.Value())
+		if err := m.validateResourceName(prefix); err != nil {
+			m.status = err.Error()
+			m.statusIsError = true
+			return m, nil
+		}
 		m.createPrefix = prefix
 		m.textInput.Blur()
 
@@ -1617,6 +4796,17 @@ func (m Model) handleCreateConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case "n":
+		// Set the display name of the selected file
+		if m.createFileSelected < len(m.createFiles) {
+			file := m.createFiles[m.createFileSelected]
+			m.inputMode = InputDisplayName
+			m.textInput.Placeholder = filepath.Base(file.WebResName)
+			m.textInput.SetValue(file.DisplayName)
+			m.textInput.Focus()
+		}
+		return m, nil
+
 	case "enter", "y":
 		// Create all the resources
 		m.creatingResources = true
@@ -1629,6 +4819,8 @@ func (m Model) handleCreateConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) scanFolderForFiles(folderPath string) tea.Cmd {
+	ignore := m.config.IgnorePatterns(m.config.CurrentEnvironment)
+
 	return func() tea.Msg {
 		var files []CreateFileInfo
 
@@ -1636,6 +4828,13 @@ func (m Model) scanFolderForFiles(folderPath string) tea.Cmd {
 			if err != nil {
 				return err
 			}
+			// Skip editor temp files and whatever the environment ignores
+			if rel, err := filepath.Rel(folderPath, path); err == nil && rel != "." && config.Ignored(ignore, rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			if info.IsDir() {
 				return nil
 			}
@@ -1655,6 +4854,7 @@ func (m Model) scanFolderForFiles(folderPath string) tea.Cmd {
 				LocalPath:    path,
 				WebResName:   relPath,
 				ResourceType: resourceType,
+				Warning:      publisher.EncodingWarning(path),
 			})
 
 			return nil
@@ -1674,6 +4874,7 @@ func (m Model) createWebResources() tea.Cmd {
 	files := m.createFiles
 	cfg := m.config
 	currentEnv := m.config.CurrentEnvironment
+	env := m.config.GetEnvironment(currentEnv)
 
 	return func() tea.Msg {
 		if client == nil {
@@ -1693,12 +4894,12 @@ func (m Model) createWebResources() tea.Cmd {
 				continue
 			}
 
-			encoded := base64.StdEncoding.EncodeToString(content)
+			encoded, _ := publisher.PrepareContent(env, file.LocalPath, content)
 
 			// Create the web resource
 			resourceID, err := client.CreateWebResource(
 				file.WebResName,
-				filepath.Base(file.WebResName),
+				file.displayName(),
 				encoded,
 				file.ResourceType,
 			)
@@ -1709,11 +4910,14 @@ func (m Model) createWebResources() tea.Cmd {
 			}
 
 			// Add to solution
+			inSolution := ""
 			if solution != nil {
 				if err := client.AddWebResourceToSolution(solution.UniqueName, resourceID); err != nil {
 					// Resource created but failed to add to solution
 					failed = append(failed, file.WebResName+" (add to solution)")
 					lastErr = err
+				} else {
+					inSolution = solution.UniqueName
 				}
 			}
 
@@ -1728,12 +4932,15 @@ func (m Model) createWebResources() tea.Cmd {
 
 			// Create binding
 			binding := config.Binding{
-				Environment:      currentEnv,
-				LocalPath:        file.LocalPath,
-				WebResourceName:  file.WebResName,
-				WebResourceID:    resourceID,
-				LastKnownVersion: "1.0.0",
-				AutoPublish:      true,
+				Environment:       currentEnv,
+				LocalPath:         file.LocalPath,
+				WebResourceName:   file.WebResName,
+				WebResourceID:     resourceID,
+				LastKnownVersion:  "1.0.0",
+				AutoPublish:       true,
+				LastPublishedHash: publisher.ContentHash(encoded),
+				DisplayName:       file.displayName(),
+				Solution:          inSolution,
 			}
 			cfg.AddBinding(binding)
 		}
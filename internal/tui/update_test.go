@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"testing"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// text types a value into the focused input
+func text(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+var (
+	keyEnter = tea.KeyMsg{Type: tea.KeyEnter}
+	keyEsc   = tea.KeyMsg{Type: tea.KeyEsc}
+	keyClear = tea.KeyMsg{Type: tea.KeyCtrlU}
+)
+
+// TestEnvironmentEdit walks the add and edit flows, cancelling at each step,
+// and checks that a cancelled edit leaves nothing behind for the next one
+func TestEnvironmentEdit(t *testing.T) {
+	const devURL = "https://dev.crm.dynamics.com"
+	const testURL = "https://test.crm.dynamics.com"
+
+	tests := []struct {
+		name  string
+		keys  []tea.KeyMsg
+		want  []config.Environment
+		state InputMode
+	}{
+		{
+			name: "rename and save",
+			keys: []tea.KeyMsg{text("e"), keyClear, text("Test"), keyEnter, keyEnter, text("y"), keyEnter},
+			want: []config.Environment{{Name: "Test", URL: devURL}},
+		},
+		{
+			name: "cancel at name, then edit again",
+			keys: []tea.KeyMsg{
+				text("e"), keyClear, text("Half"), keyEsc,
+				text("e"), keyEnter, keyClear, text(testURL), keyEnter, text("y"), keyEnter,
+			},
+			want: []config.Environment{{Name: "Dev", URL: testURL}},
+		},
+		{
+			name: "cancel at URL, then edit again",
+			keys: []tea.KeyMsg{
+				text("e"), keyClear, text("Half"), keyEnter, keyEsc,
+				text("e"), keyClear, text("Test"), keyEnter, keyEnter, text("y"), keyEnter,
+			},
+			want: []config.Environment{{Name: "Test", URL: devURL}},
+		},
+		{
+			name: "cancel at confirmation",
+			keys: []tea.KeyMsg{text("e"), keyClear, text("Test"), keyEnter, keyEnter, keyEsc},
+			want: []config.Environment{{Name: "Dev", URL: devURL}},
+		},
+		{
+			name: "decline at confirmation",
+			keys: []tea.KeyMsg{text("e"), keyClear, text("Test"), keyEnter, keyEnter, text("n"), keyEnter},
+			want: []config.Environment{{Name: "Dev", URL: devURL}},
+		},
+		{
+			name:  "invalid URL stays on the URL step",
+			keys:  []tea.KeyMsg{text("e"), keyEnter, keyClear, text("dev"), keyEnter},
+			want:  []config.Environment{{Name: "Dev", URL: devURL}},
+			state: InputEnvironmentURL,
+		},
+		{
+			name: "cancelled edit, then add",
+			keys: []tea.KeyMsg{
+				text("e"), keyClear, text("Half"), keyEnter, keyEsc,
+				text("a"), text("Test"), keyEnter, text(testURL), keyEnter, text("y"), keyEnter,
+			},
+			want: []config.Environment{{Name: "Dev", URL: devURL}, {Name: "Test", URL: testURL}},
+		},
+	}
+
+	config.SetReadOnly(true)
+	defer config.SetReadOnly(false)
+	keys, err := resolveKeymap(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ti := textinput.New()
+			ti.Focus()
+			var m tea.Model = Model{
+				state:     StateEnvironmentSelect,
+				config:    &config.Config{Environments: []config.Environment{{Name: "Dev", URL: devURL}}},
+				textInput: ti,
+				keys:      keys,
+			}
+			for _, key := range tt.keys {
+				m, _ = m.Update(key)
+			}
+
+			got := m.(Model)
+			if got.inputMode != tt.state {
+				t.Errorf("input mode %v, want %v", got.inputMode, tt.state)
+			}
+			if tt.state == InputNone && got.envEdit != nil {
+				t.Errorf("edit still in progress: %+v", *got.envEdit)
+			}
+			if len(got.config.Environments) != len(tt.want) {
+				t.Fatalf("environments %+v, want %+v", got.config.Environments, tt.want)
+			}
+			for i, env := range got.config.Environments {
+				if env.Name != tt.want[i].Name || env.URL != tt.want[i].URL {
+					t.Errorf("environment %d is %s (%s), want %s (%s)", i, env.Name, env.URL, tt.want[i].Name, tt.want[i].URL)
+				}
+			}
+		})
+	}
+}
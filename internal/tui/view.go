@@ -1,8 +1,19 @@
 package tui
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/config"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/d365"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/diff"
+	"codeberg.org/schalkuz/xrm-webresource-publisher/internal/publisher"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -57,6 +68,17 @@ var (
 	unboundStyle = lipgloss.NewStyle().
 			Foreground(COLOR_MutedDark)
 
+	missingStyle = lipgloss.NewStyle().
+			Foreground(COLOR_Error)
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(COLOR_Error).
+			Bold(true)
+
+	labelStyle = lipgloss.NewStyle().
+			Foreground(COLOR_Warning).
+			Italic(true)
+
 	// Border styles
 	mainBorderStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
@@ -129,7 +151,7 @@ var (
 func (m Model) renderStatusBar(width int) string {
 	// Determine status state
 	var stateSection string
-	isPublishing := false
+	isPublishing := m.publishingAll
 	for _, publishing := range m.publishing {
 		if publishing {
 			isPublishing = true
@@ -148,7 +170,14 @@ func (m Model) renderStatusBar(width int) string {
 	// Count section (resources found)
 	var countSection string
 	if m.state == StateList && len(m.resources) > 0 {
-		countSection = statusBarCountStyle.Render(fmt.Sprintf(" %d resources ", len(m.resources)))
+		count := fmt.Sprintf(" %d resources ", len(m.resources))
+		if len(m.marked) > 0 {
+			count = fmt.Sprintf(" %d marked •%s", len(m.marked), count)
+		}
+		if m.refreshing {
+			count = " refreshing... •" + count
+		}
+		countSection = statusBarCountStyle.Render(count)
 	}
 
 	// Message section (middle)
@@ -194,6 +223,12 @@ func (m Model) View() string {
 		content = m.viewBinding()
 	case StateSolutionPicker:
 		content = m.viewSolutionPicker()
+	case StateSnapshotPicker:
+		content = m.viewSnapshotPicker()
+	case StateHistory:
+		content = m.viewHistory()
+	case StateDiff:
+		content = m.viewDiff()
 	case StateCreateModeSelect:
 		content = m.viewCreateModeSelect()
 	case StateCreateNameInput:
@@ -202,6 +237,15 @@ func (m Model) View() string {
 		content = m.viewCreatePrefixInput()
 	case StateCreateConfirm:
 		content = m.viewCreateConfirm()
+	case StateSyncScan:
+		content = m.viewSyncScan()
+	case StateResourceDetails:
+		content = m.viewResourceDetails()
+	case StateErrorDetails:
+		content = m.viewErrorDetails()
+	}
+	if m.showHelp {
+		content = m.viewHelp()
 	}
 
 	statusBar := m.renderStatusBar(m.width - 12) // Account for main border and padding
@@ -225,6 +269,9 @@ func (m Model) viewEnvironmentSelect() string {
 
 	// Title
 	title := titleStyle.Render("D365 Web Resource Publisher")
+	if m.readOnly {
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, readOnlyBadge)
+	}
 
 	// Input mode
 	if m.inputMode != InputNone {
@@ -234,10 +281,49 @@ func (m Model) viewEnvironmentSelect() string {
 			inputContent.WriteString("Environment Name:\n")
 		case InputEnvironmentURL:
 			inputContent.WriteString("Environment URL:\n")
+		case InputEnvironmentConfirm:
+			if edit := m.envEdit; edit != nil {
+				if edit.originalName != "" && edit.originalName != edit.name {
+					inputContent.WriteString(fmt.Sprintf("Name: %s → %s\n", edit.originalName, edit.name))
+				} else {
+					inputContent.WriteString(fmt.Sprintf("Name: %s\n", edit.name))
+				}
+				inputContent.WriteString(fmt.Sprintf("URL:  %s\n\n", edit.url))
+			}
+			inputContent.WriteString("Save this environment? (y/n):\n")
+		case InputEnvironmentPrefix:
+			inputContent.WriteString("Publisher Prefix (leave empty to use the default):\n")
 		case InputDeleteConfirm:
 			if m.envSelected < len(m.config.Environments) {
 				inputContent.WriteString(fmt.Sprintf("Delete '%s'? (y/n):\n", m.config.Environments[m.envSelected].Name))
 			}
+		case InputSignOutConfirm:
+			if m.envSelected < len(m.config.Environments) {
+				inputContent.WriteString(fmt.Sprintf("Sign out of '%s'? The stored token is removed and the next sign-in can use a different account (y/n):\n", m.config.Environments[m.envSelected].Name))
+			}
+		case InputExportPath:
+			inputContent.WriteString("Export environments and bindings to (tokens are never exported):\n")
+		case InputImportPath:
+			inputContent.WriteString("Import environments and bindings from:\n")
+		case InputImportCommandsConfirm:
+			inputContent.WriteString(fmt.Sprintf("%s runs these commands when publishing:\n", m.importPath))
+			for _, command := range m.configImport.Commands() {
+				inputContent.WriteString(missingStyle.Render("  "+command) + "\n")
+			}
+			inputContent.WriteString("\nOnly import them if you trust where the file came from.\n")
+		case InputImportMode:
+			inputContent.WriteString(fmt.Sprintf("Import %s\n\n", m.importPath))
+			inputContent.WriteString("m: merge (add what is missing, keep existing entries)\n")
+			inputContent.WriteString("r: replace (discard the current environments and bindings)\n")
+		case InputOrphanTokensConfirm:
+			inputContent.WriteString("Tokens with no matching environment:\n")
+			for _, path := range m.orphanTokens {
+				inputContent.WriteString(dimStyle.Render("  "+filepath.Base(path)) + "\n")
+			}
+			for _, name := range m.orphanEntries {
+				inputContent.WriteString(dimStyle.Render("  keychain: "+name) + "\n")
+			}
+			inputContent.WriteString(fmt.Sprintf("\nDelete these %d tokens? (y/n):\n", len(m.orphanTokens)+len(m.orphanEntries)))
 		}
 		inputContent.WriteString(m.textInput.View())
 		inputBox := contentBoxStyle.Width(availableWidth).Render(inputContent.String())
@@ -252,7 +338,17 @@ func (m Model) viewEnvironmentSelect() string {
 		envContent.WriteString(dimStyle.Render("Press 'a' to add an environment"))
 	} else {
 		for i, env := range m.config.Environments {
-			line := fmt.Sprintf("  %s\n  %s", env.Name, dimStyle.Render(env.URL))
+			name := env.Name
+			if env.RequireConfirm {
+				name = productionStyle.Render(name + "  ⚠ production")
+			}
+			if health := m.envHealth[env.Name]; health != HealthUnknown {
+				name += "  " + envHealthStyle(health).Render("● "+health.String())
+			}
+			line := fmt.Sprintf("  %s\n  %s", name, dimStyle.Render(env.URL))
+			if env.PublisherPrefix != "" {
+				line += fmt.Sprintf("\n  %s", dimStyle.Render("prefix: "+env.PublisherPrefix+"_"))
+			}
 			if env.TokenOutputDir != "" {
 				line += fmt.Sprintf("\n  %s", dimStyle.Render("token.json -> "+env.TokenOutputDir))
 			}
@@ -268,7 +364,7 @@ func (m Model) viewEnvironmentSelect() string {
 	}
 
 	envBox := contentBoxStyle.Width(availableWidth).Render(envContent.String())
-	helpRendered := helpStyle.Width(availableWidth).Render("↑/↓: navigate • enter: select • a: add • e: edit • d: delete • c: clear auth • t: set token root • x: clear token root • q: quit")
+	helpRendered := helpStyle.Width(availableWidth).Render(m.helpLine(StateEnvironmentSelect))
 
 	return lipgloss.JoinVertical(lipgloss.Left, title, envBox, helpRendered)
 }
@@ -281,13 +377,41 @@ func (m Model) viewAuth() string {
 
 	// Auth content
 	var authContent strings.Builder
-	authContent.WriteString(m.spinner.View())
-	authContent.WriteString(" Opening browser for authentication...\n\n")
-	authContent.WriteString("A browser window will open for you to sign in.\n")
-	authContent.WriteString("After signing in, you can return to this application.")
+	helpText := "esc: back • q: quit"
+	if m.authErr != nil {
+		if m.authCancelled {
+			authContent.WriteString(lipgloss.NewStyle().Foreground(COLOR_Warning).Render("Sign-in was cancelled."))
+		} else {
+			authContent.WriteString(lipgloss.NewStyle().Foreground(COLOR_Error).Render("Authentication failed:"))
+			authContent.WriteString("\n")
+			authContent.WriteString(dimStyle.Render(m.authErr.Error()))
+		}
+		authContent.WriteString("\n\nPress enter or r to try again.")
+		helpText = "enter/r: retry • E: error details • esc: back • q: quit"
+	} else if m.deviceCodeFlow {
+		if code := m.deviceCode; code != nil {
+			authContent.WriteString("To sign in, open\n\n  " + code.VerificationURI + "\n\n")
+			authContent.WriteString("on any device and enter the code\n\n  " + lipgloss.NewStyle().Bold(true).Render(code.UserCode) + "\n\n")
+			authContent.WriteString(m.spinner.View() + " Waiting for you to sign in...")
+		} else {
+			authContent.WriteString(m.spinner.View() + " Requesting a sign-in code...")
+		}
+	} else {
+		authContent.WriteString(m.spinner.View())
+		authContent.WriteString(" Opening browser for authentication...\n\n")
+		authContent.WriteString("A browser window will open for you to sign in.\n")
+		authContent.WriteString("After signing in, you can return to this application.")
+	}
+	if env := m.config.GetEnvironment(m.config.CurrentEnvironment); env != nil && !env.UsesClientCredentials() {
+		if m.deviceCodeFlow {
+			helpText = "b: use the browser instead • " + helpText
+		} else {
+			helpText = "d: use a device code instead • " + helpText
+		}
+	}
 
 	authBox := contentBoxStyle.Width(availableWidth).Render(authContent.String())
-	helpRendered := helpStyle.Width(availableWidth).Render("esc: back • q: quit")
+	helpRendered := helpStyle.Width(availableWidth).Render(helpText + " • ?: help")
 
 	return lipgloss.JoinVertical(lipgloss.Left, title, authBox, helpRendered)
 }
@@ -303,21 +427,92 @@ func (m Model) viewList() string {
 	if m.includeManaged {
 		filterLabel = "All"
 	}
+	if m.solutionFilter != nil {
+		filterLabel += ", solution " + m.solutionFilter.FriendlyName
+	}
+	if m.searchQuery != "" {
+		filterLabel += fmt.Sprintf(", search %q", m.searchQuery)
+	}
 	if env != nil {
 		title = titleStyle.Render(fmt.Sprintf("Web Resources - %s (%s)", env.Name, filterLabel))
 	} else {
 		title = titleStyle.Render(fmt.Sprintf("Web Resources (%s)", filterLabel))
 	}
+	if m.readOnly {
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, readOnlyBadge)
+	}
+	if m.requiresConfirm() {
+		badge := fmt.Sprintf(" ⚠ production, auto-publish disarmed (%s: arm)", m.keyFor(StateList, "arm"))
+		if !m.autoPublishHeld() {
+			badge = " ⚠ production, auto-publish armed"
+		}
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, productionStyle.Render(badge))
+	}
+	if m.watchersArming {
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, lipgloss.NewStyle().Foreground(COLOR_Warning).Render(" "+m.spinner.View()+" arming watchers"))
+	}
+	if m.offline {
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, lipgloss.NewStyle().Foreground(COLOR_Error).Bold(true).Render(" ○ offline"))
+	} else {
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, lipgloss.NewStyle().Foreground(COLOR_Success).Render(" ● online"))
+	}
+	if session := m.sessionLine(); session != "" {
+		title = lipgloss.JoinVertical(lipgloss.Left, title, session)
+	}
 
 	// Tabs
 	tabs := m.renderTabs(availableWidth)
+	if m.inputMode == InputSearch {
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs, "Search: "+m.textInput.View())
+	}
+	if m.inputMode == InputBindingFilter {
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs, "Filter: "+m.textInput.View())
+	} else if m.bindingTab == BindingTabList && m.bindingFilter != "" {
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs, dimStyle.Render(fmt.Sprintf("Filter: %s ('/' to change)", m.bindingFilter)))
+	}
+	if m.inputMode == InputBindingLabel {
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs, "Label: "+m.textInput.View())
+	}
+	if m.inputMode == InputSnoozeMinutes && m.snoozeTarget != nil {
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs,
+			fmt.Sprintf("Snooze auto-publish for %s, minutes (empty: until 'z' again): %s", m.snoozeTarget.WebResourceName, m.textInput.View()))
+	}
+	if m.inputMode == InputPromoteConfirm && m.promotion != nil {
+		names := make([]string, 0, len(m.promotion.files))
+		for _, f := range m.promotion.files {
+			names = append(names, f.Name)
+		}
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs,
+			fmt.Sprintf("Promote %d resources to %s? (y/n): %s", len(m.promotion.files), m.promotion.target, m.textInput.View()),
+			lipgloss.NewStyle().Width(availableWidth).Render(dimStyle.Render(strings.Join(names, ", "))))
+	}
+	if m.inputMode == InputOverwriteConfirm && m.overwrite != nil {
+		warning := dimStyle.Render(fmt.Sprintf("%s in %s, after your last publish (version %d, now %d). Answer n and press %s to compare first.", formatAudit(&m.overwrite.Audit), m.config.CurrentEnvironment, m.overwrite.Known, m.overwrite.Current, m.keyFor(StateList, "diffPublish")))
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs,
+			fmt.Sprintf("%s changed on the server. Overwrite it with your local file? (y/n): %s", m.overwrite.Name, m.textInput.View()),
+			lipgloss.NewStyle().Width(availableWidth).Render(warning))
+	}
+	if m.inputMode == InputDeleteResourceConfirm && m.deleteTarget != nil {
+		warning := dimStyle.Render(fmt.Sprintf("Deletes %s from %s and removes its binding. This can't be undone.", m.deleteTarget.Name, m.config.CurrentEnvironment))
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs,
+			fmt.Sprintf("Type %s to delete it: %s", path.Base(m.deleteTarget.Name), m.textInput.View()),
+			lipgloss.NewStyle().Width(availableWidth).Render(warning))
+	}
+	if m.inputMode == InputProductionConfirm && m.confirmKey != nil {
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs, m.viewProductionConfirm(availableWidth))
+	}
+	if m.inputMode == InputPublishAllConfirm {
+		envName := m.config.CurrentEnvironment
+		warning := dimStyle.Render("Publishes every unpublished customization (forms, views, ribbons, web resources), not just bound files. This is much slower than a per-resource publish.")
+		tabs = lipgloss.JoinVertical(lipgloss.Left, tabs,
+			fmt.Sprintf("Publish ALL customizations in %s? (y/n): %s", envName, m.textInput.View()),
+			lipgloss.NewStyle().Width(availableWidth).Render(warning))
+	}
 
 	// Help text based on active tab
-	var helpText string
-	if m.bindingTab == BindingTabBind {
-		helpText = "tab: switch • ↑/↓: navigate • enter: expand/collapse • b: bind • u: unbind • p: publish • s: add to solution • N: new • a: toggle auto • m: managed/all • r: refresh • l: login • esc: back • q: quit"
-	} else {
-		helpText = "tab: switch • ↑/↓: navigate • u: unbind • a: toggle auto • p: publish • t: refresh token • s: add to solution • N: new • m: managed/all • l: login • esc: back • q: quit"
+	helpText := m.helpLine(StateList)
+	if last := m.config.LastPublishedBinding(m.config.CurrentEnvironment); last != nil {
+		helpText = fmt.Sprintf("%s: republish %s • ", m.keyFor(StateList, "republishLast"), last.WebResourceName) + helpText
 	}
 	helpRendered := helpStyle.Width(availableWidth).Render(helpText)
 
@@ -373,6 +568,16 @@ func (m Model) viewBindFilesTab(width, height int) string {
 	} else {
 		// Calculate visible range for scrolling based on actual available height
 		visibleLines := height - 2 // Account for content box border
+		flat := m.config.ViewMode == config.ViewModeFlat
+		nameWidth := 0
+		if flat {
+			// Column header takes a line
+			visibleLines--
+			nameWidth = max(width-51, 20)
+			header := fmt.Sprintf("    %-*s %-4s %7s  %-10s %s", nameWidth, "Name", "Type", "Version", "Modified", "Status")
+			resourceContent.WriteString(dimStyle.Render(header + "  (sorted by " + m.flatSort.String() + ")"))
+			resourceContent.WriteString("\n")
+		}
 		if visibleLines < 3 {
 			visibleLines = 3
 		}
@@ -387,6 +592,13 @@ func (m Model) viewBindFilesTab(width, height int) string {
 			end = len(m.displayItems)
 		}
 
+		// Room for a line inside the box after the "> " selection marker
+		lineWidth := width - contentBoxStyle.GetHorizontalPadding() - 2
+		// Full name of the selected entry when it had to be shortened
+		var selectedFull string
+		// Who last changed the selected resource
+		var modifiedBy string
+
 		for i := start; i < end; i++ {
 			item := m.displayItems[i]
 			node := item.Node
@@ -397,10 +609,14 @@ func (m Model) viewBindFilesTab(width, height int) string {
 			var line string
 			if node.IsFolder {
 				// Folder with expand/collapse indicator
+				name := ellipsizeMiddle(node.Name, lineWidth-len(indent)-3)
+				if name != node.Name && i == m.resourceSelected {
+					selectedFull = node.FullPath
+				}
 				if node.Expanded {
-					line = fmt.Sprintf("%s▼ %s/", indent, node.Name)
+					line = fmt.Sprintf("%s▼ %s/", indent, name)
 				} else {
-					line = fmt.Sprintf("%s▶ %s/", indent, node.Name)
+					line = fmt.Sprintf("%s▶ %s/", indent, name)
 				}
 			} else {
 				// File with binding status
@@ -411,8 +627,16 @@ func (m Model) viewBindFilesTab(width, height int) string {
 				var status string
 				if m.publishing[res.ID] {
 					status = lipgloss.NewStyle().Foreground(COLOR_Warning).Render(m.spinner.View() + " [publishing]")
+				} else if m.retrying[res.ID] > 0 {
+					status = lipgloss.NewStyle().Foreground(COLOR_Warning).Render("[retrying]")
+				} else if binding != nil && m.failed[res.ID] {
+					status = missingStyle.Render("[failed]")
+				} else if binding != nil && m.missingFiles[res.ID] {
+					status = missingStyle.Render("[missing]")
 				} else if binding != nil {
-					if binding.AutoPublish {
+					if badge := m.snoozeBadge(res.ID); badge != "" {
+						status = badge
+					} else if binding.AutoPublish {
 						status = boundStyle.Render("[auto]")
 					} else {
 						status = boundStyle.Render("[bound]")
@@ -425,8 +649,29 @@ func (m Model) viewBindFilesTab(width, height int) string {
 				if res.IsManaged {
 					managedTag = dimStyle.Render("[managed] ")
 				}
+				mark := "  "
+				if m.marked[res.ID] {
+					mark = markedStyle.Render("● ")
+				}
 
-				line = fmt.Sprintf("%s  %s %s%s", indent, node.Name, managedTag, status)
+				// Shorten the name so the badges stay on the line
+				var name string
+				if flat {
+					name = ellipsizeMiddle(node.Name, nameWidth)
+					// Pad by display width; "…" is wider in bytes than on screen
+					padded := name + strings.Repeat(" ", max(nameWidth-lipgloss.Width(name), 0))
+					line = fmt.Sprintf("%s%s %-4s %7d  %-10s %s%s", mark, padded, res.Type, res.Version, formatShortAge(res.ModifiedOn), managedTag, status)
+				} else {
+					deployed := dimStyle.Render(" " + deployedInfo(res))
+					name = ellipsizeMiddle(node.Name, lineWidth-len(indent)-3-lipgloss.Width(managedTag)-lipgloss.Width(status)-lipgloss.Width(deployed))
+					line = fmt.Sprintf("%s%s%s %s%s%s", indent, mark, name, managedTag, status, deployed)
+				}
+				if name != node.Name && i == m.resourceSelected {
+					selectedFull = res.Name
+				}
+				if i == m.resourceSelected && res.ModifiedByName != "" && !res.ModifiedOn.IsZero() {
+					modifiedBy = fmt.Sprintf("changed by %s %s", res.ModifiedByName, formatAge(res.ModifiedOn))
+				}
 			}
 
 			if i == m.resourceSelected {
@@ -437,8 +682,18 @@ func (m Model) viewBindFilesTab(width, height int) string {
 			resourceContent.WriteString("\n")
 		}
 
+		var footer []string
 		if len(m.displayItems) > visibleLines {
-			resourceContent.WriteString(dimStyle.Render(fmt.Sprintf("\n[%d/%d]", m.resourceSelected+1, len(m.displayItems))))
+			footer = append(footer, fmt.Sprintf("[%d/%d]", m.resourceSelected+1, len(m.displayItems)))
+		}
+		if selectedFull != "" {
+			footer = append(footer, selectedFull)
+		}
+		if modifiedBy != "" {
+			footer = append(footer, modifiedBy)
+		}
+		if len(footer) > 0 {
+			resourceContent.WriteString(dimStyle.Render("\n" + ellipsizeMiddle(strings.Join(footer, "  "), lineWidth)))
 		}
 	}
 
@@ -449,7 +704,7 @@ func (m Model) viewFileListTab(width, height int) string {
 	var listContent strings.Builder
 
 	// Get all bindings for current environment
-	bindings := m.config.GetBindingsForEnvironment(m.config.CurrentEnvironment)
+	bindings := m.fileListBindings()
 	env := m.config.GetEnvironment(m.config.CurrentEnvironment)
 
 	tokenLabel := "[t] Choose token root + write token.json"
@@ -459,9 +714,14 @@ func (m Model) viewFileListTab(width, height int) string {
 	listContent.WriteString(tokenActionStyle.Render(" " + tokenLabel + " "))
 	listContent.WriteString("\n\n")
 
-	if len(bindings) == 0 {
-		listContent.WriteString(dimStyle.Render("No bound files\n"))
-		listContent.WriteString(dimStyle.Render("Switch to 'Bind Files' tab to bind web resources"))
+	if len(bindings) == 0 && m.bindingFilter != "" {
+		listContent.WriteString(dimStyle.Render(fmt.Sprintf("No bindings match %q; press '/' to change the filter", m.bindingFilter)))
+	} else if len(bindings) == 0 {
+		envName := m.config.CurrentEnvironment
+		listContent.WriteString(dimStyle.Render(fmt.Sprintf("No files bound yet in %s\n\n", envName)))
+		listContent.WriteString(dimStyle.Render("Press 'tab' to switch to the Bind Files tab, select a web resource\n"))
+		listContent.WriteString(dimStyle.Render("and press 'b' to bind it to a local file. Press 'N' to create new\n"))
+		listContent.WriteString(dimStyle.Render("web resources from local files."))
 	} else {
 		// Calculate visible range for scrolling based on actual available height
 		// Each binding takes 2 lines (name + path), plus 1 line spacing = 3 lines per item
@@ -481,25 +741,57 @@ func (m Model) viewFileListTab(width, height int) string {
 			end = len(bindings)
 		}
 
+		// Room for a line inside the box after the "> " selection marker
+		lineWidth := width - contentBoxStyle.GetHorizontalPadding() - 2
+		var selectedFull string
+
 		for i := start; i < end; i++ {
 			binding := bindings[i]
 
-			// Build the line
-			var line strings.Builder
-			line.WriteString(binding.WebResourceName)
-			line.WriteString("\n  ")
-			line.WriteString(dimStyle.Render("→ " + binding.LocalPath))
-			line.WriteString("  ")
-
 			// Status indicators
 			var status string
 			if m.publishing[binding.WebResourceID] {
 				status = lipgloss.NewStyle().Foreground(COLOR_Warning).Render(m.spinner.View() + " [publishing]")
+			} else if m.retrying[binding.WebResourceID] > 0 {
+				status = lipgloss.NewStyle().Foreground(COLOR_Warning).Render("[retrying]")
+			} else if m.failed[binding.WebResourceID] {
+				status = missingStyle.Render("[failed]")
+			} else if m.missingFiles[binding.WebResourceID] {
+				status = missingStyle.Render("[missing]")
+			} else if badge := m.snoozeBadge(binding.WebResourceID); badge != "" {
+				status = badge
 			} else if binding.AutoPublish {
 				status = boundStyle.Render("[auto]")
 			} else {
 				status = boundStyle.Render("[bound]")
 			}
+
+			// Shorten the name and path so the badge stays on the line
+			mark := ""
+			if m.marked[binding.WebResourceID] {
+				mark = markedStyle.Render("● ")
+			}
+			name := ellipsizeMiddle(binding.WebResourceName, lineWidth-lipgloss.Width(mark))
+			shown := binding.LocalPath
+			if len(binding.LocalPaths) > 1 {
+				shown += fmt.Sprintf(" +%d", len(binding.LocalPaths)-1)
+			}
+			path := ellipsizeMiddle(shown, lineWidth-6-lipgloss.Width(status))
+			if i == m.bindingSelected && (name != binding.WebResourceName || path != shown) {
+				selectedFull = binding.LocalPath
+			}
+
+			// Build the line
+			var line strings.Builder
+			line.WriteString(mark)
+			line.WriteString(name)
+			if binding.Label != "" {
+				line.WriteString("  ")
+				line.WriteString(labelStyle.Render(ellipsizeMiddle(binding.Label, lineWidth-lipgloss.Width(name)-2)))
+			}
+			line.WriteString("\n  ")
+			line.WriteString(dimStyle.Render("→ " + path))
+			line.WriteString("  ")
 			line.WriteString(status)
 
 			lineStr := line.String()
@@ -514,8 +806,15 @@ func (m Model) viewFileListTab(width, height int) string {
 			}
 		}
 
+		var footer []string
 		if len(bindings) > visibleItems {
-			listContent.WriteString(dimStyle.Render(fmt.Sprintf("\n\n[%d/%d]", m.bindingSelected+1, len(bindings))))
+			footer = append(footer, fmt.Sprintf("[%d/%d]", m.bindingSelected+1, len(bindings)))
+		}
+		if selectedFull != "" {
+			footer = append(footer, selectedFull)
+		}
+		if len(footer) > 0 {
+			listContent.WriteString(dimStyle.Render("\n\n" + ellipsizeMiddle(strings.Join(footer, "  "), lineWidth)))
 		}
 	}
 
@@ -530,12 +829,19 @@ func (m Model) viewBinding() string {
 
 	// Binding content
 	var bindContent strings.Builder
-	if m.resourceSelected < len(m.resources) {
-		res := m.resources[m.resourceSelected]
+	if res := m.bindingResource; res != nil {
 		bindContent.WriteString(fmt.Sprintf("Resource: %s\n\n", res.Name))
 	}
 	bindContent.WriteString("Local file path:\n")
 	bindContent.WriteString(m.textInput.View())
+	if strings.TrimSpace(m.textInput.Value()) != "" {
+		bindContent.WriteString("\n")
+		if m.bindPathErr != nil {
+			bindContent.WriteString(lipgloss.NewStyle().Foreground(COLOR_Error).Render("✗ " + m.bindPathErr.Error()))
+		} else {
+			bindContent.WriteString(boundStyle.Render("✓ readable file"))
+		}
+	}
 
 	bindBox := contentBoxStyle.Width(availableWidth).Render(bindContent.String())
 	helpRendered := helpStyle.Width(availableWidth).Render("enter: confirm • esc: cancel")
@@ -553,11 +859,24 @@ func (m Model) viewFilePicker() string {
 	switch m.state {
 	case StateFilePicker:
 		title = "Select Local File"
-		helpText = "↑/↓: navigate • enter: select • esc: cancel"
+		helpText = "↑/↓: navigate • enter: select • f: show all files • esc: cancel"
+		if m.pickerShowAll {
+			helpText = "↑/↓: navigate • enter: select • f: matching files only • esc: cancel"
+		}
 		if m.bindingResource != nil {
 			b.WriteString(titleStyle.Render(title))
 			b.WriteString("\n\n")
-			b.WriteString(fmt.Sprintf("Binding: %s\n\n", m.bindingResource.Name))
+			if m.repoint {
+				b.WriteString(fmt.Sprintf("Re-pointing: %s\n", m.bindingResource.Name))
+			} else {
+				b.WriteString(fmt.Sprintf("Binding: %s\n", m.bindingResource.Name))
+			}
+			if len(m.filepicker.AllowedTypes) > 0 {
+				b.WriteString(dimStyle.Render("Showing: " + strings.Join(m.filepicker.AllowedTypes, ", ")))
+			} else {
+				b.WriteString(dimStyle.Render("Showing: all files"))
+			}
+			b.WriteString("\n\n")
 		}
 	case StateTokenExportPicker:
 		title = "Select Token Export Root"
@@ -598,6 +917,9 @@ func (m Model) viewSolutionPicker() string {
 
 	// Title
 	title := titleStyle.Render("Add to Solution")
+	if m.pickingFilter {
+		title = titleStyle.Render("Show the Web Resources of a Solution")
+	}
 
 	// Resource being added
 	var resourceInfo string
@@ -626,9 +948,13 @@ func (m Model) viewSolutionPicker() string {
 			end = len(m.solutions)
 		}
 
+		env := m.config.GetEnvironment(m.config.CurrentEnvironment)
 		for i := start; i < end; i++ {
 			solution := m.solutions[i]
 			line := fmt.Sprintf("%s (%s)", solution.FriendlyName, solution.Version)
+			if env != nil && solution.UniqueName == env.DefaultSolution {
+				line += dimStyle.Render(" default")
+			}
 
 			if i == m.solutionSelected {
 				solutionContent.WriteString(selectedStyle.Render("> " + line))
@@ -644,11 +970,164 @@ func (m Model) viewSolutionPicker() string {
 	}
 
 	solutionBox := contentBoxStyle.Width(availableWidth).Render(solutionContent.String())
-	helpRendered := helpStyle.Width(availableWidth).Render("↑/↓: navigate • enter: select • esc: cancel")
+	helpRendered := helpStyle.Width(availableWidth).Render(m.helpLine(StateSolutionPicker))
 
 	return lipgloss.JoinVertical(lipgloss.Left, title, resourceInfo, "", solutionBox, helpRendered)
 }
 
+func (m Model) viewDiff() string {
+	availableWidth := m.width - 12 // Main border (4) + content padding (8)
+
+	title := titleStyle.Render("Changes to Publish")
+
+	var resourceInfo string
+	if m.diffResource != nil {
+		info := fmt.Sprintf("Resource: %s (server → local)", m.diffResource.Name)
+		if m.diffReplaced > 0 && !m.diffLoading {
+			info += fmt.Sprintf(" • %d tokens replaced", m.diffReplaced)
+		}
+		resourceInfo = dimStyle.Render(info)
+	}
+	if m.diffWarning != "" && !m.diffLoading {
+		resourceInfo += "\n" + missingStyle.Render("Warning: "+m.diffWarning)
+	}
+
+	var content strings.Builder
+	lineWidth := availableWidth - contentBoxStyle.GetHorizontalPadding() - 2
+	switch {
+	case m.diffLoading:
+		content.WriteString(m.spinner.View() + " Downloading server content...")
+	case m.diffErr != nil:
+		content.WriteString(lipgloss.NewStyle().Foreground(COLOR_Error).Render(fmt.Sprintf("Could not compare: %v", m.diffErr)))
+	case !m.diffChanged:
+		content.WriteString(dimStyle.Render("No differences: the local file matches the server"))
+	case m.diffBinary:
+		content.WriteString("Binary content differs from the server")
+	default:
+		added := lipgloss.NewStyle().Foreground(COLOR_Success)
+		removed := lipgloss.NewStyle().Foreground(COLOR_Error)
+		end := min(len(m.diffLines), m.diffScroll+m.diffPageSize())
+		for _, line := range m.diffLines[m.diffScroll:end] {
+			text := ellipsizeMiddle(strings.ReplaceAll(line.Text, "\t", "    "), lineWidth-2)
+			switch line.Op {
+			case diff.Insert:
+				content.WriteString(added.Render("+ " + text))
+			case diff.Delete:
+				content.WriteString(removed.Render("- " + text))
+			case diff.Skip:
+				content.WriteString(dimStyle.Render("  ⋯"))
+			default:
+				content.WriteString(dimStyle.Render("  " + text))
+			}
+			content.WriteString("\n")
+		}
+		if len(m.diffLines) > m.diffPageSize() {
+			content.WriteString(dimStyle.Render(fmt.Sprintf("[%d-%d/%d]", m.diffScroll+1, end, len(m.diffLines))))
+		}
+	}
+
+	box := contentBoxStyle.Width(availableWidth).Render(content.String())
+	helpRendered := helpStyle.Width(availableWidth).Render(m.helpLine(StateDiff))
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, resourceInfo, "", box, helpRendered)
+}
+
+func (m Model) viewHistory() string {
+	availableWidth := m.width - 12 // Main border (4) + content padding (8)
+
+	title := titleStyle.Render("Publish History")
+	info := dimStyle.Render(publisher.HistoryPath())
+
+	var content strings.Builder
+	visibleLines := max(5, m.height-16)
+	start := 0
+	if m.historySelected >= visibleLines {
+		start = m.historySelected - visibleLines + 1
+	}
+	end := min(start+visibleLines, len(m.history))
+
+	for i := start; i < end; i++ {
+		entry := m.history[i]
+		outcome := boundStyle.Render("✓")
+		version := entry.NewVersion
+		if entry.OldVersion != "" && entry.NewVersion != "" {
+			version = entry.OldVersion + " → " + entry.NewVersion
+		}
+		if entry.Error != "" {
+			outcome = lipgloss.NewStyle().Foreground(COLOR_Error).Render("✗")
+			version = "failed"
+		}
+		line := fmt.Sprintf("%s %s  %-12s %s %s", outcome, entry.Time.Format("2006-01-02 15:04:05"), entry.Environment, entry.Resource, dimStyle.Render(version))
+		if i == m.historySelected {
+			content.WriteString(selectedStyle.Render("> " + line))
+		} else {
+			content.WriteString(normalStyle.Render("  " + line))
+		}
+		content.WriteString("\n")
+	}
+	if len(m.history) > visibleLines {
+		content.WriteString(dimStyle.Render(fmt.Sprintf("\n[%d/%d]", m.historySelected+1, len(m.history))))
+	}
+
+	// Details of the selected entry
+	if m.historySelected < len(m.history) {
+		entry := m.history[m.historySelected]
+		content.WriteString("\n")
+		if entry.LocalPath != "" {
+			content.WriteString(dimStyle.Render("File: "+entry.LocalPath) + "\n")
+		}
+		if entry.Error != "" {
+			content.WriteString(lipgloss.NewStyle().Foreground(COLOR_Error).Width(availableWidth - 4).Render("Error: " + entry.Error))
+		}
+	}
+
+	box := contentBoxStyle.Width(availableWidth).Render(content.String())
+	helpRendered := helpStyle.Width(availableWidth).Render(m.helpLine(StateHistory))
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, info, "", box, helpRendered)
+}
+
+func (m Model) viewSnapshotPicker() string {
+	availableWidth := m.width - 12 // Main border (4) + content padding (8)
+
+	title := titleStyle.Render("Restore Snapshot")
+
+	var resourceInfo string
+	if m.snapshotBinding != nil {
+		resourceInfo = dimStyle.Render(fmt.Sprintf("Resource: %s (%s)", m.snapshotBinding.WebResourceName, m.snapshotBinding.Environment))
+	}
+
+	var content strings.Builder
+	visibleLines := 10
+	start := 0
+	if m.snapshotSelected >= visibleLines {
+		start = m.snapshotSelected - visibleLines + 1
+	}
+	end := min(start+visibleLines, len(m.snapshots))
+
+	for i := start; i < end; i++ {
+		snapshot := m.snapshots[i]
+		line := fmt.Sprintf("%s  %s", snapshot.Time.Format("2006-01-02 15:04:05"), dimStyle.Render(filepath.Base(snapshot.Path)))
+		if i == m.snapshotSelected {
+			content.WriteString(selectedStyle.Render("> " + line))
+		} else {
+			content.WriteString(normalStyle.Render("  " + line))
+		}
+		content.WriteString("\n")
+	}
+	if len(m.snapshots) > visibleLines {
+		content.WriteString(dimStyle.Render(fmt.Sprintf("\n[%d/%d]", m.snapshotSelected+1, len(m.snapshots))))
+	}
+	if m.inputMode == InputRestoreConfirm {
+		content.WriteString("\nOverwrite the server content with this snapshot and publish? (y/n): " + m.textInput.View())
+	}
+
+	box := contentBoxStyle.Width(availableWidth).Render(content.String())
+	helpRendered := helpStyle.Width(availableWidth).Render(m.helpLine(StateSnapshotPicker))
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, resourceInfo, "", box, helpRendered)
+}
+
 func (m Model) viewCreateModeSelect() string {
 	availableWidth := m.width - 12
 
@@ -676,7 +1155,7 @@ func (m Model) viewCreateModeSelect() string {
 	}
 
 	modeBox := contentBoxStyle.Width(availableWidth).Render(modeContent.String())
-	helpRendered := helpStyle.Width(availableWidth).Render("↑/↓: navigate • enter: select • esc: cancel")
+	helpRendered := helpStyle.Width(availableWidth).Render(m.helpLine(StateCreateModeSelect))
 
 	return lipgloss.JoinVertical(lipgloss.Left, title, solutionInfo, "", modeBox, helpRendered)
 }
@@ -698,7 +1177,7 @@ func (m Model) viewCreateNameInput() string {
 	inputContent.WriteString("Enter web resource name:\n\n")
 	inputContent.WriteString(m.textInput.View())
 	inputContent.WriteString("\n\n")
-	inputContent.WriteString(dimStyle.Render("Example: publisher_/folder/filename.js"))
+	inputContent.WriteString(dimStyle.Render(fmt.Sprintf("Example: %s_/folder/filename.js", m.publisherPrefix())))
 
 	inputBox := contentBoxStyle.Width(availableWidth).Render(inputContent.String())
 	helpRendered := helpStyle.Width(availableWidth).Render("enter: confirm • esc: back")
@@ -720,7 +1199,7 @@ func (m Model) viewCreatePrefixInput() string {
 	inputContent.WriteString("Enter prefix for web resource names:\n\n")
 	inputContent.WriteString(m.textInput.View())
 	inputContent.WriteString("\n\n")
-	inputContent.WriteString(dimStyle.Render("Example: publisher_/AppName/"))
+	inputContent.WriteString(dimStyle.Render(fmt.Sprintf("Example: %s_/AppName/", m.publisherPrefix())))
 	inputContent.WriteString("\n")
 	inputContent.WriteString(dimStyle.Render("Files will be named: <prefix><relative_path>"))
 
@@ -765,6 +1244,14 @@ func (m Model) viewCreateConfirm() string {
 		for i := start; i < end; i++ {
 			file := m.createFiles[i]
 			line := file.WebResName
+			if i == m.createFileSelected && m.inputMode == InputDisplayName {
+				line += "  display name: " + m.textInput.View()
+			} else {
+				line += "  " + dimStyle.Render(file.displayName())
+			}
+			if file.Warning != "" {
+				line += "  " + lipgloss.NewStyle().Foreground(COLOR_Warning).Render("⚠ "+file.Warning)
+			}
 
 			if i == m.createFileSelected {
 				fileContent.WriteString(selectedStyle.Render("> " + line))
@@ -785,12 +1272,386 @@ func (m Model) viewCreateConfirm() string {
 	if m.creatingResources {
 		helpRendered = helpStyle.Width(availableWidth).Render("Please wait...")
 	} else if m.createMode == CreateModeFolder && len(m.createFiles) > 1 {
-		helpRendered = helpStyle.Width(availableWidth).Render("↑/↓: scroll • d: remove • r: reset list • enter/y: create • esc: back")
+		helpRendered = helpStyle.Width(availableWidth).Render("↑/↓: scroll • n: display name • d: remove • r: reset list • enter/y: create • esc: back • ?: help")
 	} else if m.createMode == CreateModeFolder {
-		helpRendered = helpStyle.Width(availableWidth).Render("↑/↓: scroll • r: reset list • enter/y: create • esc: back")
+		helpRendered = helpStyle.Width(availableWidth).Render("↑/↓: scroll • n: display name • r: reset list • enter/y: create • esc: back • ?: help")
 	} else {
-		helpRendered = helpStyle.Width(availableWidth).Render("↑/↓: scroll • enter/y: create • esc: back")
+		helpRendered = helpStyle.Width(availableWidth).Render("↑/↓: scroll • n: display name • enter/y: create • esc: back • ?: help")
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, title, solutionInfo, "", fileBox, helpRendered)
 }
+
+func (m Model) viewSyncScan() string {
+	availableWidth := m.width - 12
+
+	// Title
+	title := titleStyle.Render("Sync Status")
+
+	var scanContent strings.Builder
+
+	if m.syncScanning {
+		scanContent.WriteString(m.spinner.View())
+		scanContent.WriteString(fmt.Sprintf(" Checking %d/%d...", len(m.syncResults)+1, len(m.syncBindings)))
+		if len(m.syncResults) < len(m.syncBindings) {
+			scanContent.WriteString("\n")
+			scanContent.WriteString(dimStyle.Render(m.syncBindings[len(m.syncResults)].WebResourceName))
+		}
+		scanContent.WriteString("\n\n")
+	} else if m.syncCancelled {
+		scanContent.WriteString(lipgloss.NewStyle().Foreground(COLOR_Warning).Render(
+			fmt.Sprintf("Cancelled after %d/%d", len(m.syncResults), len(m.syncBindings))))
+		scanContent.WriteString("\n\n")
+	}
+
+	// Summary counts per category
+	statuses := []publisher.SyncStatus{publisher.SyncInSync, publisher.SyncLocalAhead, publisher.SyncConflict, publisher.SyncMissing, publisher.SyncError}
+	counts := make(map[publisher.SyncStatus]int)
+	for _, r := range m.syncResults {
+		counts[r.Status]++
+	}
+	var summary []string
+	for _, st := range statuses {
+		summary = append(summary, syncStatusStyle(st).Render(fmt.Sprintf("%s: %d", st, counts[st])))
+	}
+	scanContent.WriteString(strings.Join(summary, "  "))
+	scanContent.WriteString("\n\n")
+
+	// Results grouped by category
+	results := m.sortedSyncResults()
+	visibleLines := 10
+	start := 0
+	if m.syncSelected >= visibleLines {
+		start = m.syncSelected - visibleLines + 1
+	}
+	end := min(start+visibleLines, len(results))
+
+	for i := start; i < end; i++ {
+		r := results[i]
+		line := fmt.Sprintf("%s %s", syncStatusStyle(r.Status).Render("["+r.Status.String()+"]"), r.Binding.WebResourceName)
+		if r.Audit != nil {
+			line += " " + dimStyle.Render(formatAudit(r.Audit))
+		}
+		if r.Err != nil {
+			line += " " + dimStyle.Render(r.Err.Error())
+		}
+		if i == m.syncSelected {
+			scanContent.WriteString(selectedStyle.Render("> " + line))
+		} else {
+			scanContent.WriteString(normalStyle.Render("  " + line))
+		}
+		scanContent.WriteString("\n")
+	}
+
+	if len(results) > visibleLines {
+		scanContent.WriteString(dimStyle.Render(fmt.Sprintf("\n[%d/%d]", m.syncSelected+1, len(results))))
+	}
+
+	scanBox := contentBoxStyle.Width(availableWidth).Render(scanContent.String())
+
+	helpText := "↑/↓: scroll • esc: back • ?: help"
+	if m.syncScanning {
+		helpText = "esc: cancel scan • ?: help"
+	}
+	helpRendered := helpStyle.Width(availableWidth).Render(helpText)
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, scanBox, helpRendered)
+}
+
+// envHealthStyle returns the colour used for an environment health label
+func envHealthStyle(health EnvHealth) lipgloss.Style {
+	switch health {
+	case HealthReady:
+		return boundStyle
+	case HealthChecking:
+		return dimStyle
+	case HealthNoToken, HealthExpired:
+		return lipgloss.NewStyle().Foreground(COLOR_Warning)
+	default:
+		return lipgloss.NewStyle().Foreground(COLOR_Error)
+	}
+}
+
+// syncStatusStyle returns the colour used for a sync status label
+func syncStatusStyle(status publisher.SyncStatus) lipgloss.Style {
+	switch status {
+	case publisher.SyncInSync:
+		return boundStyle
+	case publisher.SyncLocalAhead:
+		return lipgloss.NewStyle().Foreground(COLOR_Secondary)
+	case publisher.SyncConflict:
+		return lipgloss.NewStyle().Foreground(COLOR_Warning)
+	default:
+		return lipgloss.NewStyle().Foreground(COLOR_Error)
+	}
+}
+
+func (m Model) viewResourceDetails() string {
+	availableWidth := m.width - 12
+
+	// Title
+	title := titleStyle.Render("Web Resource Details")
+
+	var detailsContent strings.Builder
+	if res := m.detailsResource; res != nil {
+		if m.inputMode == InputResourceName {
+			detailsContent.WriteString("Name:     " + m.textInput.View() + "\n")
+		} else {
+			detailsContent.WriteString(fmt.Sprintf("Name:     %s\n", res.Name))
+		}
+		if m.inputMode == InputDisplayName {
+			detailsContent.WriteString("Display:  " + m.textInput.View() + "\n")
+		} else if res.DisplayName != "" {
+			detailsContent.WriteString(fmt.Sprintf("Display:  %s\n", res.DisplayName))
+		} else {
+			detailsContent.WriteString("Display:  " + dimStyle.Render("not set") + "\n")
+		}
+		detailsContent.WriteString(fmt.Sprintf("ID:       %s\n", res.ID))
+		detailsContent.WriteString(fmt.Sprintf("Version:  %d\n", res.Version))
+		if res.IsManaged {
+			detailsContent.WriteString("Managed:  yes\n")
+		} else {
+			detailsContent.WriteString("Managed:  no\n")
+		}
+
+		if binding := m.config.GetBinding(m.config.CurrentEnvironment, res.ID); binding != nil {
+			detailsContent.WriteString(fmt.Sprintf("Bound to: %s\n", binding.LocalPath))
+			for _, source := range binding.LocalPaths[min(1, len(binding.LocalPaths)):] {
+				detailsContent.WriteString(fmt.Sprintf("        + %s\n", source))
+			}
+			detailsContent.WriteString(fmt.Sprintf("Auto:     %t\n", binding.AutoPublish))
+			if names, ok := m.solutionsOf[res.ID]; ok && len(names) > 0 {
+				detailsContent.WriteString(fmt.Sprintf("Solution: %s\n", strings.Join(names, ", ")))
+			} else if ok {
+				detailsContent.WriteString("Solution: " + dimStyle.Render("none") + "\n")
+			} else if m.solutionsOfErr != nil {
+				detailsContent.WriteString("Solution: " + dimStyle.Render("lookup failed") + "\n")
+			}
+		} else {
+			detailsContent.WriteString("Bound to: " + dimStyle.Render("not bound") + "\n")
+		}
+
+		detailsContent.WriteString("\n")
+		switch {
+		case m.detailsLoading:
+			detailsContent.WriteString(m.spinner.View())
+			detailsContent.WriteString(" Loading modification info...")
+		case m.detailsErr != nil:
+			detailsContent.WriteString(lipgloss.NewStyle().Foreground(COLOR_Error).Render(
+				fmt.Sprintf("Failed to load modification info: %v", m.detailsErr)))
+		case m.detailsAudit != nil:
+			detailsContent.WriteString(fmt.Sprintf("Modified: %s\n", formatAudit(m.detailsAudit)))
+			detailsContent.WriteString(dimStyle.Render(m.detailsAudit.ModifiedOn.Local().Format("2006-01-02 15:04:05")))
+		}
+	}
+
+	detailsBox := contentBoxStyle.Width(availableWidth).Render(detailsContent.String())
+	if m.inputMode == InputProductionConfirm && m.confirmKey != nil {
+		detailsBox = lipgloss.JoinVertical(lipgloss.Left, detailsBox, m.viewProductionConfirm(availableWidth))
+	}
+	help := m.helpLine(StateResourceDetails)
+	if m.inputMode == InputDisplayName || m.inputMode == InputResourceName {
+		help = "enter: save • esc: cancel"
+	}
+	helpRendered := helpStyle.Width(availableWidth).Render(help)
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, detailsBox, helpRendered)
+}
+
+// viewProductionConfirm renders the question held back publishes wait on in
+// an environment with requireConfirm set
+func (m Model) viewProductionConfirm(width int) string {
+	warning := dimStyle.Render(fmt.Sprintf("%s requires confirmation before every publish.", m.config.CurrentEnvironment))
+	return lipgloss.JoinVertical(lipgloss.Left,
+		productionStyle.Render(fmt.Sprintf("Publish to production environment %s?", m.config.CurrentEnvironment))+" (y/n): "+m.textInput.View(),
+		lipgloss.NewStyle().Width(width).Render(warning))
+}
+
+// formatAudit renders who modified a resource and how long ago
+func formatAudit(audit *d365.WebResourceAudit) string {
+	who := audit.ModifiedBy.FullName
+	if who == "" {
+		who = "Someone"
+	}
+	if audit.ModifiedOn.IsZero() {
+		return who + " changed this"
+	}
+	return fmt.Sprintf("%s changed this %s", who, formatAge(audit.ModifiedOn))
+}
+
+// formatAge renders a time as a short relative duration such as "10 minutes ago"
+func formatAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return pluralize(int(d.Minutes()), "minute") + " ago"
+	case d < 24*time.Hour:
+		return pluralize(int(d.Hours()), "hour") + " ago"
+	default:
+		return pluralize(int(d.Hours()/24), "day") + " ago"
+	}
+}
+
+// deployedInfo renders the server version and last change of a resource,
+// e.g. "v12345 • 2d ago"
+func deployedInfo(res *d365.WebResource) string {
+	info := fmt.Sprintf("v%d", res.Version)
+	if !res.ModifiedOn.IsZero() {
+		info += " • " + formatShortAge(res.ModifiedOn)
+	}
+	return info
+}
+
+// formatShortAge renders a time as a compact relative duration such as "2d
+// ago", falling back to the date for anything older than a month
+func formatShortAge(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	case t.Year() == time.Now().Year():
+		return t.Local().Format("Jan 2")
+	default:
+		return t.Local().Format("2006-01-02")
+	}
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func (m Model) viewErrorDetails() string {
+	availableWidth := m.width - 12
+
+	// Title
+	title := titleStyle.Render("Error Details")
+
+	// Wrap long lines so nothing is cut off, then scroll
+	wrapped := lipgloss.NewStyle().Width(availableWidth - 4).Render(errorDetailsText(m.err))
+	lines := strings.Split(wrapped, "\n")
+
+	visibleLines := max(m.height-14, 5)
+	start := min(m.errorScroll, max(len(lines)-visibleLines, 0))
+	end := min(start+visibleLines, len(lines))
+
+	content := strings.Join(lines[start:end], "\n")
+	if len(lines) > visibleLines {
+		content += dimStyle.Render(fmt.Sprintf("\n\n[lines %d-%d of %d]", start+1, end, len(lines)))
+	}
+
+	errorBox := contentBoxStyle.Width(availableWidth).Render(content)
+	helpRendered := helpStyle.Width(availableWidth).Render(m.helpLine(StateErrorDetails))
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, errorBox, helpRendered)
+}
+
+// errorDetailsText formats an error with any API request details for display and copying
+func errorDetailsText(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Error: ")
+	b.WriteString(err.Error())
+
+	var apiErr *d365.APIError
+	if errors.As(err, &apiErr) {
+		b.WriteString("\n\nRequest: ")
+		b.WriteString(apiErr.Method + " " + apiErr.Path)
+		b.WriteString(fmt.Sprintf("\nStatus:  %d", apiErr.StatusCode))
+		if apiErr.ODataCode != "" {
+			b.WriteString("\nCode:    " + apiErr.ODataCode)
+		}
+		b.WriteString("\n\nResponse body:\n")
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, []byte(apiErr.Body), "", "  ") == nil {
+			b.WriteString(pretty.String())
+		} else {
+			b.WriteString(apiErr.Body)
+		}
+	}
+
+	return b.String()
+}
+
+// snoozeBadge renders the snooze state of a binding with its countdown, or
+// an empty string when auto-publish is not snoozed
+func (m Model) snoozeBadge(resourceID string) string {
+	remaining, ok := m.snoozeRemaining(resourceID)
+	if !ok {
+		return ""
+	}
+	style := lipgloss.NewStyle().Foreground(COLOR_Warning)
+	if remaining == 0 {
+		return style.Render("[snoozed]")
+	}
+	return style.Render(fmt.Sprintf("[snoozed %s]", remaining.Round(time.Second)))
+}
+
+// ellipsizeMiddle shortens s to at most width cells by replacing its middle
+// with an ellipsis, keeping the start and the more specific end of a path
+func ellipsizeMiddle(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return "…"
+	}
+	runes := []rune(s)
+	keep := width - 1
+	if len(runes) <= keep {
+		return s
+	}
+	head := keep / 2
+	tail := keep - head
+	return string(runes[:head]) + "…" + string(runes[len(runes)-tail:])
+}
+
+// readOnlyBadge marks the header when the app was started with --read-only
+var readOnlyBadge = lipgloss.NewStyle().Foreground(COLOR_Warning).Bold(true).Render(" [read-only]")
+
+// markedStyle flags resources marked for the bulk actions
+var markedStyle = lipgloss.NewStyle().Foreground(COLOR_Secondary).Bold(true)
+
+// productionStyle marks environments that require confirmation before publishing
+var productionStyle = lipgloss.NewStyle().Foreground(COLOR_Error).Bold(true)
+
+// sessionLine renders who is signed in and the time left on the access
+// token, red inside the refresh buffer used by Token.IsExpired
+func (m Model) sessionLine() string {
+	var parts []string
+	if m.connectedAs != "" {
+		parts = append(parts, dimStyle.Render("Signed in as ")+m.connectedAs)
+	}
+	if m.token != nil {
+		remaining := time.Until(m.token.ExpiresAt)
+		switch {
+		case remaining <= 0:
+			parts = append(parts, errorStyle.Render("token expired"))
+		case m.token.IsExpired():
+			label := fmt.Sprintf("token expires in %s", remaining.Round(time.Second))
+			if m.tokenRefreshing {
+				label += " (refreshing)"
+			}
+			parts = append(parts, errorStyle.Render(label))
+		default:
+			parts = append(parts, dimStyle.Render(fmt.Sprintf("token expires in %dm", int(remaining.Minutes()))))
+		}
+	}
+	return strings.Join(parts, dimStyle.Render(" • "))
+}
@@ -0,0 +1,29 @@
+package watcher
+
+import "syscall"
+
+// networkFSTypes are the file systems that don't deliver kqueue events for changes made elsewhere
+var networkFSTypes = map[string]bool{
+	"nfs":     true,
+	"smbfs":   true,
+	"afpfs":   true,
+	"webdav":  true,
+	"macfuse": true, // e.g. sshfs
+	"osxfuse": true,
+}
+
+// onNetworkFS reports whether path is on a network or remote file system
+func onNetworkFS(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+	name := make([]byte, 0, len(st.Fstypename))
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return networkFSTypes[string(name)]
+}
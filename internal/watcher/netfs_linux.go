@@ -0,0 +1,23 @@
+package watcher
+
+import "syscall"
+
+// networkFSTypes are the statfs magic numbers of file systems that don't
+// deliver inotify events for changes made elsewhere
+var networkFSTypes = map[uint32]bool{
+	0x6969:     true, // NFS
+	0x517B:     true, // SMB
+	0xFF534D42: true, // CIFS
+	0xFE534D42: true, // SMB2
+	0x01021997: true, // 9P, e.g. WSL and some container mounts
+	0x65735546: true, // FUSE, e.g. sshfs and Docker Desktop file sharing
+}
+
+// onNetworkFS reports whether path is on a network or remote file system
+func onNetworkFS(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+	return networkFSTypes[uint32(st.Type)]
+}
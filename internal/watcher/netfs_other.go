@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package watcher
+
+// onNetworkFS reports whether path is on a network file system; it isn't
+// detected on this platform, so set the environment's watchMode to poll
+func onNetworkFS(path string) bool {
+	return false
+}
@@ -0,0 +1,30 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// driveRemote is the GetDriveType result for a network drive
+const driveRemote = 4
+
+var getDriveType = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDriveTypeW")
+
+// onNetworkFS reports whether path is on a UNC share or a mapped network drive
+func onNetworkFS(path string) bool {
+	volume := filepath.VolumeName(path)
+	if strings.HasPrefix(volume, `\\`) {
+		return true
+	}
+	if volume == "" {
+		return false
+	}
+	root, err := syscall.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+	kind, _, _ := getDriveType.Call(uintptr(unsafe.Pointer(root)))
+	return kind == driveRemote
+}
@@ -1,8 +1,15 @@
 package watcher
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -16,14 +23,60 @@ type FileChange struct {
 // Watcher manages file watching for auto-publish
 type Watcher struct {
 	watcher    *fsnotify.Watcher
-	files      map[string]bool      // tracks watched files
-	dirs       map[string][]string  // maps directories to files in them
+	files      map[string]bool                   // tracks watched files
+	dirs       map[string][]string               // maps directories to files in them
+	trees      map[string]func(path string) bool // watched directory trees and the files they report
+	treeDirs   map[string]bool                   // directories watched as part of a tree
+	skipDirs   map[string]func(dir string) bool  // tree root -> folders left out of the tree
 	onChange   func(path string)
-	debounce   map[string]time.Time
+	onError    func(err error)
+	pending    map[string]*time.Timer // trailing debounce timers per file
 	debounceMu sync.Mutex
 	debounceMs time.Duration
+	ops        fsnotify.Op // events that count as a change
 	stopChan   chan struct{}
 	mu         sync.Mutex
+	mode       Mode
+	// Files and trees that couldn't be watched because the system's watch
+	// limit was reached, or that live on a file system that doesn't deliver
+	// events, are polled instead
+	polled    map[string]bool
+	pollTrees map[string]func(path string) bool
+	stamps    map[string]fileStamp // last seen state of each polled file
+	pollOnce  sync.Once
+	limitSeen bool
+}
+
+// PollInterval is how often polled files are checked
+const PollInterval = 2 * time.Second
+
+// Mode chooses between file events and polling
+type Mode int
+
+const (
+	// ModeAuto uses file events, but polls files on network file systems
+	// such as NFS and SMB shares, which don't deliver events reliably
+	ModeAuto Mode = iota
+	// ModeEvents always uses file events
+	ModeEvents
+	// ModePoll checks every file for changes every PollInterval
+	ModePoll
+)
+
+// fileStamp is what polling compares to notice a change
+type fileStamp struct {
+	modTime int64
+	size    int64
+	exists  bool
+}
+
+// statStamp returns the current stamp of a file; a missing file has the zero stamp
+func statStamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size(), exists: true}
 }
 
 // New creates a new file watcher
@@ -37,10 +90,17 @@ func New(onChange func(path string)) (*Watcher, error) {
 		watcher:    fsWatcher,
 		files:      make(map[string]bool),
 		dirs:       make(map[string][]string),
+		trees:      make(map[string]func(path string) bool),
+		treeDirs:   make(map[string]bool),
+		skipDirs:   make(map[string]func(dir string) bool),
 		onChange:   onChange,
-		debounce:   make(map[string]time.Time),
+		pending:    make(map[string]*time.Timer),
 		debounceMs: 300 * time.Millisecond,
+		ops:        fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove,
 		stopChan:   make(chan struct{}),
+		polled:     make(map[string]bool),
+		pollTrees:  make(map[string]func(path string) bool),
+		stamps:     make(map[string]fileStamp),
 	}
 
 	go w.run()
@@ -57,61 +117,176 @@ func (w *Watcher) run() {
 			if !ok {
 				return
 			}
-			// Handle Write, Create, and Rename events (macOS editors often use atomic saves)
-			if event.Op&fsnotify.Write == fsnotify.Write ||
-				event.Op&fsnotify.Create == fsnotify.Create ||
-				event.Op&fsnotify.Rename == fsnotify.Rename {
-				// Check if this is a file we're watching
-				w.mu.Lock()
-				isWatched := w.files[event.Name]
-				w.mu.Unlock()
-				
-				if isWatched {
-					w.handleChange(event.Name)
-				}
+			// Editors that save atomically either rename the original away and
+			// create a new file (Rename/Remove of the old name, then Create) or
+			// write a temp file and rename it onto the target. The temp file
+			// isn't watched; the rename shows up as a Create of the watched
+			// name, often without any Write. By default all of these schedule
+			// a publish once the events settle.
+			path := filepath.Clean(event.Name)
+			if event.Op&fsnotify.Create != 0 {
+				w.watchNewDir(path)
+			}
+
+			w.mu.Lock()
+			isWatched := w.files[path] || w.inTree(path)
+			// A file appearing at a watched path was replaced, so it counts
+			// even when only writes do
+			counts := event.Op&w.ops != 0 || (isWatched && event.Op&fsnotify.Create != 0)
+			w.mu.Unlock()
+
+			if isWatched && counts {
+				w.handleChange(path)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Keep watching; the error only explains changes that were missed
+			w.mu.Lock()
+			onError := w.onError
+			w.mu.Unlock()
+			if onError != nil {
+				onError(describeError(err))
 			}
-		case <-w.watcher.Errors:
-			// Log error but continue
 		}
 	}
 }
 
-// handleChange processes a file change with debouncing
+// handleChange processes a file change with trailing debouncing, so a burst of
+// events from one save results in a single notification after the last event
 func (w *Watcher) handleChange(path string) {
 	w.debounceMu.Lock()
-	lastChange, exists := w.debounce[path]
-	now := time.Now()
+	defer w.debounceMu.Unlock()
 
-	if exists && now.Sub(lastChange) < w.debounceMs {
-		w.debounceMu.Unlock()
+	if timer, exists := w.pending[path]; exists {
+		timer.Reset(w.debounceMs)
 		return
 	}
 
-	w.debounce[path] = now
-	w.debounceMu.Unlock()
+	w.pending[path] = time.AfterFunc(w.debounceMs, func() {
+		w.debounceMu.Lock()
+		delete(w.pending, path)
+		w.debounceMu.Unlock()
+
+		// The file was renamed away or deleted and has not come back
+		if !readable(path) {
+			return
+		}
+
+		if w.onChange != nil {
+			w.onChange(path)
+		}
+	})
+}
+
+// readableAttempts and readableDelay bound how long readable waits for a file
+// to come back, e.g. when the rename of an atomic save lands after the debounce
+const (
+	readableAttempts = 5
+	readableDelay    = 50 * time.Millisecond
+)
+
+// readable reports whether path is a file that can be opened, retrying
+// briefly while it is missing or locked mid-save
+func readable(path string) bool {
+	for attempt := 0; attempt < readableAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(readableDelay)
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		f.Close()
+		return true
+	}
+	return false
+}
+
+// SetErrorHandler sets a function called with the errors the file system
+// reports while watching, e.g. when events were dropped
+func (w *Watcher) SetErrorHandler(onError func(err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = onError
+}
+
+// describeError explains what a watch error means for auto-publish
+func describeError(err error) error {
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		return fmt.Errorf("too many file events at once, so some saves may not have been published; save them again: %w", err)
+	}
+	return fmt.Errorf("watching files: %w", err)
+}
+
+// SetWriteOnly limits change notifications to in-place writes, ignoring the
+// rename and remove events produced by atomic saves. A file created at a
+// watched path still counts, as that is how a save that renames a temp file
+// onto the target shows up.
+func (w *Watcher) SetWriteOnly(writeOnly bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if writeOnly {
+		w.ops = fsnotify.Write
+	} else {
+		w.ops = fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove
+	}
+}
+
+// SetMode chooses how files added from now on are watched
+func (w *Watcher) SetMode(mode Mode) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mode = mode
+}
 
-	if w.onChange != nil {
-		w.onChange(path)
+// shouldPoll reports whether files in dir are polled instead of watched.
+// Callers hold w.mu.
+func (w *Watcher) shouldPoll(dir string) bool {
+	switch w.mode {
+	case ModePoll:
+		return true
+	case ModeEvents:
+		return false
 	}
+	return onNetworkFS(dir)
 }
 
-// AddFile starts watching a file by watching its parent directory
+// AddFile starts watching a file by watching its parent directory. Files
+// polled because of the watcher's mode are polled right away; if the
+// system's watch limit is reached, the file is polled as well and the error
+// handler is told why.
 func (w *Watcher) AddFile(path string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.files[path] {
+	if w.files[path] || w.polled[path] {
 		return nil
 	}
 
 	// Watch the parent directory instead of the file itself
 	// This is more reliable on macOS with editors that use atomic saves
 	dir := filepath.Dir(path)
+	if w.shouldPoll(dir) {
+		w.pollFile(path)
+		return nil
+	}
 
 	// Add directory to watcher if not already watched
 	if len(w.dirs[dir]) == 0 {
 		if err := w.watcher.Add(dir); err != nil {
-			return err
+			if !isWatchLimit(err) {
+				return err
+			}
+			w.pollFile(path)
+			w.reportWatchLimit(err)
+			return nil
 		}
 	}
 
@@ -121,36 +296,281 @@ func (w *Watcher) AddFile(path string) error {
 	return nil
 }
 
-// RemoveFile stops watching a file
+// RemoveFile stops watching a file. Its directory stays watched while other
+// files in it are tracked.
 func (w *Watcher) RemoveFile(path string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.polled[path] {
+		delete(w.polled, path)
+		delete(w.stamps, path)
+		w.cancelPending(path)
+		return nil
+	}
 	if !w.files[path] {
 		return nil
 	}
 
-	if err := w.watcher.Remove(path); err != nil {
-		return err
+	delete(w.files, path)
+	w.cancelPending(path)
+
+	dir := filepath.Dir(path)
+	remaining := w.dirs[dir][:0]
+	for _, p := range w.dirs[dir] {
+		if p != path {
+			remaining = append(remaining, p)
+		}
+	}
+	if len(remaining) > 0 {
+		w.dirs[dir] = remaining
+		return nil
 	}
 
-	delete(w.files, path)
+	delete(w.dirs, dir)
+	if w.treeDirs[dir] {
+		return nil
+	}
+	return w.watcher.Remove(dir)
+}
+
+// AddDir watches a directory and everything below it, reporting changes to
+// the files for which match returns true. Folders for which skip returns
+// true, such as node_modules, are left out with everything in them; skip may
+// be nil. Folders created later are watched as they appear. Like files,
+// trees are polled instead when the watcher's mode says so or the system's
+// watch limit is reached.
+func (w *Watcher) AddDir(root string, match func(path string) bool, skip func(dir string) bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if skip != nil {
+		w.skipDirs[root] = skip
+	}
+	if w.shouldPoll(root) {
+		w.pollTree(root, match)
+		return nil
+	}
+	w.trees[root] = match
+	err := w.addTree(root)
+	if err == nil || !isWatchLimit(err) {
+		return err
+	}
+	w.pollTree(root, match)
+	w.reportWatchLimit(err)
 	return nil
 }
 
+// pollFile polls a file instead of watching it. Callers hold w.mu.
+func (w *Watcher) pollFile(path string) {
+	w.polled[path] = true
+	w.stamps[path] = statStamp(path)
+	w.pollOnce.Do(func() { go w.poll() })
+}
+
+// pollTree polls a directory tree instead of watching it. Callers hold w.mu.
+func (w *Watcher) pollTree(root string, match func(path string) bool) {
+	w.pollTrees[root] = match
+	w.stampTree(root, match)
+	w.pollOnce.Do(func() { go w.poll() })
+}
+
+// isWatchLimit reports whether adding a watch failed because the system ran
+// out of them: inotify's max_user_watches on Linux, open files with kqueue
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
+
+// reportWatchLimit reports the watch limit once. Callers hold w.mu.
+func (w *Watcher) reportWatchLimit(err error) {
+	if w.limitSeen || w.onError == nil {
+		return
+	}
+	w.limitSeen = true
+	if runtime.GOOS == "linux" {
+		err = fmt.Errorf("inotify watch limit reached; increase fs.inotify.max_user_watches (e.g. sudo sysctl fs.inotify.max_user_watches=524288). Checking the remaining files every %s instead: %w", PollInterval, err)
+	} else {
+		err = fmt.Errorf("file watch limit reached; raise the open file limit (ulimit -n). Checking the remaining files every %s instead: %w", PollInterval, err)
+	}
+	go w.onError(err)
+}
+
+// poll checks the polled files and trees until the watcher is closed
+func (w *Watcher) poll() {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			for _, path := range w.pollChanges() {
+				w.handleChange(path)
+			}
+		}
+	}
+}
+
+// pollChanges returns the polled files that were written or created since
+// the last poll
+func (w *Watcher) pollChanges() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var changed []string
+	check := func(path string) {
+		stamp := statStamp(path)
+		if old, ok := w.stamps[path]; ok && old == stamp {
+			return
+		}
+		w.stamps[path] = stamp
+		if stamp.exists {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.polled {
+		check(path)
+	}
+	for root, match := range w.pollTrees {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() && w.skipped(path) {
+				return filepath.SkipDir
+			}
+			if err == nil && !d.IsDir() && match(path) {
+				check(path)
+			}
+			return nil
+		})
+	}
+	return changed
+}
+
+// stampTree records the files of a polled tree, so only later changes count.
+// Callers hold w.mu.
+func (w *Watcher) stampTree(root string, match func(path string) bool) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && w.skipped(path) {
+			return filepath.SkipDir
+		}
+		if err == nil && !d.IsDir() && match(path) {
+			w.stamps[path] = statStamp(path)
+		}
+		return nil
+	})
+}
+
+// addTree watches every directory below root
+func (w *Watcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || w.treeDirs[path] {
+			return nil
+		}
+		if w.skipped(path) {
+			return filepath.SkipDir
+		}
+		if len(w.dirs[path]) == 0 {
+			if err := w.watcher.Add(path); err != nil {
+				return err
+			}
+		}
+		w.treeDirs[path] = true
+		return nil
+	})
+}
+
+// watchNewDir starts watching a folder created inside a watched tree
+func (w *Watcher) watchNewDir(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.treeDirs[filepath.Dir(path)] {
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		w.addTree(path)
+	}
+}
+
+// skipped reports whether a folder is left out of the tree it is in.
+// Callers hold w.mu.
+func (w *Watcher) skipped(dir string) bool {
+	for root, skip := range w.skipDirs {
+		if dir != root && within(root, dir) && skip(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is root or below it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// inTree reports whether a path is reported by one of the watched trees.
+// Callers hold w.mu.
+func (w *Watcher) inTree(path string) bool {
+	for root, match := range w.trees {
+		if within(root, path) && match(path) {
+			return true
+		}
+	}
+	return false
+}
+
 // Clear removes all watched files
 func (w *Watcher) Clear() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	for path := range w.files {
-		w.watcher.Remove(path)
+	for dir := range w.dirs {
+		w.watcher.Remove(dir)
 	}
+	for dir := range w.treeDirs {
+		if len(w.dirs[dir]) == 0 {
+			w.watcher.Remove(dir)
+		}
+	}
+	w.debounceMu.Lock()
+	for path, timer := range w.pending {
+		timer.Stop()
+		delete(w.pending, path)
+	}
+	w.debounceMu.Unlock()
 	w.files = make(map[string]bool)
+	w.dirs = make(map[string][]string)
+	w.trees = make(map[string]func(path string) bool)
+	w.treeDirs = make(map[string]bool)
+	w.skipDirs = make(map[string]func(dir string) bool)
+	w.polled = make(map[string]bool)
+	w.pollTrees = make(map[string]func(path string) bool)
+	w.stamps = make(map[string]fileStamp)
+}
+
+// cancelPending drops a debounced notification that has not fired yet
+func (w *Watcher) cancelPending(path string) {
+	w.debounceMu.Lock()
+	defer w.debounceMu.Unlock()
+
+	if timer, exists := w.pending[path]; exists {
+		timer.Stop()
+		delete(w.pending, path)
+	}
 }
 
 // Close stops the watcher
 func (w *Watcher) Close() error {
+	w.debounceMu.Lock()
+	for path, timer := range w.pending {
+		timer.Stop()
+		delete(w.pending, path)
+	}
+	w.debounceMu.Unlock()
+
 	close(w.stopChan)
 	return w.watcher.Close()
 }
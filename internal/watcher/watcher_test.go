@@ -0,0 +1,132 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestAtomicSaves replays the file operations editors perform when saving and
+// checks that each save is reported once, for the watched path
+func TestAtomicSaves(t *testing.T) {
+	tests := []struct {
+		name      string
+		writeOnly bool
+		save      func(t *testing.T, path string)
+		want      int // notifications expected
+	}{
+		{
+			name: "write in place",
+			save: func(t *testing.T, path string) {
+				write(t, path, "saved")
+			},
+			want: 1,
+		},
+		{
+			// vim with backupcopy=no: the original becomes the backup and a
+			// new file is written under the old name
+			name: "rename to backup and write new file",
+			save: func(t *testing.T, path string) {
+				rename(t, path, path+"~")
+				write(t, path, "saved")
+				remove(t, path+"~")
+			},
+			want: 1,
+		},
+		{
+			name: "rename temp file onto target",
+			save: func(t *testing.T, path string) {
+				tmp := filepath.Join(filepath.Dir(path), ".app.js.swp")
+				write(t, tmp, "saved")
+				rename(t, tmp, path)
+			},
+			want: 1,
+		},
+		{
+			name:      "rename temp file onto target, writes only",
+			writeOnly: true,
+			save: func(t *testing.T, path string) {
+				tmp := filepath.Join(filepath.Dir(path), ".app.js.swp")
+				write(t, tmp, "saved")
+				rename(t, tmp, path)
+			},
+			want: 1,
+		},
+		{
+			name: "delete",
+			save: func(t *testing.T, path string) {
+				remove(t, path)
+			},
+			want: 0,
+		},
+		{
+			name: "unwatched file in the same folder",
+			save: func(t *testing.T, path string) {
+				write(t, filepath.Join(filepath.Dir(path), "other.js"), "saved")
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.js")
+			write(t, path, "original")
+
+			changes := make(chan string, 10)
+			w, err := New(func(path string) { changes <- path })
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer w.Close()
+			w.debounceMs = 50 * time.Millisecond
+			w.SetWriteOnly(tt.writeOnly)
+			w.SetMode(ModeEvents)
+			if err := w.AddFile(path); err != nil {
+				t.Fatalf("AddFile: %v", err)
+			}
+
+			tt.save(t, path)
+
+			got := 0
+			timeout := time.After(time.Second)
+		wait:
+			for {
+				select {
+				case changed := <-changes:
+					if changed != path {
+						t.Errorf("notified for %s, want %s", changed, path)
+					}
+					got++
+				case <-timeout:
+					break wait
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got %d notifications, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func rename(t *testing.T, from, to string) {
+	t.Helper()
+	if err := os.Rename(from, to); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func remove(t *testing.T, path string) {
+	t.Helper()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+}